@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/config"
+	"ipinfo/internal/db"
+	"ipinfo/internal/server"
+)
+
+// command is an offline lookup that runs against the local databases instead of starting the server.
+type command struct {
+	usage  string
+	lookup func(ctx context.Context, geoIP *db.GeoIPManager, query, lang string) (any, error)
+}
+
+// commands are the offline lookups by name.
+var commands = map[string]command{
+	"lookup": {
+		usage:  "ip|cidr",
+		lookup: server.LookupAddress,
+	},
+	"asn": {
+		usage: "asn",
+		lookup: func(ctx context.Context, geoIP *db.GeoIPManager, query, _ string) (any, error) {
+			return server.LookupASN(ctx, geoIP, query)
+		},
+	},
+	"domain": {
+		usage: "domain",
+		lookup: func(ctx context.Context, _ *db.GeoIPManager, query, _ string) (any, error) {
+			return server.LookupDomain(ctx, query)
+		},
+	},
+}
+
+// runCommand runs an offline lookup of the queries given as arguments, or one per line on stdin when
+// there are none. It returns the exit code: 1 if a lookup failed and 2 for invalid usage.
+func runCommand(cfg *config.Config, args []string) int {
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected lookup, asn or domain\n", name)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("ipinfo "+name, flag.ContinueOnError)
+	format := fs.String("format", "json", "output format: json, table or csv")
+	lang := fs.String("lang", "", "language of place names, such as de")
+	reverseDNS := fs.Bool("reverse-dns", false, "resolve the hostname of addresses, which needs DNS access")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ipinfo [flags] %s [-format json|table|csv] [-lang code] [-reverse-dns] [%s ...]\n\n", name, cmd.usage)
+		fmt.Fprintf(fs.Output(), "Queries are read from stdin, one per line, when none are given.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *format != "json" && *format != "table" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "unsupported format %q, expected json, table or csv\n", *format)
+		return 2
+	}
+
+	geoIP, err := db.OpenGeoIPManager(cfg.Database)
+	if err != nil {
+		slog.Error("failed to open databases", "error", err)
+		return 1
+	}
+	defer geoIP.Close()
+
+	// Lookups stay offline unless hostnames are asked for.
+	ctx := context.Background()
+	if !*reverseDNS {
+		ctx = common.WithoutReverseDNS(ctx)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if fs.NArg() == 1 {
+		data, err := cmd.lookup(ctx, geoIP, fs.Arg(0), *lang)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), err)
+			return 1
+		}
+		if err := writeResult(out, *format, data); err != nil {
+			slog.Error("failed to write result", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	queries := fs.Args()
+	if len(queries) == 0 {
+		queries, err = readQueries(os.Stdin)
+		if err != nil {
+			slog.Error("failed to read queries from stdin", "error", err)
+			return 1
+		}
+	}
+
+	failed := false
+	var rows []any
+	for _, query := range queries {
+		data, lookupErr := cmd.lookup(ctx, geoIP, query, *lang)
+		failed = failed || lookupErr != nil
+
+		if *format == "json" {
+			// One result per line, like the stream and job endpoints.
+			err = server.Encode(out, "json", server.Result(query, data, lookupErr), false)
+		} else {
+			var row any
+			row, err = server.ResultRow(query, data, lookupErr)
+			rows = append(rows, row)
+		}
+		if err != nil {
+			slog.Error("failed to write result", "query", query, "error", err)
+			return 1
+		}
+	}
+
+	if *format != "json" {
+		if err := writeResult(out, *format, rows); err != nil {
+			slog.Error("failed to write results", "error", err)
+			return 1
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// readQueries reads one query per line, skipping blank lines and lines starting with #.
+func readQueries(r io.Reader) ([]string, error) {
+	var queries []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	return queries, scanner.Err()
+}
+
+// writeResult writes a result, or a list of result rows, as indented JSON, CSV or an aligned table.
+// A single result is shown in the table as one field per line.
+func writeResult(w io.Writer, format string, data any) error {
+	switch format {
+	case "json":
+		return server.Encode(w, "json", data, true)
+	case "csv":
+		return server.Encode(w, "csv", data, false)
+	}
+
+	var buf bytes.Buffer
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if rows, ok := data.([]any); ok {
+		if err := server.Encode(&buf, "csv", rows, false); err != nil {
+			return err
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			fmt.Fprintln(table, strings.Join(record, "\t"))
+		}
+	} else {
+		if err := server.Encode(&buf, "text", data, false); err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			key, value, _ := strings.Cut(line, "=")
+			fmt.Fprintf(table, "%s\t%s\n", key, value)
+		}
+	}
+	return table.Flush()
+}
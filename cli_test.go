@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"ipinfo/internal/server"
+)
+
+func TestReadQueries(t *testing.T) {
+	queries, err := readQueries(strings.NewReader("8.8.8.8\n\n# resolvers\n  1.1.1.0/24 \nAS13335\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"8.8.8.8", "1.1.1.0/24", "AS13335"}; !slices.Equal(queries, want) {
+		t.Errorf("queries %q, want %q", queries, want)
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	type result struct {
+		IP      string `json:"ip"`
+		Country string `json:"country"`
+	}
+	first, err := server.ResultRow("8.8.8.8", result{"8.8.8.8", "US"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := server.ResultRow("bad", nil, errors.New("invalid query"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		data   any
+		want   string
+	}{
+		{"json", result{"8.8.8.8", "US"}, "{\n  \"ip\": \"8.8.8.8\",\n  \"country\": \"US\"\n}\n"},
+		{"table", result{"8.8.8.8", "US"}, "ip       8.8.8.8\ncountry  US\n"},
+		{"csv", []any{first, second}, "query,error,ip,country\n8.8.8.8,,8.8.8.8,US\nbad,invalid query,,\n"},
+		{"table", []any{first, second}, "query    error          ip       country\n8.8.8.8                 8.8.8.8  US\nbad      invalid query" + strings.Repeat(" ", 11) + "\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeResult(&buf, tt.format, tt.data); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s output:\n%s\nwant:\n%s", tt.format, buf.String(), tt.want)
+		}
+	}
+}
@@ -1,3 +0,0 @@
-module healthcheck
-
-go 1.24.0
@@ -1,22 +1,47 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"time"
+
+	"ipinfo/internal/config"
+
+	"github.com/joho/godotenv"
 )
 
+// timeout bounds the health request, below the interval of the container health check.
+const timeout = 4 * time.Second
+
 func main() {
-	resp, err := http.Get("http://localhost:3000/health")
+	_ = godotenv.Load()
+
+	// The configuration is loaded like the server's, from the config file, the environment and the
+	// flags, so the probe reaches the address and scheme the server actually listens on.
+	cfg, _, _, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		slog.Error("error performing healthcheck", "err", err)
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	resp, err := healthClient(cfg).Get(healthURL(cfg))
+	if err != nil {
+		slog.Error("error performing healthcheck", "error", err)
 		os.Exit(1)
 	}
 
 	defer func() {
-		if cerr := resp.Body.Close(); err != nil {
-			slog.Warn("failed to close response body", "err", cerr)
+		if cerr := resp.Body.Close(); cerr != nil {
+			slog.Warn("failed to close response body", "error", cerr)
 		}
 	}()
 
@@ -26,5 +51,34 @@ func main() {
 	}
 
 	fmt.Println("OK")
-	os.Exit(0)
+}
+
+// healthURL returns the health endpoint of the server's own listener, reached through localhost unless
+// the server listens on a specific address.
+func healthURL(cfg *config.Config) string {
+	host, port, err := net.SplitHostPort(cfg.Server.Address)
+	if err != nil || port == "" {
+		port = "3000"
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	scheme := "http"
+	if cfg.TLS.Enabled() {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port) + "/health"
+}
+
+// healthClient returns the client of the probe. The server's certificate is issued for its public
+// names, not for the local address the probe connects to, so it is not verified.
+func healthClient(cfg *config.Config) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if cfg.TLS.Enabled() {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return client
 }
@@ -2,7 +2,10 @@ package common
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"ipinfo/internal/metrics"
 )
 
 // cachedItem represents a generic item in the cache.
@@ -15,6 +18,7 @@ type cachedItem struct {
 type Cache struct {
 	store sync.Map
 	ttl   time.Duration
+	size  atomic.Int64
 }
 
 // NewCache creates a new generic cache with the specified TTL.
@@ -26,10 +30,13 @@ func NewCache(ttl time.Duration) *Cache {
 
 // Set adds a new entry to the cache.
 func (c *Cache) Set(key any, data any) {
-	c.store.Store(key, cachedItem{
+	_, replaced := c.store.Swap(key, cachedItem{
 		data: data,
 		time: time.Now(),
 	})
+	if !replaced {
+		c.size.Add(1)
+	}
 }
 
 // Get retrieves an entry from the cache.
@@ -37,12 +44,27 @@ func (c *Cache) Get(key any) (any, bool) {
 	if item, ok := c.store.Load(key); ok {
 		cached := item.(cachedItem)
 		if time.Since(cached.time) < c.ttl {
+			metrics.CacheLookups.WithLabelValues("hit").Inc()
 			return cached.data, true
 		}
-		c.store.Delete(key)
+		if _, deleted := c.store.LoadAndDelete(key); deleted {
+			c.size.Add(-1)
+		}
 	}
+	metrics.CacheLookups.WithLabelValues("miss").Inc()
 	return nil, false
 }
 
-// Global cache with a 10-minute TTL.
-var cache = NewCache(10 * time.Minute)
+// Len returns the number of entries in the cache, including expired entries that have not been read since.
+func (c *Cache) Len() int {
+	return int(c.size.Load())
+}
+
+// Global cache of lookup results.
+var cache = NewCache(settings.Cache.TTL)
+
+func init() {
+	metrics.RegisterCacheEntries(func() float64 {
+		return float64(cache.Len())
+	})
+}
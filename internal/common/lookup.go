@@ -1,23 +1,42 @@
 package common
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"math/big"
 	"net"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"ipinfo/internal/config"
 	"ipinfo/internal/db"
+	"ipinfo/internal/metrics"
+	"ipinfo/internal/tracing"
 
 	whoisparser "github.com/likexian/whois-parser"
 	"github.com/miekg/dns"
+	"github.com/oschwald/maxminddb-golang"
 	"github.com/ringsaturn/tzf"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/publicsuffix"
 )
 
 var tzFinder tzf.F
 
+// settings holds the DNS, WHOIS and cache configuration of lookups.
+var settings = config.Default()
+
+// Configure applies the cache, DNS and WHOIS settings of cfg. It must be called before any lookup.
+func Configure(cfg *config.Config) {
+	settings = cfg
+	cache = NewCache(cfg.Cache.TTL)
+}
+
 func init() {
 	var err error
 	tzFinder, err = tzf.NewDefaultFinder()
@@ -26,49 +45,95 @@ func init() {
 	}
 }
 
+// noReverseDNSContextKey marks a context in which IP lookups skip the reverse DNS query.
+type noReverseDNSContextKey struct{}
+
+// WithoutReverseDNS returns a context in which IP lookups leave the hostname empty instead of
+// resolving it, so that they need no network access.
+func WithoutReverseDNS(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noReverseDNSContextKey{}, true)
+}
+
 // LookupIPData looks up IP data in the databases with caching.
-func LookupIPData(geoIP *db.GeoIPManager, ip net.IP) *DataStruct {
+// Place names are returned in lang when the database has them, otherwise in English.
+func LookupIPData(ctx context.Context, geoIP *db.GeoIPManager, ip net.IP, lang string) *DataStruct {
+	data, _ := LookupIPDataCached(ctx, geoIP, ip, lang)
+	return data
+}
+
+// LookupIPDataCached is LookupIPData that also reports whether the data came from the cache.
+// Lookups without reverse DNS bypass the cache, which holds complete data only.
+func LookupIPDataCached(ctx context.Context, geoIP *db.GeoIPManager, ip net.IP, lang string) (*DataStruct, bool) {
+	if ctx.Value(noReverseDNSContextKey{}) != nil {
+		return LookupIPDataUncached(ctx, geoIP, ip, lang), false
+	}
+	key := ipCacheKey{ip: ip.String(), lang: lang}
+	if data, found := cache.Get(key); found {
+		return data.(*DataStruct), true
+	}
+
+	data := LookupIPDataUncached(ctx, geoIP, ip, lang)
+	if data != nil {
+		cache.Set(key, data)
+	}
+	return data, false
+}
+
+// LookupIPDataUncached looks up IP data in the databases without reading or filling the cache.
+// Bulk callers use it so that large inputs do not grow the cache.
+func LookupIPDataUncached(ctx context.Context, geoIP *db.GeoIPManager, ip net.IP, lang string) *DataStruct {
 	ipStr := ip.String()
-	if data, found := cache.Get(ipStr); found {
-		return data.(*DataStruct)
-	}
-
-	var cityRecord struct {
-		City struct {
-			Names map[string]string `maxminddb:"names"`
-		} `maxminddb:"city"`
-		Subdivisions []struct {
-			Names map[string]string `maxminddb:"names"`
-		} `maxminddb:"subdivisions"`
-		Country struct {
-			IsoCode string `maxminddb:"iso_code"`
-		} `maxminddb:"country"`
-		Location struct {
-			Latitude  float64 `maxminddb:"latitude"`
-			Longitude float64 `maxminddb:"longitude"`
-		} `maxminddb:"location"`
-	}
-
-	if err := geoIP.GetCityDB().Lookup(ip, &cityRecord); err != nil {
+
+	var cityRecord db.CityRecord
+	if _, _, err := lookupDB(ctx, geoIP.GetCityDB(), db.CityDBName, ip, &cityRecord); err != nil {
 		slog.Error("failed to look up city data", "err", err)
 		return nil
 	}
 
 	var asnRecord db.ASNRecord
-	if err := geoIP.GetASNDB().Lookup(ip, &asnRecord); err != nil {
+	asnNetwork, asnFound, err := lookupDB(ctx, geoIP.GetASNDB(), db.ASNDBName, ip, &asnRecord)
+	if err != nil {
 		slog.Error("failed to look up asn data", "err", err)
 		return nil
 	}
 
-	hostname, _ := net.LookupAddr(ipStr)
+	var network *string
+	if asnFound {
+		network = ToPtr(asnNetwork.String())
+	}
+
 	hostnameStr := ""
-	if len(hostname) > 0 {
-		hostnameStr = strings.TrimSuffix(hostname[0], ".")
+	if ctx.Value(noReverseDNSContextKey{}) == nil {
+		hostnameStr = lookupHostname(ctx, ipStr)
 	}
 
 	var region *string
-	if len(cityRecord.Subdivisions) > 0 {
-		region = ToPtr(cityRecord.Subdivisions[0].Names["en"])
+	subdivisions := make([]SubdivisionInfo, 0, len(cityRecord.Subdivisions))
+	for _, subdivision := range cityRecord.Subdivisions {
+		subdivisions = append(subdivisions, SubdivisionInfo{
+			Code: ToPtr(subdivision.IsoCode),
+			Name: ToPtr(localizedName(subdivision.Names, lang)),
+		})
+	}
+	if len(subdivisions) > 0 {
+		region = subdivisions[0].Name
+	}
+
+	var asn *uint
+	var org *string
+	if asnRecord.AutonomousSystemNumber > 0 {
+		asn = &asnRecord.AutonomousSystemNumber
+		org = ToPtr(fmt.Sprintf("AS%d %s", asnRecord.AutonomousSystemNumber, asnRecord.AutonomousSystemOrganization))
+	}
+
+	var isEU *bool
+	if cityRecord.Country.IsoCode != "" {
+		isEU = &cityRecord.Country.IsInEuropeanUnion
+	}
+
+	var accuracyRadius *uint16
+	if cityRecord.Location.AccuracyRadius > 0 {
+		accuracyRadius = &cityRecord.Location.AccuracyRadius
 	}
 
 	var timezone string
@@ -76,35 +141,129 @@ func LookupIPData(geoIP *db.GeoIPManager, ip net.IP) *DataStruct {
 		timezone = tzFinder.GetTimezoneName(cityRecord.Location.Longitude, cityRecord.Location.Latitude)
 	}
 
-	data := &DataStruct{
-		IP:       ToPtr(ipStr),
-		Hostname: ToPtr(hostnameStr),
-		Org:      ToPtr(fmt.Sprintf("AS%d %s", asnRecord.AutonomousSystemNumber, asnRecord.AutonomousSystemOrganization)),
-		City:     ToPtr(cityRecord.City.Names["en"]),
-		Region:   region,
-		Country:  ToPtr(cityRecord.Country.IsoCode),
-		Timezone: ToPtr(timezone),
-		Loc:      ToPtr(fmt.Sprintf("%.4f,%.4f", cityRecord.Location.Latitude, cityRecord.Location.Longitude)),
+	return &DataStruct{
+		IP:             ToPtr(ipStr),
+		Hostname:       ToPtr(hostnameStr),
+		ASN:            asn,
+		ASName:         ToPtr(asnRecord.AutonomousSystemOrganization),
+		Org:            org,
+		Network:        network,
+		City:           ToPtr(localizedName(cityRecord.City.Names, lang)),
+		Region:         region,
+		Subdivisions:   subdivisions,
+		Postal:         ToPtr(cityRecord.Postal.Code),
+		Country:        ToPtr(cityRecord.Country.IsoCode),
+		CountryName:    ToPtr(localizedName(cityRecord.Country.Names, lang)),
+		IsEU:           isEU,
+		Continent:      ToPtr(localizedName(cityRecord.Continent.Names, lang)),
+		ContinentCode:  ToPtr(cityRecord.Continent.Code),
+		Timezone:       ToPtr(timezone),
+		Loc:            ToPtr(fmt.Sprintf("%.4f,%.4f", cityRecord.Location.Latitude, cityRecord.Location.Longitude)),
+		AccuracyRadius: accuracyRadius,
+	}
+}
+
+// lookupHostname returns the first PTR name of an address, or "" if it has none.
+func lookupHostname(ctx context.Context, ip string) string {
+	_, span := tracing.Start(ctx, "reverse_dns", attribute.String("net.ip", ip))
+	start := time.Now()
+	hostname, err := net.LookupAddr(ip)
+	metrics.ObserveUpstream("reverse_dns", start, err)
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		// An address without a PTR record is not a failed lookup.
+		err = nil
+	}
+	tracing.End(span, err)
+	if len(hostname) == 0 {
+		return ""
 	}
+	return strings.TrimSuffix(hostname[0], ".")
+}
 
-	cache.Set(ipStr, data)
-	return data
+// ErrNetworkSplit is returned by LookupNetworkData when the databases record the queried prefix as
+// several smaller networks, so no single record describes all of it.
+var ErrNetworkSplit = errors.New("network is wider than the database networks it covers")
+
+// LookupNetworkData looks up the database networks enclosing a CIDR prefix with caching.
+func LookupNetworkData(ctx context.Context, geoIP *db.GeoIPManager, network *net.IPNet) (*NetworkDataResponse, error) {
+	data, _, err := LookupNetworkDataCached(ctx, geoIP, network)
+	return data, err
+}
+
+// LookupNetworkDataCached is LookupNetworkData that also reports whether the data came from the cache.
+func LookupNetworkDataCached(ctx context.Context, geoIP *db.GeoIPManager, network *net.IPNet) (*NetworkDataResponse, bool, error) {
+	cidr := network.String()
+	if data, found := cache.Get(cidr); found {
+		return data.(*NetworkDataResponse), true, nil
+	}
+
+	var cityRecord db.CityRecord
+	cityNetwork, cityFound, err := lookupDB(ctx, geoIP.GetCityDB(), db.CityDBName, network.IP, &cityRecord)
+	if err != nil {
+		return nil, false, fmt.Errorf("looking up city network: %w", err)
+	}
+
+	var asnRecord db.ASNRecord
+	asnNetwork, asnFound, err := lookupDB(ctx, geoIP.GetASNDB(), db.ASNDBName, network.IP, &asnRecord)
+	if err != nil {
+		return nil, false, fmt.Errorf("looking up asn network: %w", err)
+	}
+
+	ones, bits := network.Mask.Size()
+	lastIP := make(net.IP, len(network.IP))
+	for i := range network.IP {
+		lastIP[i] = network.IP[i] | ^network.Mask[i]
+	}
+
+	// The databases are searched by the first address, so a result only describes the prefix if its
+	// network also holds the last one. Otherwise the prefix spans several database networks.
+	cityEncloses := cityNetwork != nil && cityNetwork.Contains(lastIP)
+	asnEncloses := asnNetwork != nil && asnNetwork.Contains(lastIP)
+	if !cityEncloses && !asnEncloses {
+		return nil, false, fmt.Errorf("%w: %s", ErrNetworkSplit, cidr)
+	}
+
+	response := &NetworkDataResponse{
+		Network:      cidr,
+		PrefixLength: ones,
+		FirstAddress: network.IP.String(),
+		LastAddress:  lastIP.String(),
+		AddressCount: new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)),
+	}
+
+	if cityFound && cityEncloses {
+		response.CityNetwork = ToPtr(cityNetwork.String())
+		response.Country = ToPtr(cityRecord.Country.IsoCode)
+	}
+	if asnFound && asnEncloses {
+		response.ASNNetwork = ToPtr(asnNetwork.String())
+		response.Org = ToPtr(fmt.Sprintf("AS%d %s", asnRecord.AutonomousSystemNumber, asnRecord.AutonomousSystemOrganization))
+	}
+
+	cache.Set(cidr, response)
+	return response, false, nil
 }
 
 // LookupASNData looks up ASN data in the databases with caching.
-func LookupASNData(geoIP *db.GeoIPManager, targetASN uint) (*ASNDataResponse, error) {
+func LookupASNData(ctx context.Context, geoIP *db.GeoIPManager, targetASN uint) (*ASNDataResponse, error) {
+	data, _, err := LookupASNDataCached(ctx, geoIP, targetASN)
+	return data, err
+}
+
+// LookupASNDataCached is LookupASNData that also reports whether the data came from the cache.
+func LookupASNDataCached(ctx context.Context, geoIP *db.GeoIPManager, targetASN uint) (*ASNDataResponse, bool, error) {
 	if data, found := cache.Get(targetASN); found {
-		return data.(*ASNDataResponse), nil
+		return data.(*ASNDataResponse), true, nil
 	}
 
 	prefixes := geoIP.GetASNPrefixes(targetASN)
 	if len(prefixes) == 0 {
-		return nil, fmt.Errorf("no prefixes found for as%d in the database", targetASN)
+		return nil, false, fmt.Errorf("no prefixes found for as%d in the database", targetASN)
 	}
 
 	var orgName string
 	var record db.ASNRecord
-	if err := geoIP.GetASNDB().Lookup(prefixes[0].IP, &record); err == nil {
+	if _, _, err := lookupDB(ctx, geoIP.GetASNDB(), db.ASNDBName, prefixes[0].IP, &record); err == nil {
 		orgName = record.AutonomousSystemOrganization
 	}
 
@@ -134,20 +293,52 @@ func LookupASNData(geoIP *db.GeoIPManager, targetASN uint) (*ASNDataResponse, er
 	}
 
 	cache.Set(targetASN, response)
-	return response, nil
+	return response, false, nil
 }
 
-// queryDns performs a DNS query for a specific type against a public resolver.
-func queryDns(domain string, recordType uint16) ([]dns.RR, error) {
-	c := new(dns.Client)
+// LookupOrigin looks up the ASN and prefix announcing an IP address, and the country it is located in,
+// without the reverse DNS lookup of LookupIPData. It returns nil if no ASN announces the address.
+func LookupOrigin(ctx context.Context, geoIP *db.GeoIPManager, ip net.IP) (*OriginData, error) {
+	var asnRecord db.ASNRecord
+	asnNetwork, found, err := lookupDB(ctx, geoIP.GetASNDB(), db.ASNDBName, ip, &asnRecord)
+	if err != nil {
+		return nil, fmt.Errorf("looking up asn network: %w", err)
+	}
+	if !found || asnRecord.AutonomousSystemNumber == 0 {
+		return nil, nil
+	}
+
+	var cityRecord db.CityRecord
+	if _, _, err := lookupDB(ctx, geoIP.GetCityDB(), db.CityDBName, ip, &cityRecord); err != nil {
+		return nil, fmt.Errorf("looking up city data: %w", err)
+	}
+
+	return &OriginData{
+		ASN:     asnRecord.AutonomousSystemNumber,
+		ASName:  asnRecord.AutonomousSystemOrganization,
+		Prefix:  asnNetwork.String(),
+		Country: cityRecord.Country.IsoCode,
+	}, nil
+}
+
+// queryDns performs a DNS query for a specific type against the configured resolver.
+func queryDns(ctx context.Context, domain string, recordType uint16) (answers []dns.RR, err error) {
+	_, span := tracing.Start(ctx, "dns.query",
+		attribute.String("dns.question.name", domain),
+		attribute.String("dns.question.type", dns.TypeToString[recordType]),
+		attribute.String("server.address", settings.DNS.Resolver))
+	defer func() { tracing.End(span, err) }()
+
+	c := &dns.Client{Timeout: settings.DNS.Timeout}
 	m := new(dns.Msg)
 	m.SetQuestion(dns.Fqdn(domain), recordType)
 	m.RecursionDesired = true
 
-	r, _, err := c.Exchange(m, "1.1.1.1:53")
+	r, _, err := c.Exchange(m, settings.DNS.Resolver)
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(attribute.String("dns.response.code", dns.RcodeToString[r.Rcode]), attribute.Int("dns.answers", len(r.Answer)))
 
 	if r.Rcode != dns.RcodeSuccess {
 		return nil, nil
@@ -156,18 +347,35 @@ func queryDns(domain string, recordType uint16) ([]dns.RR, error) {
 	return r.Answer, nil
 }
 
+// lookupDB looks up ip in a database inside a span named after it.
+func lookupDB(ctx context.Context, reader *maxminddb.Reader, name string, ip net.IP, record any) (*net.IPNet, bool, error) {
+	_, span := tracing.Start(ctx, "mmdb.lookup", attribute.String("mmdb.database", name), attribute.String("net.ip", ip.String()))
+	network, found, err := reader.LookupNetwork(ip, record)
+	span.SetAttributes(attribute.Bool("mmdb.found", found))
+	tracing.End(span, err)
+	return network, found, err
+}
+
 // LookupDomainData looks up domain data with caching.
-func LookupDomainData(domain string) (*DomainDataResponse, error) {
+func LookupDomainData(ctx context.Context, domain string) (*DomainDataResponse, error) {
+	data, _, err := LookupDomainDataCached(ctx, domain)
+	return data, err
+}
+
+// LookupDomainDataCached is LookupDomainData that also reports whether the data came from the cache.
+func LookupDomainDataCached(ctx context.Context, domain string) (*DomainDataResponse, bool, error) {
 	if data, found := cache.Get(domain); found {
-		return data.(*DomainDataResponse), nil
+		return data.(*DomainDataResponse), true, nil
 	}
 
 	eTLD, err := publicsuffix.EffectiveTLDPlusOne(domain)
 	if err != nil {
-		return nil, fmt.Errorf("invalid domain: %w", err)
+		return nil, false, fmt.Errorf("invalid domain: %w", err)
 	}
 
-	whoisRaw, err := performWhoisWithFallback(eTLD)
+	start := time.Now()
+	whoisRaw, err := performWhoisWithFallback(ctx, eTLD)
+	metrics.ObserveUpstream("whois", start, err)
 	var whoisResult any
 	if err != nil {
 		slog.Error("whois lookup failed completely", "domain", eTLD, "err", err)
@@ -183,6 +391,7 @@ func LookupDomainData(domain string) (*DomainDataResponse, error) {
 	}
 
 	dnsData := DNSData{}
+	minTTL := uint32(math.MaxUint32)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
@@ -201,7 +410,9 @@ func LookupDomainData(domain string) (*DomainDataResponse, error) {
 		wg.Add(1)
 		go func(name string, recordType uint16) {
 			defer wg.Done()
-			answers, err := queryDns(domain, recordType)
+			start := time.Now()
+			answers, err := queryDns(ctx, domain, recordType)
+			metrics.ObserveUpstream("dns", start, err)
 			if err != nil {
 				slog.Debug("dns lookup failed for type", "type", name, "domain", domain, "err", err)
 				return
@@ -210,6 +421,7 @@ func LookupDomainData(domain string) (*DomainDataResponse, error) {
 			mu.Lock()
 			defer mu.Unlock()
 			for _, ans := range answers {
+				minTTL = min(minTTL, ans.Header().Ttl)
 				switch rr := ans.(type) {
 				case *dns.A:
 					dnsData.A = append(dnsData.A, rr.A.String())
@@ -249,7 +461,10 @@ func LookupDomainData(domain string) (*DomainDataResponse, error) {
 		Whois: whoisResult,
 		DNS:   dnsData,
 	}
+	if minTTL != math.MaxUint32 {
+		response.Expires = time.Now().Add(time.Duration(minTTL) * time.Second)
+	}
 
 	cache.Set(domain, response)
-	return response, nil
+	return response, false, nil
 }
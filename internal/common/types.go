@@ -1,15 +1,55 @@
 package common
 
+import (
+	"math/big"
+	"time"
+)
+
+// ipCacheKey identifies a cached IP lookup in a specific language.
+type ipCacheKey struct {
+	ip   string
+	lang string
+}
+
 // DataStruct represents the structure of the IP data returned by the API.
 type DataStruct struct {
-	IP       *string `json:"ip"`
-	Hostname *string `json:"hostname"`
-	Org      *string `json:"org"`
-	City     *string `json:"city"`
-	Region   *string `json:"region"`
-	Country  *string `json:"country"`
-	Timezone *string `json:"timezone"`
-	Loc      *string `json:"loc"`
+	IP             *string           `json:"ip"`
+	Hostname       *string           `json:"hostname"`
+	ASN            *uint             `json:"asn"`
+	ASName         *string           `json:"as_name"`
+	Org            *string           `json:"org"`
+	Network        *string           `json:"network"`
+	City           *string           `json:"city"`
+	Region         *string           `json:"region"`
+	Subdivisions   []SubdivisionInfo `json:"subdivisions"`
+	Postal         *string           `json:"postal"`
+	Country        *string           `json:"country"`
+	CountryName    *string           `json:"country_name"`
+	IsEU           *bool             `json:"is_in_european_union"`
+	Continent      *string           `json:"continent"`
+	ContinentCode  *string           `json:"continent_code"`
+	Timezone       *string           `json:"timezone"`
+	Loc            *string           `json:"loc"`
+	AccuracyRadius *uint16           `json:"accuracy_radius"`
+}
+
+// SubdivisionInfo represents a single subdivision level, from largest to smallest.
+type SubdivisionInfo struct {
+	Code *string `json:"code"`
+	Name *string `json:"name"`
+}
+
+// NetworkDataResponse represents the structure of the CIDR network data returned by the API.
+type NetworkDataResponse struct {
+	Network      string   `json:"network"`
+	PrefixLength int      `json:"prefix_length"`
+	FirstAddress string   `json:"first_address"`
+	LastAddress  string   `json:"last_address"`
+	AddressCount *big.Int `json:"address_count"`
+	CityNetwork  *string  `json:"city_network"`
+	ASNNetwork   *string  `json:"asn_network"`
+	Org          *string  `json:"org"`
+	Country      *string  `json:"country"`
 }
 
 // ASNDataResponse represents the structure of the ASN data returned by the API.
@@ -30,10 +70,20 @@ type ASNPrefixInfo struct {
 	IPv6 []string `json:"ipv6"`
 }
 
+// OriginData represents the origin ASN, announced prefix and country of an IP address.
+type OriginData struct {
+	ASN     uint   `json:"asn"`
+	ASName  string `json:"as_name"`
+	Prefix  string `json:"prefix"`
+	Country string `json:"country"`
+}
+
 // DomainDataResponse represents the structure of the domain data returned by the API.
 type DomainDataResponse struct {
 	Whois interface{} `json:"whois"`
 	DNS   DNSData     `json:"dns"`
+	// Expires is when the first of the DNS records expires, or the zero time if there are none.
+	Expires time.Time `json:"-"`
 }
 
 // DNSData represents the structure of the DNS records.
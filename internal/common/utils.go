@@ -2,10 +2,14 @@ package common
 
 import (
 	"net"
+	"strings"
 
 	"ipinfo/utils"
 )
 
+// DefaultLanguage is the language used for place names when no other language is available.
+const DefaultLanguage = "en"
+
 // ToPtr converts a string to a pointer, returning nil for empty strings.
 func ToPtr(s string) *string {
 	if s == "" {
@@ -23,3 +27,16 @@ func IsBogon(ip net.IP) bool {
 	}
 	return false
 }
+
+// localizedName returns the name for lang, falling back to its base language and then to English.
+func localizedName(names map[string]string, lang string) string {
+	if name := names[lang]; name != "" {
+		return name
+	}
+	if base, _, found := strings.Cut(lang, "-"); found {
+		if name := names[base]; name != "" {
+			return name
+		}
+	}
+	return names[DefaultLanguage]
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -9,23 +10,32 @@ import (
 	"strings"
 	"time"
 
+	"ipinfo/internal/tracing"
+
 	"github.com/likexian/whois"
 	whoisparser "github.com/likexian/whois-parser"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // performWhoisWithFallback attempts a WHOIS query and falls back to manual lookup if the default fails.
-func performWhoisWithFallback(domain string) (string, error) {
+// Each attempt gets its own span below a whois span covering them all.
+func performWhoisWithFallback(ctx context.Context, domain string) (result string, err error) {
+	ctx, span := tracing.Start(ctx, "whois", attribute.String("whois.domain", domain))
+	defer func() { tracing.End(span, err) }()
+
 	c := whois.NewClient()
-	c.SetTimeout(5 * time.Second)
+	c.SetTimeout(settings.Whois.Timeout)
 
-	result, err := c.Whois(domain)
+	_, attempt := tracing.Start(ctx, "whois.query", attribute.String("whois.attempt", "primary"))
+	result, err = c.Whois(domain, settings.Whois.Server)
+	tracing.End(attempt, err)
 	if err == nil {
 		return result, nil
 	}
 
 	slog.Warn("standard whois lookup failed, attempting fallback", "domain", domain, "err", err)
 
-	serverHost, serverErr := getWhoisServerForDomain(domain)
+	serverHost, serverErr := getWhoisServerForDomain(ctx, domain)
 	if serverErr != nil {
 		slog.Error("could not find whois server during fallback", "domain", domain, "err", serverErr)
 		return "", err
@@ -41,7 +51,7 @@ func performWhoisWithFallback(domain string) (string, error) {
 		if ip.To4() != nil {
 			ipv4Server := ip.String()
 			slog.Info("retrying whois query with explicit ipv4 address", "domain", domain, "server", ipv4Server)
-			res, err := queryWhoisServer(domain, ipv4Server)
+			res, err := queryWhoisServer(ctx, domain, ipv4Server, "ipv4")
 			if err == nil {
 				return res, nil
 			}
@@ -53,7 +63,7 @@ func performWhoisWithFallback(domain string) (string, error) {
 		if ip.To4() == nil {
 			ipv6Server := ip.String()
 			slog.Info("retrying whois query with ipv6 address", "domain", domain, "server", ipv6Server)
-			res, err := queryWhoisServer(domain, ipv6Server)
+			res, err := queryWhoisServer(ctx, domain, ipv6Server, "ipv6")
 			if err == nil {
 				return res, nil
 			}
@@ -64,14 +74,20 @@ func performWhoisWithFallback(domain string) (string, error) {
 }
 
 // getWhoisServerForDomain finds the authoritative WHOIS server for a domain by querying IANA.
-func getWhoisServerForDomain(domain string) (string, error) {
+func getWhoisServerForDomain(ctx context.Context, domain string) (server string, err error) {
 	parts := strings.Split(domain, ".")
 	if len(parts) < 2 {
 		return "", fmt.Errorf("invalid domain: %s", domain)
 	}
 	tld := parts[len(parts)-1]
 
-	conn, err := net.Dial("tcp", "whois.iana.org:43")
+	_, span := tracing.Start(ctx, "whois.iana_referral", attribute.String("whois.tld", tld))
+	defer func() {
+		span.SetAttributes(attribute.String("whois.server", server))
+		tracing.End(span, err)
+	}()
+
+	conn, err := net.DialTimeout("tcp", "whois.iana.org:43", settings.Whois.FallbackTimeout)
 	if err != nil {
 		return "", fmt.Errorf("could not connect to iana whois server: %w", err)
 	}
@@ -81,6 +97,7 @@ func getWhoisServerForDomain(domain string) (string, error) {
 		}
 	}()
 
+	_ = conn.SetDeadline(time.Now().Add(settings.Whois.FallbackTimeout))
 	_, err = conn.Write([]byte(tld + "\r\n"))
 	if err != nil {
 		return "", fmt.Errorf("could not send query to iana whois server: %w", err)
@@ -103,9 +120,13 @@ func getWhoisServerForDomain(domain string) (string, error) {
 	return "", fmt.Errorf("could not find whois server for TLD: %s", tld)
 }
 
-// queryWhoisServer manually performs a WHOIS query to a specific server IP.
-func queryWhoisServer(domain, serverIP string) (string, error) {
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(serverIP, "43"), 10*time.Second)
+// queryWhoisServer manually performs a WHOIS query to a specific server IP. attempt names the fallback
+// in its span.
+func queryWhoisServer(ctx context.Context, domain, serverIP, attempt string) (result string, err error) {
+	_, span := tracing.Start(ctx, "whois.query", attribute.String("whois.attempt", attempt), attribute.String("server.address", serverIP))
+	defer func() { tracing.End(span, err) }()
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(serverIP, "43"), settings.Whois.FallbackTimeout)
 	if err != nil {
 		return "", fmt.Errorf("could not connect to %s: %w", serverIP, err)
 	}
@@ -115,7 +136,7 @@ func queryWhoisServer(domain, serverIP string) (string, error) {
 		}
 	}()
 
-	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_ = conn.SetDeadline(time.Now().Add(settings.Whois.FallbackTimeout))
 	_, err = conn.Write([]byte(domain + "\r\n"))
 	if err != nil {
 		return "", fmt.Errorf("could not send query to %s: %w", serverIP, err)
@@ -0,0 +1,571 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete configuration of the service.
+type Config struct {
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+	Tracing     TracingConfig     `yaml:"tracing" toml:"tracing"`
+	TLS         TLSConfig         `yaml:"tls" toml:"tls"`
+	GRPC        GRPCConfig        `yaml:"grpc" toml:"grpc"`
+	DNSServer   DNSServerConfig   `yaml:"dns_server" toml:"dns_server"`
+	WhoisServer WhoisServerConfig `yaml:"whois_server" toml:"whois_server"`
+	Proxy       ProxyConfig       `yaml:"proxy" toml:"proxy"`
+	CORS        CORSConfig        `yaml:"cors" toml:"cors"`
+	UI          UIConfig          `yaml:"ui" toml:"ui"`
+	Cache       CacheConfig       `yaml:"cache" toml:"cache"`
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	DNS         DNSConfig         `yaml:"dns" toml:"dns"`
+	Whois       WhoisConfig       `yaml:"whois" toml:"whois"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit"`
+	Jobs        JobsConfig        `yaml:"jobs" toml:"jobs"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	Address            string        `yaml:"address" toml:"address" env:"LISTEN_ADDRESS" usage:"address the HTTP server listens on"`
+	ReadTimeout        time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"READ_TIMEOUT" usage:"maximum time to read a request, 0 for none"`
+	WriteTimeout       time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"WRITE_TIMEOUT" usage:"maximum time to write a response, 0 for none"`
+	IdleTimeout        time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"IDLE_TIMEOUT" usage:"maximum time to keep an idle connection open, 0 for none"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"maximum time to wait for requests to finish on shutdown"`
+	BatchMaxSize       int           `yaml:"batch_max_size" toml:"batch_max_size" env:"BATCH_MAX_SIZE" usage:"maximum number of items in a batch request"`
+	CompressionMinSize int           `yaml:"compression_min_size" toml:"compression_min_size" env:"COMPRESSION_MIN_SIZE" usage:"smallest response body in bytes that is compressed"`
+	MetricsAddress     string        `yaml:"metrics_address" toml:"metrics_address" env:"METRICS_ADDRESS" usage:"address the Prometheus metrics endpoint listens on, empty to disable it"`
+}
+
+// LogConfig configures the log output and the access log of HTTP requests.
+type LogConfig struct {
+	Format             string     `yaml:"format" toml:"format" env:"LOG_FORMAT" usage:"log format, text or json"`
+	Level              slog.Level `yaml:"level" toml:"level" env:"LOG_LEVEL" usage:"minimum level of logged messages: debug, info, warn or error"`
+	AccessLevel        slog.Level `yaml:"access_level" toml:"access_level" env:"LOG_ACCESS_LEVEL" usage:"level of access log entries; server errors are logged at error level"`
+	AccessSampleRate   float64    `yaml:"access_sample_rate" toml:"access_sample_rate" env:"LOG_ACCESS_SAMPLE_RATE" usage:"fraction of requests written to the access log; server errors are always logged"`
+	AccessExcludePaths []string   `yaml:"access_exclude_paths" toml:"access_exclude_paths" env:"LOG_ACCESS_EXCLUDE_PATHS" usage:"request paths left out of the access log"`
+}
+
+// TracingConfig configures the export of OpenTelemetry traces.
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER" usage:"where spans are sent: none, stdout or otlp"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" toml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" usage:"host:port of the OTLP gRPC collector, empty for OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317"`
+	OTLPInsecure bool    `yaml:"otlp_insecure" toml:"otlp_insecure" env:"TRACING_OTLP_INSECURE" usage:"connect to the OTLP collector without TLS"`
+	SampleRate   float64 `yaml:"sample_rate" toml:"sample_rate" env:"TRACING_SAMPLE_RATE" usage:"fraction of traces started here that are recorded; incoming sampling decisions are kept"`
+	ServiceName  string  `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME" usage:"service name reported with the spans"`
+}
+
+// TLSConfig enables HTTPS, either with a certificate from files or with certificates obtained through ACME.
+type TLSConfig struct {
+	CertFile         string   `yaml:"cert_file" toml:"cert_file" env:"TLS_CERT_FILE" usage:"PEM certificate file, reloaded when it changes"`
+	KeyFile          string   `yaml:"key_file" toml:"key_file" env:"TLS_KEY_FILE" usage:"PEM private key file of the certificate"`
+	RedirectAddress  string   `yaml:"redirect_address" toml:"redirect_address" env:"TLS_REDIRECT_ADDRESS" usage:"address of a plain HTTP listener that redirects to HTTPS and answers ACME HTTP-01 challenges"`
+	ACMEDomains      []string `yaml:"acme_domains" toml:"acme_domains" env:"ACME_DOMAINS" usage:"domains to obtain certificates for through ACME"`
+	ACMEEmail        string   `yaml:"acme_email" toml:"acme_email" env:"ACME_EMAIL" usage:"contact email of the ACME account"`
+	ACMEDirectoryURL string   `yaml:"acme_directory_url" toml:"acme_directory_url" env:"ACME_DIRECTORY_URL" usage:"directory URL of the ACME server"`
+	ACMECacheDir     string   `yaml:"acme_cache_dir" toml:"acme_cache_dir" env:"ACME_CACHE_DIR" usage:"directory ACME accounts and certificates are stored in"`
+	ACMECAFile       string   `yaml:"acme_ca_file" toml:"acme_ca_file" env:"ACME_CA_FILE" usage:"PEM file of CAs trusted for the ACME server, such as a local test server"`
+}
+
+// Enabled reports whether the server uses HTTPS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || len(c.ACMEDomains) > 0
+}
+
+// GRPCConfig configures the gRPC API.
+type GRPCConfig struct {
+	Address    string `yaml:"address" toml:"address" env:"GRPC_ADDRESS" usage:"address the gRPC server listens on, empty to disable it"`
+	Reflection bool   `yaml:"reflection" toml:"reflection" env:"GRPC_REFLECTION" usage:"serve the gRPC reflection service"`
+}
+
+// DNSServerConfig configures the DNS server that answers IP-to-ASN TXT queries in the Team Cymru format.
+type DNSServerConfig struct {
+	Address     string        `yaml:"address" toml:"address" env:"DNS_SERVER_ADDRESS" usage:"address the DNS server listens on over UDP and TCP, empty to disable it"`
+	OriginZone  string        `yaml:"origin_zone" toml:"origin_zone" env:"DNS_SERVER_ORIGIN_ZONE" usage:"zone answering reversed IPv4 addresses with their origin ASN"`
+	Origin6Zone string        `yaml:"origin6_zone" toml:"origin6_zone" env:"DNS_SERVER_ORIGIN6_ZONE" usage:"zone answering reversed IPv6 nibbles with their origin ASN"`
+	ASNZone     string        `yaml:"asn_zone" toml:"asn_zone" env:"DNS_SERVER_ASN_ZONE" usage:"zone answering ASNs such as AS15169 with their name"`
+	TTL         time.Duration `yaml:"ttl" toml:"ttl" env:"DNS_SERVER_TTL" usage:"TTL of answers"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" toml:"negative_ttl" env:"DNS_SERVER_NEGATIVE_TTL" usage:"TTL of names that do not exist"`
+}
+
+// WhoisServerConfig configures the WHOIS server that answers IP and ASN queries in the Team Cymru format.
+type WhoisServerConfig struct {
+	Address        string        `yaml:"address" toml:"address" env:"WHOIS_SERVER_ADDRESS" usage:"address the WHOIS server listens on, empty to disable it"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"WHOIS_SERVER_IDLE_TIMEOUT" usage:"maximum time to wait for the next line of a query"`
+	BulkMaxSize    int           `yaml:"bulk_max_size" toml:"bulk_max_size" env:"WHOIS_SERVER_BULK_MAX_SIZE" usage:"maximum number of queries in a bulk request"`
+	MaxConnections int           `yaml:"max_connections" toml:"max_connections" env:"WHOIS_SERVER_MAX_CONNECTIONS" usage:"maximum number of connections served at once; further ones wait to be accepted"`
+}
+
+// ProxyConfig controls which proxies are trusted to report the client IP.
+type ProxyConfig struct {
+	TrustedProxies    []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES" usage:"CIDRs or addresses of trusted proxies, none to trust no one"`
+	CloudflareIPsFile string   `yaml:"cloudflare_ips_file" toml:"cloudflare_ips_file" env:"CLOUDFLARE_IPS_FILE" usage:"file of additional trusted CIDRs, one per line"`
+	RealIPHeaders     []string `yaml:"real_ip_headers" toml:"real_ip_headers" env:"REAL_IP_HEADERS" usage:"client IP headers in priority order"`
+}
+
+// CORSConfig configures which browser origins may call the API.
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"origins allowed to call the API, such as https://*.example.com, or * for any; empty disables CORS"`
+	AllowedMethods   []string      `yaml:"allowed_methods" toml:"allowed_methods" env:"CORS_ALLOWED_METHODS" usage:"methods allowed in cross-origin requests"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" toml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" usage:"request headers allowed in cross-origin requests, or * for any"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" toml:"exposed_headers" env:"CORS_EXPOSED_HEADERS" usage:"response headers cross-origin scripts may read"`
+	MaxAge           time.Duration `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE" usage:"how long browsers may cache a preflight response"`
+	AllowCredentials bool          `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" usage:"allow cross-origin requests with cookies and HTTP authentication"`
+}
+
+// UIConfig configures the HTML pages served to browsers.
+type UIConfig struct {
+	Enabled    bool   `yaml:"enabled" toml:"enabled" env:"UI_ENABLED" usage:"serve HTML pages to requests that accept text/html"`
+	MapTileURL string `yaml:"map_tile_url" toml:"map_tile_url" env:"UI_MAP_TILE_URL" usage:"URL template of map tiles with {z}, {x} and {y}, such as https://tile.openstreetmap.org/{z}/{x}/{y}.png; empty shows a placeholder"`
+}
+
+// CacheConfig configures the lookup cache.
+type CacheConfig struct {
+	TTL time.Duration `yaml:"ttl" toml:"ttl" env:"CACHE_TTL" usage:"how long lookup results are cached"`
+}
+
+// DatabaseConfig configures the GeoIP databases and their updates.
+type DatabaseConfig struct {
+	CityPath        string        `yaml:"city_path" toml:"city_path" env:"CITY_DB_PATH" usage:"path of the city database"`
+	ASNPath         string        `yaml:"asn_path" toml:"asn_path" env:"ASN_DB_PATH" usage:"path of the ASN database"`
+	CityURL         string        `yaml:"city_url" toml:"city_url" env:"CITY_DB_URL" usage:"download URL of the gzipped city database, {month} is replaced by YYYY-MM"`
+	ASNURL          string        `yaml:"asn_url" toml:"asn_url" env:"ASN_DB_URL" usage:"download URL of the gzipped ASN database, {month} is replaced by YYYY-MM"`
+	UpdateInterval  time.Duration `yaml:"update_interval" toml:"update_interval" env:"UPDATE_INTERVAL" usage:"how often the databases are downloaded again"`
+	DownloadTimeout time.Duration `yaml:"download_timeout" toml:"download_timeout" env:"DOWNLOAD_TIMEOUT" usage:"maximum time to download a database"`
+}
+
+// DNSConfig configures the resolver used for domain lookups.
+type DNSConfig struct {
+	Resolver string        `yaml:"resolver" toml:"resolver" env:"DNS_RESOLVER" usage:"host:port of the DNS resolver"`
+	Timeout  time.Duration `yaml:"timeout" toml:"timeout" env:"DNS_TIMEOUT" usage:"maximum time for a DNS query"`
+}
+
+// WhoisConfig configures WHOIS lookups.
+type WhoisConfig struct {
+	Server          string        `yaml:"server" toml:"server" env:"WHOIS_SERVER" usage:"host or host:port of a WHOIS server to send domain queries to, empty to find the registry's server through IANA"`
+	Timeout         time.Duration `yaml:"timeout" toml:"timeout" env:"WHOIS_TIMEOUT" usage:"maximum time for a WHOIS query"`
+	FallbackTimeout time.Duration `yaml:"fallback_timeout" toml:"fallback_timeout" env:"WHOIS_FALLBACK_TIMEOUT" usage:"maximum time for a WHOIS query sent directly to the registry server"`
+}
+
+// AuthConfig configures API key authentication.
+type AuthConfig struct {
+	KeysFile  string `yaml:"keys_file" toml:"keys_file" env:"API_KEYS_FILE" usage:"YAML file of API keys and tiers, empty to disable authentication"`
+	UsageFile string `yaml:"usage_file" toml:"usage_file" env:"API_USAGE_FILE" usage:"file the API key usage counters are saved to"`
+}
+
+// RateLimitConfig configures the per-client rate limits of each route class.
+type RateLimitConfig struct {
+	IP       RateLimit `yaml:"ip" toml:"ip" env:"RATE_LIMIT_IP" usage:"rate limit of IP and network lookups"`
+	ASN      RateLimit `yaml:"asn" toml:"asn" env:"RATE_LIMIT_ASN" usage:"rate limit of ASN lookups"`
+	Domain   RateLimit `yaml:"domain" toml:"domain" env:"RATE_LIMIT_DOMAIN" usage:"rate limit of domain lookups"`
+	Bulk     RateLimit `yaml:"bulk" toml:"bulk" env:"RATE_LIMIT_BULK" usage:"rate limit of batch, stream and job requests"`
+	RedisURL string    `yaml:"redis_url" toml:"redis_url" env:"RATE_LIMIT_REDIS_URL" usage:"Redis URL to share rate limits between instances"`
+}
+
+// JobsConfig configures bulk lookup jobs.
+type JobsConfig struct {
+	Dir         string        `yaml:"dir" toml:"dir" env:"JOBS_DIR" usage:"directory job files are stored in"`
+	Workers     int           `yaml:"workers" toml:"workers" env:"JOB_WORKERS" usage:"number of jobs processed at once"`
+	QueueSize   int           `yaml:"queue_size" toml:"queue_size" env:"JOB_QUEUE_SIZE" usage:"number of jobs that may wait for a worker"`
+	Retention   time.Duration `yaml:"retention" toml:"retention" env:"JOB_RETENTION" usage:"how long finished jobs are kept"`
+	MaxUploadMB int           `yaml:"max_upload_mb" toml:"max_upload_mb" env:"JOB_MAX_UPLOAD_MB" usage:"maximum upload size in megabytes"`
+}
+
+// RateLimit allows Limit requests per Window; the zero value disables the limit.
+// It is written as <requests>/<window>, such as 60/1m, or 0.
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// UnmarshalText parses a rate limit such as 60/1m.
+func (l *RateLimit) UnmarshalText(text []byte) error {
+	value := string(text)
+	if value == "0" {
+		*l = RateLimit{}
+		return nil
+	}
+	count, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return fmt.Errorf("expected <requests>/<window>: %s", value)
+	}
+	limit, err := strconv.Atoi(count)
+	if err != nil || limit < 0 {
+		return fmt.Errorf("invalid request count: %s", count)
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("invalid window: %s", window)
+	}
+	*l = RateLimit{Limit: limit, Window: duration}
+	return nil
+}
+
+// MarshalText formats the rate limit the way UnmarshalText reads it.
+func (l RateLimit) MarshalText() ([]byte, error) {
+	if l.Limit == 0 {
+		return []byte("0"), nil
+	}
+	return []byte(fmt.Sprintf("%d/%s", l.Limit, l.Window)), nil
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address:            ":3000",
+			ReadTimeout:        10 * time.Second,
+			WriteTimeout:       10 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			ShutdownTimeout:    5 * time.Second,
+			BatchMaxSize:       100,
+			CompressionMinSize: 1024,
+			MetricsAddress:     ":9090",
+		},
+		Log: LogConfig{
+			Format:             "text",
+			Level:              slog.LevelInfo,
+			AccessLevel:        slog.LevelInfo,
+			AccessSampleRate:   1,
+			AccessExcludePaths: []string{"/favicon.ico"},
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRate:  1,
+			ServiceName: "ipinfo",
+		},
+		TLS: TLSConfig{
+			ACMEDirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+			ACMECacheDir:     "acme",
+		},
+		GRPC: GRPCConfig{
+			Reflection: true,
+		},
+		DNSServer: DNSServerConfig{
+			OriginZone:  "origin.asn.example",
+			Origin6Zone: "origin6.asn.example",
+			ASNZone:     "asn.example",
+			TTL:         time.Hour,
+			NegativeTTL: 5 * time.Minute,
+		},
+		WhoisServer: WhoisServerConfig{
+			IdleTimeout:    30 * time.Second,
+			BulkMaxSize:    100000,
+			MaxConnections: 256,
+		},
+		Proxy: ProxyConfig{
+			TrustedProxies: []string{"127.0.0.0/8", "::1/128"},
+			RealIPHeaders:  []string{"CF-Connecting-IP", "X-Real-IP", "X-Forwarded-For"},
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "DELETE"},
+			AllowedHeaders: []string{"Accept", "Accept-Language", "Authorization", "Content-Type", "If-None-Match", "X-API-Key"},
+			ExposedHeaders: []string{"ETag", "Location", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "X-Request-ID"},
+			MaxAge:         10 * time.Minute,
+		},
+		UI: UIConfig{
+			Enabled: true,
+		},
+		Cache: CacheConfig{
+			TTL: 10 * time.Minute,
+		},
+		Database: DatabaseConfig{
+			CityPath:        "dbip-city-lite.mmdb",
+			ASNPath:         "dbip-asn-lite.mmdb",
+			CityURL:         "https://download.db-ip.com/free/dbip-city-lite-{month}.mmdb.gz",
+			ASNURL:          "https://download.db-ip.com/free/dbip-asn-lite-{month}.mmdb.gz",
+			UpdateInterval:  24 * time.Hour,
+			DownloadTimeout: 5 * time.Minute,
+		},
+		DNS: DNSConfig{
+			Resolver: "1.1.1.1:53",
+			Timeout:  2 * time.Second,
+		},
+		Whois: WhoisConfig{
+			Timeout:         5 * time.Second,
+			FallbackTimeout: 10 * time.Second,
+		},
+		Auth: AuthConfig{
+			UsageFile: "api-usage.json",
+		},
+		RateLimit: RateLimitConfig{
+			IP:     RateLimit{Limit: 600, Window: time.Minute},
+			ASN:    RateLimit{Limit: 600, Window: time.Minute},
+			Domain: RateLimit{Limit: 30, Window: time.Minute},
+			Bulk:   RateLimit{Limit: 60, Window: time.Minute},
+		},
+		Jobs: JobsConfig{
+			Dir:         "jobs",
+			Workers:     2,
+			QueueSize:   100,
+			Retention:   24 * time.Hour,
+			MaxUploadMB: 100,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file, the environment and the
+// command-line flags, each overriding the one before, and validates it. The config file is
+// named by the --config flag or CONFIG_FILE. rest holds the arguments after the flags, and
+// printConfig reports whether --print-config was given.
+func Load(args []string) (cfg *Config, rest []string, printConfig bool, err error) {
+	path := os.Getenv("CONFIG_FILE")
+
+	// The flags are parsed once up front to find the config file, and again at the end so they win.
+	if err := newFlagSet(Default(), &path, &printConfig).Parse(args); err != nil {
+		return nil, nil, false, err
+	}
+
+	cfg = Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, nil, false, fmt.Errorf("loading config file %s: %w", path, err)
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, false, err
+	}
+	fs := newFlagSet(cfg, &path, &printConfig)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, false, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, false, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, fs.Args(), printConfig, nil
+}
+
+// loadFile reads a YAML or TOML config file, chosen by its extension. Unknown keys are an error.
+func (c *Config) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(strings.NewReader(string(raw)))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(raw), c)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown key %s", undecoded[0])
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	return nil
+}
+
+// loadEnv overrides every setting whose environment variable is set and not empty.
+func (c *Config) loadEnv() error {
+	for _, s := range c.settings() {
+		value, ok := os.LookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.Set(value); err != nil {
+			return fmt.Errorf("invalid %s: %w", s.env, err)
+		}
+	}
+	return nil
+}
+
+// newFlagSet creates the command-line flags, which write straight into cfg.
+func newFlagSet(cfg *Config, path *string, printConfig *bool) *flag.FlagSet {
+	fs := flag.NewFlagSet("ipinfo", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ipinfo [flags] [lookup|asn|domain [arguments]]\n\nWithout a command, the server is started.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(path, "config", *path, "YAML or TOML config file (env CONFIG_FILE)")
+	fs.BoolVar(printConfig, "print-config", false, "print the effective configuration as YAML and exit")
+	for _, s := range cfg.settings() {
+		fs.Var(s, s.flag(), fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	return fs
+}
+
+// Validate reports every setting that is out of range.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.Server.Address)
+	check(err == nil, "server.address: %q is not host:port", c.Server.Address)
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.BatchMaxSize > 0, "server.batch_max_size must be positive")
+	check(c.Server.CompressionMinSize >= 0, "server.compression_min_size must not be negative")
+
+	check(c.Log.Format == "text" || c.Log.Format == "json", "log.format: %q is not text or json", c.Log.Format)
+	check(c.Log.AccessSampleRate >= 0 && c.Log.AccessSampleRate <= 1, "log.access_sample_rate must be between 0 and 1")
+
+	check(c.Tracing.Exporter == "none" || c.Tracing.Exporter == "stdout" || c.Tracing.Exporter == "otlp",
+		"tracing.exporter: %q is not none, stdout or otlp", c.Tracing.Exporter)
+	if c.Tracing.OTLPEndpoint != "" {
+		_, _, err = net.SplitHostPort(c.Tracing.OTLPEndpoint)
+		check(err == nil, "tracing.otlp_endpoint: %q is not host:port", c.Tracing.OTLPEndpoint)
+	}
+	check(c.Tracing.SampleRate >= 0 && c.Tracing.SampleRate <= 1, "tracing.sample_rate must be between 0 and 1")
+	check(c.Tracing.ServiceName != "", "tracing.service_name is required")
+
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	check(c.TLS.CertFile == "" || len(c.TLS.ACMEDomains) == 0, "tls.cert_file and tls.acme_domains cannot be used together")
+	if len(c.TLS.ACMEDomains) > 0 {
+		check(validURL(c.TLS.ACMEDirectoryURL), "tls.acme_directory_url: %q is not an http(s) URL", c.TLS.ACMEDirectoryURL)
+		check(c.TLS.ACMECacheDir != "", "tls.acme_cache_dir is required with tls.acme_domains")
+	}
+	if c.TLS.RedirectAddress != "" {
+		_, _, err = net.SplitHostPort(c.TLS.RedirectAddress)
+		check(err == nil, "tls.redirect_address: %q is not host:port", c.TLS.RedirectAddress)
+		check(c.TLS.Enabled(), "tls.redirect_address requires tls.cert_file or tls.acme_domains")
+		check(c.TLS.RedirectAddress != c.Server.Address, "tls.redirect_address must differ from server.address")
+	}
+
+	if c.Server.MetricsAddress != "" {
+		_, _, err = net.SplitHostPort(c.Server.MetricsAddress)
+		check(err == nil, "server.metrics_address: %q is not host:port", c.Server.MetricsAddress)
+		check(c.Server.MetricsAddress != c.Server.Address && c.Server.MetricsAddress != c.TLS.RedirectAddress,
+			"server.metrics_address must differ from server.address and tls.redirect_address")
+	}
+
+	if c.GRPC.Address != "" {
+		_, _, err = net.SplitHostPort(c.GRPC.Address)
+		check(err == nil, "grpc.address: %q is not host:port", c.GRPC.Address)
+		check(c.GRPC.Address != c.Server.Address && c.GRPC.Address != c.TLS.RedirectAddress && c.GRPC.Address != c.Server.MetricsAddress,
+			"grpc.address must differ from server.address, server.metrics_address and tls.redirect_address")
+	}
+
+	if c.DNSServer.Address != "" {
+		_, _, err = net.SplitHostPort(c.DNSServer.Address)
+		check(err == nil, "dns_server.address: %q is not host:port", c.DNSServer.Address)
+		check(validZone(c.DNSServer.OriginZone), "dns_server.origin_zone: %q is not a domain name", c.DNSServer.OriginZone)
+		check(validZone(c.DNSServer.Origin6Zone), "dns_server.origin6_zone: %q is not a domain name", c.DNSServer.Origin6Zone)
+		check(validZone(c.DNSServer.ASNZone), "dns_server.asn_zone: %q is not a domain name", c.DNSServer.ASNZone)
+		check(c.DNSServer.Address != c.Server.Address && c.DNSServer.Address != c.Server.MetricsAddress &&
+			c.DNSServer.Address != c.GRPC.Address && c.DNSServer.Address != c.TLS.RedirectAddress,
+			"dns_server.address must differ from server.address, server.metrics_address, grpc.address and tls.redirect_address")
+		check(c.DNSServer.OriginZone != c.DNSServer.Origin6Zone && c.DNSServer.OriginZone != c.DNSServer.ASNZone &&
+			c.DNSServer.Origin6Zone != c.DNSServer.ASNZone, "dns_server zones must differ")
+		check(c.DNSServer.TTL > 0, "dns_server.ttl must be positive")
+		check(c.DNSServer.NegativeTTL > 0, "dns_server.negative_ttl must be positive")
+	}
+
+	if c.WhoisServer.Address != "" {
+		_, _, err = net.SplitHostPort(c.WhoisServer.Address)
+		check(err == nil, "whois_server.address: %q is not host:port", c.WhoisServer.Address)
+		check(c.WhoisServer.Address != c.Server.Address && c.WhoisServer.Address != c.Server.MetricsAddress && c.WhoisServer.Address != c.GRPC.Address &&
+			c.WhoisServer.Address != c.TLS.RedirectAddress && c.WhoisServer.Address != c.DNSServer.Address,
+			"whois_server.address must differ from server.address, server.metrics_address, grpc.address, tls.redirect_address and dns_server.address")
+		check(c.WhoisServer.IdleTimeout > 0, "whois_server.idle_timeout must be positive")
+		check(c.WhoisServer.BulkMaxSize > 0, "whois_server.bulk_max_size must be positive")
+		check(c.WhoisServer.MaxConnections > 0, "whois_server.max_connections must be positive")
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(validOrigin(origin), "cors.allowed_origins: %q is not an origin such as https://example.com or https://*.example.com", origin)
+		check(origin != "*" || !c.CORS.AllowCredentials, "cors.allow_credentials cannot be used with the * origin")
+	}
+	check(len(c.CORS.AllowedOrigins) == 0 || len(c.CORS.AllowedMethods) > 0, "cors.allowed_methods is required with cors.allowed_origins")
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+
+	if c.UI.MapTileURL != "" {
+		check(validURL(c.UI.MapTileURL) && strings.Contains(c.UI.MapTileURL, "{z}") &&
+			strings.Contains(c.UI.MapTileURL, "{x}") && strings.Contains(c.UI.MapTileURL, "{y}"),
+			"ui.map_tile_url: %q is not an http(s) URL with {z}, {x} and {y}", c.UI.MapTileURL)
+	}
+
+	check(c.Cache.TTL > 0, "cache.ttl must be positive")
+
+	check(c.Database.CityPath != "", "database.city_path is required")
+	check(c.Database.ASNPath != "", "database.asn_path is required")
+	check(c.Database.CityPath != c.Database.ASNPath, "database.city_path and database.asn_path must differ")
+	check(validURL(c.Database.CityURL), "database.city_url: %q is not an http(s) URL", c.Database.CityURL)
+	check(validURL(c.Database.ASNURL), "database.asn_url: %q is not an http(s) URL", c.Database.ASNURL)
+	check(c.Database.UpdateInterval > 0, "database.update_interval must be positive")
+	check(c.Database.DownloadTimeout > 0, "database.download_timeout must be positive")
+
+	_, _, err = net.SplitHostPort(c.DNS.Resolver)
+	check(err == nil, "dns.resolver: %q is not host:port", c.DNS.Resolver)
+	check(c.DNS.Timeout > 0, "dns.timeout must be positive")
+
+	check(c.Whois.Timeout > 0, "whois.timeout must be positive")
+	check(c.Whois.FallbackTimeout > 0, "whois.fallback_timeout must be positive")
+
+	check(c.Auth.UsageFile != "", "auth.usage_file is required")
+
+	if c.RateLimit.RedisURL != "" {
+		u, err := url.Parse(c.RateLimit.RedisURL)
+		check(err == nil && (u.Scheme == "redis" || u.Scheme == "rediss" || u.Scheme == "unix"),
+			"rate_limit.redis_url is not a redis://, rediss:// or unix:// URL")
+	}
+
+	check(c.Jobs.Dir != "", "jobs.dir is required")
+	check(c.Jobs.Workers > 0, "jobs.workers must be positive")
+	check(c.Jobs.QueueSize > 0, "jobs.queue_size must be positive")
+	check(c.Jobs.Retention > 0, "jobs.retention must be positive")
+	check(c.Jobs.MaxUploadMB > 0, "jobs.max_upload_mb must be positive")
+
+	return errors.Join(errs...)
+}
+
+// validZone reports whether zone is a domain name below the root.
+func validZone(zone string) bool {
+	_, ok := dns.IsDomainName(zone)
+	return ok && strings.Trim(zone, ".") != ""
+}
+
+// validOrigin reports whether value is *, or a scheme and host with an optional port, where the host
+// may start with a *. wildcard for any subdomain.
+func validOrigin(value string) bool {
+	if value == "*" {
+		return true
+	}
+	u, err := url.Parse(strings.Replace(value, "://*.", "://wildcard.", 1))
+	return err == nil && u.Scheme != "" && u.Host != "" && u.Path == "" && u.RawQuery == "" && u.User == nil
+}
+
+// validURL reports whether value is an absolute http or https URL.
+func validURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Print writes the configuration as YAML in the layout of a config file.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.node()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
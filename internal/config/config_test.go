@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestLoadFlags(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	cfg, rest, printConfig, err := Load([]string{"--tracing.otlp-insecure", "--grpc.reflection=false", "--print-config", "--server.batch-max-size", "50", "lookup", "8.8.8.8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Tracing.OTLPInsecure || cfg.GRPC.Reflection || cfg.Server.BatchMaxSize != 50 {
+		t.Errorf("otlp_insecure %t, reflection %t, batch_max_size %d", cfg.Tracing.OTLPInsecure, cfg.GRPC.Reflection, cfg.Server.BatchMaxSize)
+	}
+	if !printConfig {
+		t.Error("--print-config was not reported")
+	}
+	if len(rest) != 2 || rest[0] != "lookup" || rest[1] != "8.8.8.8" {
+		t.Errorf("rest %q, want the command and its argument", rest)
+	}
+
+	// A bare boolean flag takes no value, so the next argument is not consumed.
+	cfg, rest, _, err = Load([]string{"--grpc.reflection", "lookup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GRPC.Reflection || len(rest) != 1 {
+		t.Errorf("reflection %t, rest %q", cfg.GRPC.Reflection, rest)
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting is a single configuration value together with its file key, environment variable and description.
+// It implements flag.Value so it can be bound to a command-line flag.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	value reflect.Value
+}
+
+// settings lists every configurable value of c in file order.
+func (c *Config) settings() []*setting {
+	var out []*setting
+	walkSettings(reflect.ValueOf(c).Elem(), "", &out)
+	return out
+}
+
+// walkSettings collects the settings of a config section, descending into nested sections.
+func walkSettings(section reflect.Value, prefix string, out *[]*setting) {
+	for i := range section.NumField() {
+		field := section.Type().Field(i)
+		key := prefix + field.Tag.Get("yaml")
+		value := section.Field(i)
+		if field.Type.Kind() == reflect.Struct && !isText(value) {
+			walkSettings(value, key+".", out)
+			continue
+		}
+		*out = append(*out, &setting{
+			key:   key,
+			env:   field.Tag.Get("env"),
+			usage: field.Tag.Get("usage"),
+			value: value,
+		})
+	}
+}
+
+// isText reports whether a value reads and writes itself as text, like RateLimit.
+func isText(value reflect.Value) bool {
+	_, ok := value.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// flag returns the command-line flag name of the setting, such as server.read-timeout.
+func (s *setting) flag() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// IsBoolFlag lets boolean settings be given as a bare flag, such as --grpc.reflection, the way the
+// flag package treats its own booleans.
+func (s *setting) IsBoolFlag() bool {
+	return s.value.Kind() == reflect.Bool
+}
+
+// Set parses a value given as an environment variable or flag. Lists are comma-separated.
+func (s *setting) Set(text string) error {
+	if u, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(text))
+	}
+
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(text)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		s.value.SetFloat(f)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// String formats the value the way Set reads it.
+func (s *setting) String() string {
+	// The flag package calls String on a zero setting to detect default values.
+	if !s.value.IsValid() {
+		return ""
+	}
+	if m, ok := s.value.Interface().(encoding.TextMarshaler); ok {
+		text, _ := m.MarshalText()
+		return string(text)
+	}
+	switch {
+	case s.value.Type() == durationType:
+		return time.Duration(s.value.Int()).String()
+	case s.value.Kind() == reflect.Slice:
+		return strings.Join(s.value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(s.value.Interface())
+}
+
+// yamlNode converts the setting to a YAML node, keeping numbers and booleans unquoted.
+func (s *setting) yamlNode() *yaml.Node {
+	if s.value.Kind() == reflect.Slice {
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range s.value.Interface().([]string) {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+		return node
+	}
+
+	tag := "!!str"
+	if s.value.Type() != durationType && !isText(s.value) {
+		switch s.value.Kind() {
+		case reflect.Int:
+			tag = "!!int"
+		case reflect.Float64:
+			// Left to the encoder, which would otherwise mark whole numbers as floats explicitly.
+			tag = ""
+		case reflect.Bool:
+			tag = "!!bool"
+		}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: s.String()}
+}
+
+// node builds the YAML document of the configuration, with sections in the order they are declared.
+func (c *Config) node() *yaml.Node {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+	for _, s := range c.settings() {
+		parent := root
+		section, name, nested := strings.Cut(s.key, ".")
+		if nested {
+			if sections[section] == nil {
+				sections[section] = &yaml.Node{Kind: yaml.MappingNode}
+				root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, sections[section])
+			}
+			parent = sections[section]
+		} else {
+			name = section
+		}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, s.yamlNode())
+	}
+	return root
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"net"
+	"time"
 
 	"github.com/oschwald/maxminddb-golang"
 )
@@ -26,3 +27,23 @@ func (g *GeoIPManager) GetASNPrefixes(asn uint) []*net.IPNet {
 	defer g.mu.RUnlock()
 	return g.asnPrefixMap[asn]
 }
+
+// BuildEpochs returns the build times of the city and ASN databases as Unix timestamps.
+func (g *GeoIPManager) BuildEpochs() (city, asn uint) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.cityDB != nil {
+		city = g.cityDB.Metadata.BuildEpoch
+	}
+	if g.asnDB != nil {
+		asn = g.asnDB.Metadata.BuildEpoch
+	}
+	return city, asn
+}
+
+// NextUpdate returns when the updater next downloads the databases, or the zero time if it is not running.
+func (g *GeoIPManager) NextUpdate() time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.nextUpdate
+}
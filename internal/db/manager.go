@@ -8,6 +8,9 @@ import (
 	"sync"
 	"time"
 
+	"ipinfo/internal/config"
+	"ipinfo/internal/metrics"
+
 	"github.com/oschwald/maxminddb-golang"
 )
 
@@ -17,13 +20,16 @@ type GeoIPManager struct {
 	asnDB        *maxminddb.Reader
 	asnPrefixMap map[uint][]*net.IPNet
 	httpClient   *http.Client
+	config       config.DatabaseConfig
+	nextUpdate   time.Time
 	mu           sync.RWMutex
 }
 
 // NewGeoIPManager creates a new GeoIPManager
-func NewGeoIPManager() (*GeoIPManager, error) {
+func NewGeoIPManager(cfg config.DatabaseConfig) (*GeoIPManager, error) {
 	manager := &GeoIPManager{
-		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		httpClient: &http.Client{Timeout: cfg.DownloadTimeout},
+		config:     cfg,
 	}
 	if err := manager.Initialize(); err != nil {
 		return nil, fmt.Errorf("initializing geoip manager: %w", err)
@@ -31,11 +37,30 @@ func NewGeoIPManager() (*GeoIPManager, error) {
 	return manager, nil
 }
 
+// OpenGeoIPManager opens the existing database files without downloading missing ones, for use
+// without the updater.
+func OpenGeoIPManager(cfg config.DatabaseConfig) (*GeoIPManager, error) {
+	manager := &GeoIPManager{config: cfg}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for _, path := range []string{cfg.CityPath, cfg.ASNPath} {
+		if err := manager.openDB(path); err != nil {
+			if manager.cityDB != nil {
+				manager.cityDB.Close()
+			}
+			return nil, fmt.Errorf("opening database %s: %w", path, err)
+		}
+	}
+	manager.buildASNPrefixMap()
+	return manager, nil
+}
+
 // Initialize initializes the GeoIPManager by opening the database files.
 func (g *GeoIPManager) Initialize() error {
 	g.mu.Lock()
-	cityErr := g.openDB(CityDBPath)
-	asnErr := g.openDB(ASNDBPath)
+	cityErr := g.openDB(g.config.CityPath)
+	asnErr := g.openDB(g.config.ASNPath)
 	g.mu.Unlock()
 
 	if cityErr != nil || asnErr != nil {
@@ -45,6 +70,7 @@ func (g *GeoIPManager) Initialize() error {
 		}
 	} else {
 		g.mu.Lock()
+		g.recordDatabaseMetrics()
 		g.buildASNPrefixMap()
 		g.mu.Unlock()
 	}
@@ -75,7 +101,7 @@ func (g *GeoIPManager) openDB(path string) error {
 		return err
 	}
 
-	if path == CityDBPath {
+	if path == g.config.CityPath {
 		g.cityDB = db
 	} else {
 		g.asnDB = db
@@ -104,5 +130,23 @@ func (g *GeoIPManager) buildASNPrefixMap() {
 			g.asnPrefixMap[record.AutonomousSystemNumber] = append(g.asnPrefixMap[record.AutonomousSystemNumber], subnet)
 		}
 	}
-	slog.Info("finished building asn prefix map", "duration", time.Since(startTime))
+	duration := time.Since(startTime)
+	prefixes := 0
+	for _, subnets := range g.asnPrefixMap {
+		prefixes += len(subnets)
+	}
+	metrics.ASNPrefixMapASNs.Set(float64(len(g.asnPrefixMap)))
+	metrics.ASNPrefixMapPrefixes.Set(float64(prefixes))
+	metrics.ASNPrefixMapBuildDuration.Set(duration.Seconds())
+	slog.Info("finished building asn prefix map", "duration", duration)
+}
+
+// recordDatabaseMetrics exports the build time of the loaded databases.
+func (g *GeoIPManager) recordDatabaseMetrics() {
+	if g.cityDB != nil {
+		metrics.DatabaseBuildEpoch.WithLabelValues(CityDBName).Set(float64(g.cityDB.Metadata.BuildEpoch))
+	}
+	if g.asnDB != nil {
+		metrics.DatabaseBuildEpoch.WithLabelValues(ASNDBName).Set(float64(g.asnDB.Metadata.BuildEpoch))
+	}
 }
@@ -2,13 +2,10 @@ package db
 
 import "errors"
 
-// Constants for database names and paths
+// Constants for database names
 const (
-	CityDBName  = "dbip-city-lite"
-	ASNDBName   = "dbip-asn-lite"
-	DBExtension = ".mmdb"
-	CityDBPath  = CityDBName + DBExtension
-	ASNDBPath   = ASNDBName + DBExtension
+	CityDBName = "dbip-city-lite"
+	ASNDBName  = "dbip-asn-lite"
 )
 
 // Error messages
@@ -22,3 +19,31 @@ type ASNRecord struct {
 	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
 	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
 }
+
+// CityRecord represents a record in the city database
+type CityRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Continent struct {
+		Code  string            `maxminddb:"code"`
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"continent"`
+	Country struct {
+		IsoCode           string            `maxminddb:"iso_code"`
+		IsInEuropeanUnion bool              `maxminddb:"is_in_european_union"`
+		Names             map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Location struct {
+		AccuracyRadius uint16  `maxminddb:"accuracy_radius"`
+		Latitude       float64 `maxminddb:"latitude"`
+		Longitude      float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+	Subdivisions []struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+}
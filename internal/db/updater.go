@@ -8,8 +8,11 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"ipinfo/internal/metrics"
+
 	"github.com/oschwald/maxminddb-golang"
 )
 
@@ -17,10 +20,12 @@ import (
 func (g *GeoIPManager) StartUpdater(ctx context.Context, updateInterval time.Duration) {
 	slog.Info("starting database updater", "interval", updateInterval.String())
 	ticker := time.NewTicker(updateInterval)
+	g.setNextUpdate(time.Now().Add(updateInterval))
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
+				g.setNextUpdate(time.Now().Add(updateInterval))
 				slog.Info("performing scheduled database update")
 				if err := g.UpdateDatabases(); err != nil {
 					slog.Error("failed to update databases", "err", err)
@@ -34,10 +39,18 @@ func (g *GeoIPManager) StartUpdater(ctx context.Context, updateInterval time.Dur
 	}()
 }
 
+// setNextUpdate records when the updater runs next.
+func (g *GeoIPManager) setNextUpdate(t time.Time) {
+	g.mu.Lock()
+	g.nextUpdate = t
+	g.mu.Unlock()
+}
+
 // UpdateDatabases downloads new databases and reloads them into the manager.
 func (g *GeoIPManager) UpdateDatabases() error {
 	tmpFiles, err := g.downloadToTemp(context.Background())
 	if err != nil {
+		metrics.DatabaseUpdateFailures.Inc()
 		return err
 	}
 
@@ -59,37 +72,44 @@ func (g *GeoIPManager) UpdateDatabases() error {
 		}
 	}
 
-	var openErr error
-	g.cityDB, openErr = maxminddb.Open(CityDBPath)
-	if openErr != nil {
-		slog.Error("failed to reopen city database", "err", openErr)
+	var cityErr, asnErr error
+	g.cityDB, cityErr = maxminddb.Open(g.config.CityPath)
+	if cityErr != nil {
+		slog.Error("failed to reopen city database", "err", cityErr)
 	}
 
-	g.asnDB, openErr = maxminddb.Open(ASNDBPath)
-	if openErr != nil {
-		slog.Error("failed to reopen asn database", "err", openErr)
+	g.asnDB, asnErr = maxminddb.Open(g.config.ASNPath)
+	if asnErr != nil {
+		slog.Error("failed to reopen asn database", "err", asnErr)
 	}
 
+	g.recordDatabaseMetrics()
 	g.buildASNPrefixMap()
+	if cityErr != nil || asnErr != nil {
+		metrics.DatabaseUpdateFailures.Inc()
+		return nil
+	}
+
+	metrics.DatabaseLastUpdate.SetToCurrentTime()
 	slog.Info("successfully updated and reloaded databases")
 	return nil
 }
 
-// downloadToTemp downloads the current month's DB-IP databases to temporary files.
+// downloadToTemp downloads the current month's databases to temporary files.
 func (g *GeoIPManager) downloadToTemp(ctx context.Context) (map[string]string, error) {
 	now := time.Now()
 	dateStr := now.Format("2006-01")
 
 	targets := map[string]string{
-		CityDBPath: fmt.Sprintf("dbip-city-lite-%s", dateStr),
-		ASNDBPath:  fmt.Sprintf("dbip-asn-lite-%s", dateStr),
+		g.config.CityPath: g.config.CityURL,
+		g.config.ASNPath:  g.config.ASNURL,
 	}
 
 	results := make(map[string]string)
 	var firstError error
 
-	for localPath, urlName := range targets {
-		downloadURL := fmt.Sprintf("https://download.db-ip.com/free/%s.mmdb.gz", urlName)
+	for localPath, urlTemplate := range targets {
+		downloadURL := strings.ReplaceAll(urlTemplate, "{month}", dateStr)
 		tmpPath := localPath + ".tmp"
 
 		if err := g.downloadFile(ctx, downloadURL, tmpPath); err != nil {
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes every metric exported by the service.
+const namespace = "ipinfo"
+
+// HTTP metrics, labeled by route class, method and status code.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests handled.",
+	}, []string{"route", "method", "code"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+)
+
+// Cache metrics.
+var (
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of cache lookups by result (hit or miss).",
+	}, []string{"result"})
+)
+
+// Upstream metrics for WHOIS servers and DNS resolvers.
+var (
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Time taken by upstream WHOIS and DNS queries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"upstream"})
+
+	UpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Number of failed upstream WHOIS and DNS queries.",
+	}, []string{"upstream"})
+)
+
+// GeoIP database metrics.
+var (
+	DatabaseBuildEpoch = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "database_build_timestamp_seconds",
+		Help:      "Build time of the loaded GeoIP database as a Unix timestamp.",
+	}, []string{"database"})
+
+	DatabaseLastUpdate = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "database_last_update_success_timestamp_seconds",
+		Help:      "Time of the last successful database update as a Unix timestamp.",
+	})
+
+	DatabaseUpdateFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "database_update_failures_total",
+		Help:      "Number of failed database updates.",
+	})
+
+	ASNPrefixMapASNs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "asn_prefix_map_asns",
+		Help:      "Number of ASNs in the ASN prefix map.",
+	})
+
+	ASNPrefixMapPrefixes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "asn_prefix_map_prefixes",
+		Help:      "Number of prefixes in the ASN prefix map.",
+	})
+
+	ASNPrefixMapBuildDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "asn_prefix_map_build_duration_seconds",
+		Help:      "Time taken by the last build of the ASN prefix map.",
+	})
+)
+
+// RegisterCacheEntries exports the number of cached entries as reported by entries.
+func RegisterCacheEntries(entries func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_entries",
+		Help:      "Number of entries in the lookup cache.",
+	}, entries)
+}
+
+// ObserveUpstream records the duration of an upstream query started at start and counts it as failed if err is set.
+// Not-found DNS answers are expected and are not counted as errors.
+func ObserveUpstream(upstream string, start time.Time, err error) {
+	UpstreamDuration.WithLabelValues(upstream).Observe(time.Since(start).Seconds())
+
+	var dnsErr *net.DNSError
+	if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		UpstreamErrors.WithLabelValues(upstream).Inc()
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"ipinfo/internal/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the ID of a request, taken from the client or a proxy in front of the service
+// or generated, and is echoed in the response.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps the length of a propagated request ID.
+const maxRequestIDLength = 128
+
+// accessLogContextKey is the context key of the accessLogEntry of a request.
+type accessLogContextKey struct{}
+
+// accessLogEntry collects what handlers report about a request for its access log entry.
+type accessLogEntry struct {
+	requestID string
+	cache     string
+}
+
+// accessLogger writes one structured log entry per HTTP request, DNS query or WHOIS connection.
+type accessLogger struct {
+	level      slog.Level
+	sampleRate float64
+	exclude    []string
+}
+
+// newAccessLogger creates an access logger with the configured level, sampling and excluded paths.
+func newAccessLogger(cfg config.LogConfig) *accessLogger {
+	return &accessLogger{
+		level:      cfg.AccessLevel,
+		sampleRate: cfg.AccessSampleRate,
+		exclude:    cfg.AccessExcludePaths,
+	}
+}
+
+// middleware assigns the request its ID and logs it once it has been served.
+func (l *accessLogger) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := &accessLogEntry{requestID: r.Header.Get(requestIDHeader)}
+		if !validRequestID(entry.requestID) {
+			entry.requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, entry.requestID)
+		r = r.WithContext(context.WithValue(r.Context(), accessLogContextKey{}, entry))
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		duration := time.Since(start)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		failed := recorder.status >= http.StatusInternalServerError
+		if !failed && slices.Contains(l.exclude, r.URL.Path) {
+			return
+		}
+		level, ok := l.entryLevel(r.Context(), failed)
+		if !ok {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", entry.requestID),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", routeLabel(r)),
+			slog.Int("status", recorder.status),
+			slog.Int("size", recorder.size),
+			slog.Duration("duration", duration),
+			slog.String("client", GetRealIP(r)),
+			slog.String("user_agent", r.UserAgent()),
+		}
+		if entry.cache != "" {
+			attrs = append(attrs, slog.String("cache", entry.cache))
+		}
+		if span := trace.SpanContextFromContext(r.Context()); span.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", span.TraceID().String()))
+		}
+		slog.LogAttrs(r.Context(), level, "http request", attrs...)
+	})
+}
+
+// entryLevel returns the level of an access log entry and whether it is written. Failed requests are
+// logged at error level and never sampled out.
+func (l *accessLogger) entryLevel(ctx context.Context, failed bool) (slog.Level, bool) {
+	level := l.level
+	if failed {
+		level = slog.LevelError
+	} else if l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+		return level, false
+	}
+	return level, slog.Default().Enabled(ctx, level)
+}
+
+// setCacheHit records in the access log entry whether the response was served from the lookup cache.
+func setCacheHit(r *http.Request, hit bool) {
+	entry, ok := r.Context().Value(accessLogContextKey{}).(*accessLogEntry)
+	if !ok {
+		return
+	}
+	entry.cache = "miss"
+	if hit {
+		entry.cache = "hit"
+	}
+}
+
+// validRequestID reports whether a propagated request ID is short and printable, so it is safe to
+// echo and log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID.
+func newRequestID() string {
+	return fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64())
+}
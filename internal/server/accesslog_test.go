@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+
+	"github.com/miekg/dns"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// syncBuffer is a bytes.Buffer that log handlers on several goroutines can write to.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// take returns what was logged so far and clears it.
+func (b *syncBuffer) take() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.buf.Reset()
+	return b.buf.String()
+}
+
+// captureLogs sends the default logger to a buffer at info level for the rest of the test.
+func captureLogs(t *testing.T) *syncBuffer {
+	buf := &syncBuffer{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return buf
+}
+
+// dnsRecorder is a dns.ResponseWriter that keeps the response.
+type dnsRecorder struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (w *dnsRecorder) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5353}
+}
+
+func (w *dnsRecorder) WriteMsg(msg *dns.Msg) error {
+	w.msg = msg
+	return nil
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	logs := captureLogs(t)
+	geoIP := newTestGeoIP(t)
+
+	tests := []struct {
+		name       string
+		level      slog.Level
+		sampleRate float64
+		want       string
+	}{
+		{"default", slog.LevelInfo, 1, "level=INFO"},
+		{"raised level", slog.LevelWarn, 1, "level=WARN"},
+		{"below the log level", slog.LevelDebug, 1, ""},
+		{"sampled out", slog.LevelInfo, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default().Log
+			cfg.AccessLevel = tt.level
+			cfg.AccessSampleRate = tt.sampleRate
+			access := newAccessLogger(cfg)
+
+			check := func(kind, entry string) {
+				t.Helper()
+				if tt.want == "" && entry != "" {
+					t.Errorf("%s logged %q", kind, entry)
+				}
+				if tt.want != "" && !strings.Contains(entry, tt.want) {
+					t.Errorf("%s entry %q, want %s", kind, entry, tt.want)
+				}
+			}
+
+			f := newDNSFrontend(geoIP, config.Default().DNSServer, access)
+			msg := new(dns.Msg)
+			msg.SetQuestion("8.8.8.8.origin.asn.example.", dns.TypeTXT)
+			f.ServeDNS(&dnsRecorder{}, msg)
+			check("dns query", logs.take())
+
+			whois := newWhoisServer(geoIP, config.Default().WhoisServer, access)
+			client, conn := net.Pipe()
+			whois.wg.Add(1)
+			whois.slots <- struct{}{}
+			go whois.serve(conn)
+			_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := io.WriteString(client, "8.8.8.8\n"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.ReadAll(client); err != nil {
+				t.Fatal(err)
+			}
+			whois.wg.Wait()
+			check("whois request", logs.take())
+
+			access.logGRPCCall(context.Background(), "/ipinfo.v1.IPInfoService/Lookup", "id", status.Error(codes.NotFound, "not found"), time.Millisecond)
+			check("grpc request", logs.take())
+
+			// Server errors are always logged, at error level.
+			access.logGRPCCall(context.Background(), "/ipinfo.v1.IPInfoService/Lookup", "id", status.Error(codes.Internal, "broken"), time.Millisecond)
+			if entry := logs.take(); !strings.Contains(entry, "level=ERROR") {
+				t.Errorf("failed grpc call logged %q, want an error entry", entry)
+			}
+		})
+	}
+}
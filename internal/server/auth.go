@@ -0,0 +1,431 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ipinfo/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// usageSaveInterval is how often changed usage counters are written to disk.
+const usageSaveInterval = 30 * time.Second
+
+// Endpoint names that API key tiers can allow.
+const (
+	endpointIP      = "ip"
+	endpointNetwork = "network"
+	endpointASN     = "asn"
+	endpointDomain  = "domain"
+	endpointBatch   = "batch"
+	endpointStream  = "stream"
+	endpointJobs    = "jobs"
+)
+
+// knownEndpoints lists every endpoint name accepted in the keys file.
+var knownEndpoints = []string{endpointIP, endpointNetwork, endpointASN, endpointDomain, endpointBatch, endpointStream, endpointJobs}
+
+// apiKeysConfig is the layout of the API keys file.
+type apiKeysConfig struct {
+	Tiers map[string]apiTierConfig `yaml:"tiers"`
+	Keys  []apiKeyConfig           `yaml:"keys"`
+}
+
+// apiTierConfig defines what the keys of a tier may access; empty endpoints and zero quotas are unrestricted.
+type apiTierConfig struct {
+	Endpoints    []string `yaml:"endpoints"`
+	DailyQuota   int64    `yaml:"daily_quota"`
+	MonthlyQuota int64    `yaml:"monthly_quota"`
+}
+
+// apiKeyConfig assigns a key to a tier.
+type apiKeyConfig struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+	Tier string `yaml:"tier"`
+}
+
+// apiKey is a configured key with the limits of its tier.
+type apiKey struct {
+	auth         *authenticator
+	name         string
+	tier         string
+	endpoints    []string
+	dailyQuota   int64
+	monthlyQuota int64
+}
+
+// keyUsage counts the requests of a key in the current day and month.
+type keyUsage struct {
+	Day     string `json:"day"`
+	Daily   int64  `json:"daily"`
+	Month   string `json:"month"`
+	Monthly int64  `json:"monthly"`
+}
+
+// apiKeyContextKey is the context key of the API key that authenticated a request.
+type apiKeyContextKey struct{}
+
+// authenticator checks API keys against their tiers and quotas and keeps usage counters on disk.
+type authenticator struct {
+	keysFile  string
+	usageFile string
+	keys      map[[sha256.Size]byte]*apiKey
+
+	mu    sync.Mutex
+	usage map[string]*keyUsage
+	dirty bool
+	wg    sync.WaitGroup
+}
+
+// newAuthenticator creates an authenticator. Authentication is disabled unless a keys file is configured.
+func newAuthenticator(cfg config.AuthConfig) *authenticator {
+	return &authenticator{
+		keysFile:  cfg.KeysFile,
+		usageFile: cfg.UsageFile,
+		usage:     make(map[string]*keyUsage),
+	}
+}
+
+// enabled reports whether requests need an API key.
+func (a *authenticator) enabled() bool {
+	return a.keysFile != ""
+}
+
+// start loads the keys and usage counters and saves the counters periodically until ctx is done.
+func (a *authenticator) start(ctx context.Context) error {
+	if !a.enabled() {
+		return nil
+	}
+	if err := a.loadKeys(); err != nil {
+		return fmt.Errorf("loading api keys: %w", err)
+	}
+	if err := a.loadUsage(); err != nil {
+		return fmt.Errorf("loading api usage: %w", err)
+	}
+	slog.Info("api key authentication enabled", "keys", len(a.keys))
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ticker := time.NewTicker(usageSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.saveUsage()
+			case <-ctx.Done():
+				a.saveUsage()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// wait blocks until the usage counters have been saved for the last time.
+func (a *authenticator) wait() {
+	a.wg.Wait()
+}
+
+// loadKeys reads the keys file and resolves every key's tier.
+func (a *authenticator) loadKeys() error {
+	raw, err := os.ReadFile(a.keysFile)
+	if err != nil {
+		return err
+	}
+	var config apiKeysConfig
+	if err := yaml.Unmarshal(raw, &config); err != nil {
+		return err
+	}
+
+	for name, tier := range config.Tiers {
+		for _, endpoint := range tier.Endpoints {
+			if !slices.Contains(knownEndpoints, endpoint) {
+				return fmt.Errorf("tier %q: unknown endpoint %q", name, endpoint)
+			}
+		}
+	}
+
+	a.keys = make(map[[sha256.Size]byte]*apiKey, len(config.Keys))
+	names := make(map[string]struct{}, len(config.Keys))
+	for i, key := range config.Keys {
+		if key.Name == "" || key.Key == "" {
+			return fmt.Errorf("key %d: name and key are required", i+1)
+		}
+		if _, ok := names[key.Name]; ok {
+			return fmt.Errorf("key %q: duplicate name", key.Name)
+		}
+		tier, ok := config.Tiers[key.Tier]
+		if !ok {
+			return fmt.Errorf("key %q: unknown tier %q", key.Name, key.Tier)
+		}
+		hash := sha256.Sum256([]byte(key.Key))
+		if _, ok := a.keys[hash]; ok {
+			return fmt.Errorf("key %q: duplicate key", key.Name)
+		}
+
+		names[key.Name] = struct{}{}
+		a.keys[hash] = &apiKey{
+			auth:         a,
+			name:         key.Name,
+			tier:         key.Tier,
+			endpoints:    tier.Endpoints,
+			dailyQuota:   tier.DailyQuota,
+			monthlyQuota: tier.MonthlyQuota,
+		}
+	}
+	return nil
+}
+
+// loadUsage restores the usage counters saved by a previous run.
+func (a *authenticator) loadUsage() error {
+	raw, err := os.ReadFile(a.usageFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return json.Unmarshal(raw, &a.usage)
+}
+
+// saveUsage writes the usage counters to disk if they changed since the last save.
+func (a *authenticator) saveUsage() {
+	a.mu.Lock()
+	if !a.dirty {
+		a.mu.Unlock()
+		return
+	}
+	raw, err := json.Marshal(a.usage)
+	a.dirty = false
+	a.mu.Unlock()
+
+	if err != nil {
+		slog.Error("failed to encode api usage", "error", err)
+		return
+	}
+	if err := os.WriteFile(a.usageFile+".tmp", raw, 0o644); err != nil {
+		slog.Error("failed to save api usage", "error", err)
+		return
+	}
+	if err := os.Rename(a.usageFile+".tmp", a.usageFile); err != nil {
+		slog.Error("failed to save api usage", "error", err)
+	}
+}
+
+// middleware rejects requests without a valid key or for an endpoint outside the key's tier. Quotas are
+// charged by quotaMiddleware, behind the rate limiter.
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	if !a.enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := requestEndpoint(r)
+		if endpoint == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := requestToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			sendError(w, r, "Please provide an API key.", http.StatusUnauthorized)
+			return
+		}
+		key, ok := a.keys[sha256.Sum256([]byte(token))]
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			sendError(w, r, "Invalid API key.", http.StatusUnauthorized)
+			return
+		}
+
+		if !key.allows(endpoint) {
+			sendError(w, r, "This API key cannot access this endpoint.", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	})
+}
+
+// quotaMiddleware charges requests to the quotas of their API key, rejecting them once a quota is used
+// up. It runs after the rate limiter has admitted a request, so throttled requests cost no quota.
+func (a *authenticator) quotaMiddleware(next http.Handler) http.Handler {
+	if !a.enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := requestAPIKey(r)
+		if key == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		endpoint := requestEndpoint(r)
+
+		// Bulk requests are charged per query as the queries are looked up, so the request itself only
+		// needs quota left. Reading or deleting a job costs nothing.
+		cost := int64(1)
+		switch endpoint {
+		case endpointBatch, endpointStream, endpointJobs:
+			cost = 0
+		}
+		if endpoint != endpointJobs || r.Method == http.MethodPost {
+			if retryAfter, ok := a.consume(key, time.Now(), cost); !ok {
+				sendQuotaExceeded(w, r, retryAfter)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// consume counts n lookups against the key's quotas, returning how long to wait if not enough of a quota
+// is left. Nothing is counted then. With n of 0, it only checks that some quota is left.
+func (a *authenticator) consume(key *apiKey, now time.Time, n int64) (time.Duration, bool) {
+	now = now.UTC()
+	day, month := now.Format(time.DateOnly), now.Format("2006-01")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	usage, ok := a.usage[key.name]
+	if !ok {
+		usage = &keyUsage{}
+		a.usage[key.name] = usage
+	}
+	if usage.Day != day {
+		usage.Day, usage.Daily = day, 0
+	}
+	if usage.Month != month {
+		usage.Month, usage.Monthly = month, 0
+	}
+
+	need := max(n, 1)
+	if key.monthlyQuota > 0 && usage.Monthly+need > key.monthlyQuota {
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC).Sub(now), false
+	}
+	if key.dailyQuota > 0 && usage.Daily+need > key.dailyQuota {
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC).Sub(now), false
+	}
+
+	usage.Daily += n
+	usage.Monthly += n
+	a.dirty = a.dirty || n > 0
+	return 0, true
+}
+
+// errQuotaExceeded is the error of batch, stream and job queries beyond the API key's quota.
+var errQuotaExceeded = errors.New("api key quota exceeded")
+
+// charge counts n queries of a batch, stream or job against the key's quotas, returning how long to
+// wait if not enough of a quota is left. A nil key, as used when authentication is disabled, is never
+// charged.
+func (k *apiKey) charge(n int) (time.Duration, bool) {
+	if k == nil {
+		return 0, true
+	}
+	return k.auth.consume(k, time.Now(), int64(n))
+}
+
+// sendQuotaExceeded answers that the API key has used up a quota, which renews after retryAfter.
+func sendQuotaExceeded(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(int(retryAfter.Seconds()), 1)))
+	sendError(w, r, "API key quota exceeded.", http.StatusTooManyRequests)
+}
+
+// allows reports whether the key's tier includes endpoint.
+func (k *apiKey) allows(endpoint string) bool {
+	return len(k.endpoints) == 0 || slices.Contains(k.endpoints, endpoint)
+}
+
+// permits returns an error if the key may not look up a single batch, stream or job query.
+// A nil key, as used when authentication is disabled, permits everything.
+func (k *apiKey) permits(query string) error {
+	if k == nil {
+		return nil
+	}
+	if endpoint := queryEndpoint(query); endpoint != "" && !k.allows(endpoint) {
+		return fmt.Errorf("this api key cannot look up %s queries", endpoint)
+	}
+	return nil
+}
+
+// requestAPIKey returns the API key that authenticated the request, or nil.
+func requestAPIKey(r *http.Request) *apiKey {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(*apiKey)
+	return key
+}
+
+// requestToken returns the API key from the Authorization or X-API-Key header or the token query parameter.
+func requestToken(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if token := r.Header.Get("X-API-Key"); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// requestEndpoint names the endpoint a request is routed to, or returns "" for public routes.
+func requestEndpoint(r *http.Request) string {
+	path := strings.Trim(r.URL.Path, "/")
+	first, rest, _ := strings.Cut(path, "/")
+
+	switch first {
+	case "health", "favicon.ico", "search", "openapi.json", "schemas":
+		return ""
+	case "batch":
+		return endpointBatch
+	case "stream":
+		return endpointStream
+	case "jobs":
+		return endpointJobs
+	}
+
+	if endpoint := queryEndpoint(first); endpoint == endpointASN || endpoint == endpointDomain {
+		return endpoint
+	}
+	if _, err := strconv.Atoi(rest); err == nil {
+		return endpointNetwork
+	}
+	return endpointIP
+}
+
+// queryEndpoint names the endpoint that serves a single lookup query, or returns "" if it is not valid.
+// It classifies queries the same way lookupBatchItem resolves them.
+func queryEndpoint(query string) string {
+	switch {
+	case isASNQuery(query):
+		return endpointASN
+	case net.ParseIP(query) != nil:
+		return endpointIP
+	case strings.Contains(query, "/"):
+		if _, _, err := net.ParseCIDR(query); err == nil {
+			return endpointNetwork
+		}
+		return ""
+	case strings.Contains(query, "."):
+		return endpointDomain
+	}
+	return ""
+}
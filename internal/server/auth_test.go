@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+)
+
+// newTestRouter returns the HTTP API on the test databases with the given authenticator and rate limits.
+func newTestRouter(t *testing.T, auth *authenticator, limits config.RateLimitConfig) http.Handler {
+	t.Helper()
+	cfg := config.Default()
+	cfg.RateLimit = limits
+	return newTestRouterConfig(t, auth, cfg)
+}
+
+// newTestRouterConfig returns the HTTP API on the test databases with the given authenticator and configuration.
+func newTestRouterConfig(t *testing.T, auth *authenticator, cfg *config.Config) http.Handler {
+	t.Helper()
+	geoIP := newTestGeoIP(t)
+	rateLimits := newRateLimiter(cfg.RateLimit)
+	return newRouter(geoIP, newJobManager(geoIP, rateLimits, cfg.Jobs), auth, rateLimits, cfg)
+}
+
+// serveTest sends a request with an API key, if key is not empty, and returns the response.
+func serveTest(handler http.Handler, method, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// dailyUsage returns how many lookups the named key has been charged today.
+func dailyUsage(auth *authenticator, name string) int64 {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+	if usage, ok := auth.usage[name]; ok && usage.Day == time.Now().UTC().Format(time.DateOnly) {
+		return usage.Daily
+	}
+	return 0
+}
+
+func TestAuthentication(t *testing.T) {
+	auth := newTestAuthenticator(t, true)
+	router := newTestRouter(t, auth, config.RateLimitConfig{})
+
+	tests := []struct {
+		name   string
+		target string
+		key    string
+		want   int
+	}{
+		{"no key", "/8.8.8.8", "", http.StatusUnauthorized},
+		{"invalid key", "/8.8.8.8", "wrong", http.StatusUnauthorized},
+		{"outside tier", "/AS15169", "ip-secret", http.StatusForbidden},
+		{"public route", "/health", "", http.StatusOK},
+		{"token parameter", "/8.8.8.0/24?token=batch-secret", "", http.StatusForbidden},
+		{"within quota", "/10.0.0.1", "ip-secret", http.StatusOK},
+		{"within quota again", "/10.0.0.2", "ip-secret", http.StatusOK},
+		{"over quota", "/10.0.0.3", "ip-secret", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveTest(router, http.MethodGet, tt.target, tt.key, "")
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+	if got := dailyUsage(auth, "ip"); got != 2 {
+		t.Errorf("ip key charged %d lookups, want 2", got)
+	}
+}
+
+func TestRateLimitedRequestsCostNoQuota(t *testing.T) {
+	auth := newTestAuthenticator(t, true)
+	router := newTestRouter(t, auth, config.RateLimitConfig{IP: config.RateLimit{Limit: 1, Window: time.Hour}})
+
+	if rec := serveTest(router, http.MethodGet, "/10.0.0.1", "ip-secret", ""); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", rec.Code)
+	}
+	for range 3 {
+		if rec := serveTest(router, http.MethodGet, "/10.0.0.1", "ip-secret", ""); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+			t.Fatalf("throttled request: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+		}
+	}
+	if got := dailyUsage(auth, "ip"); got != 1 {
+		t.Errorf("ip key charged %d lookups, want only the one that was served", got)
+	}
+}
+
+func TestBatchChargesLookedUpQueries(t *testing.T) {
+	auth := newTestAuthenticator(t, true)
+	router := newTestRouter(t, auth, config.RateLimitConfig{})
+
+	// The ASN is outside the batch key's tier and the last query is invalid, so only the two IPs count.
+	rec := serveTest(router, http.MethodPost, "/batch", "batch-secret", `["10.0.0.1", "AS13335", "10.0.0.2", "not a query", "10.0.0.1"]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var results map[string]map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 || results["AS13335"]["error"] == nil || results["not a query"]["error"] == nil || results["10.0.0.2"]["bogon"] != true {
+		t.Errorf("results %v", results)
+	}
+	if got := dailyUsage(auth, "batch"); got != 2 {
+		t.Errorf("batch key charged %d lookups, want 2", got)
+	}
+
+	// Two more IPs do not fit into the one lookup left, so the batch is refused as a whole.
+	rec = serveTest(router, http.MethodPost, "/batch", "batch-secret", `["10.0.0.3", "10.0.0.4"]`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("batch over quota: status %d, want 429", rec.Code)
+	}
+	if got := dailyUsage(auth, "batch"); got != 2 {
+		t.Errorf("batch key charged %d lookups after a refused batch, want 2", got)
+	}
+}
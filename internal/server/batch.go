@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/db"
+)
+
+const (
+	// batchBodyLimit caps the size of a batch request body in bytes.
+	batchBodyLimit = 1 << 20
+	// batchConcurrency bounds the number of lookups running at once for a single batch.
+	batchConcurrency = 8
+)
+
+// handleBatch handles batch lookups of IPs, ASNs and domains sent as a JSON array.
+func handleBatch(geoIP *db.GeoIPManager, maxBatchSize int, limits *rateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, batchBodyLimit)
+		var queries []string
+		if err := json.NewDecoder(r.Body).Decode(&queries); err != nil || len(queries) == 0 {
+			sendError(w, r, "Please provide a JSON array of IPs, ASNs or domains.", http.StatusBadRequest)
+			return
+		}
+
+		if len(queries) > maxBatchSize {
+			sendError(w, r, fmt.Sprintf("Batch size exceeds the maximum of %d items.", maxBatchSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		unique := make([]string, 0, len(queries))
+		seen := make(map[string]struct{}, len(queries))
+		for _, query := range queries {
+			query = strings.TrimSpace(query)
+			if _, ok := seen[query]; !ok {
+				seen[query] = struct{}{}
+				unique = append(unique, query)
+			}
+		}
+
+		// Queries outside the key's tier are answered right away, and only valid queries that are looked
+		// up are charged.
+		key := requestAPIKey(r)
+		results := make(map[string]any, len(unique))
+		lookups := make([]string, 0, len(unique))
+		charged := 0
+		for _, query := range unique {
+			if err := key.permits(query); err != nil {
+				results[query] = errorResponse{Error: err.Error()}
+				continue
+			}
+			lookups = append(lookups, query)
+			if queryEndpoint(query) != "" {
+				charged++
+			}
+		}
+		if retryAfter, ok := key.charge(charged); !ok {
+			sendQuotaExceeded(w, r, retryAfter)
+			return
+		}
+
+		// Domain queries wait for the domain rate limit, which may take longer than the write timeout.
+		if slices.ContainsFunc(lookups, func(q string) bool { return queryEndpoint(q) == endpointDomain }) {
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		}
+
+		lang := requestLanguage(r, geoIP)
+		identity := rateIdentity(r)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		sem := make(chan struct{}, batchConcurrency)
+
+		for _, query := range lookups {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(q string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				var data any
+				err := limits.waitQuery(r.Context(), identity, q)
+				if err == nil {
+					data, err = lookupBatchItem(r.Context(), geoIP, q, lang)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					results[q] = errorResponse{Error: err.Error()}
+					return
+				}
+				results[q] = data
+			}(query)
+		}
+
+		wg.Wait()
+		sendResponse(w, r, results, http.StatusOK)
+	}
+}
+
+// lookupBatchItem resolves a single batch entry the same way rootHandler routes a path.
+func lookupBatchItem(ctx context.Context, geoIP *db.GeoIPManager, query, lang string) (any, error) {
+	if query == "" {
+		return nil, errors.New("empty query")
+	}
+
+	if isASNQuery(query) {
+		return lookupASNItem(ctx, geoIP, query)
+	}
+
+	if data, ok, err := lookupAddressItem(ctx, geoIP, query, lang); ok {
+		return data, err
+	}
+
+	if strings.Contains(query, ".") {
+		return lookupDomainItem(ctx, query)
+	}
+
+	return nil, errors.New("invalid query: must be an ip address, asn or domain")
+}
+
+// lookupASNItem resolves an ASN such as "AS13335" or "13335".
+func lookupASNItem(ctx context.Context, geoIP *db.GeoIPManager, query string) (any, error) {
+	asn, err := parseASN(query)
+	if err != nil {
+		return nil, err
+	}
+	data, err := common.LookupASNData(ctx, geoIP, asn)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// lookupAddressItem resolves an IP address or CIDR network. ok is false if query is neither.
+func lookupAddressItem(ctx context.Context, geoIP *db.GeoIPManager, query, lang string) (data any, ok bool, err error) {
+	if ip := net.ParseIP(query); ip != nil {
+		if common.IsBogon(ip) {
+			return bogonDataStruct{IP: ip.String(), Bogon: true}, true, nil
+		}
+		data := common.LookupIPData(ctx, geoIP, ip, lang)
+		if data == nil {
+			return nil, true, errors.New("could not retrieve data for the specified ip")
+		}
+		return data, true, nil
+	}
+
+	if _, network, err := net.ParseCIDR(query); err == nil {
+		if common.IsBogon(network.IP) {
+			return bogonDataStruct{IP: network.String(), Bogon: true}, true, nil
+		}
+		data, err := common.LookupNetworkData(ctx, geoIP, network)
+		if err != nil {
+			return nil, true, err
+		}
+		return data, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// lookupDomainItem resolves a domain name.
+func lookupDomainItem(ctx context.Context, query string) (any, error) {
+	domain, err := normalizeDomain(query)
+	if err != nil {
+		return nil, err
+	}
+	data, err := common.LookupDomainData(ctx, domain)
+	if err != nil {
+		slog.Error("failed to look up domain data", "domain", domain, "error", err)
+		return nil, errors.New("error retrieving data for domain")
+	}
+	return data, nil
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipinfo/internal/config"
+)
+
+// jsonBody decodes a JSON object response.
+func jsonBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("status %d, body %q: %v", rec.Code, rec.Body.String(), err)
+	}
+	return body
+}
+
+func TestBatch(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+
+	rec := serveTest(router, http.MethodPost, "/batch", "", `["8.8.8.8", " 8.8.8.8 ", "AS13335", "10.0.0.1", "1.1.1.0/24", "", "not a query"]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	results := jsonBody(t, rec)
+
+	// Queries are trimmed and answered once.
+	if len(results) != 6 {
+		t.Errorf("got %d results, want 6: %v", len(results), results)
+	}
+	if ip, _ := results["8.8.8.8"].(map[string]any); ip["country"] != "US" || ip["asn"] != 15169.0 {
+		t.Errorf("8.8.8.8 result %v", results["8.8.8.8"])
+	}
+	if asn, _ := results["AS13335"].(map[string]any); asn["details"] == nil {
+		t.Errorf("AS13335 result %v", results["AS13335"])
+	}
+	if bogon, _ := results["10.0.0.1"].(map[string]any); bogon["bogon"] != true {
+		t.Errorf("10.0.0.1 result %v, want bogon", results["10.0.0.1"])
+	}
+	if network, _ := results["1.1.1.0/24"].(map[string]any); network["asn_network"] != "1.1.1.0/24" {
+		t.Errorf("1.1.1.0/24 result %v", results["1.1.1.0/24"])
+	}
+	for _, query := range []string{"", "not a query"} {
+		if failed, _ := results[query].(map[string]any); failed["error"] == nil {
+			t.Errorf("%q result %v, want an error", query, results[query])
+		}
+	}
+}
+
+func TestBatchRequestLimits(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+	queries := make([]string, config.Default().Server.BatchMaxSize+1)
+	for i := range queries {
+		queries[i] = fmt.Sprintf(`"10.0.%d.%d"`, i/256, i%256)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"at the limit", "[" + strings.Join(queries[1:], ",") + "]", http.StatusOK},
+		{"over the limit", "[" + strings.Join(queries, ",") + "]", http.StatusRequestEntityTooLarge},
+		{"empty", "[]", http.StatusBadRequest},
+		{"not an array", `{"query": "8.8.8.8"}`, http.StatusBadRequest},
+		{"not json", "8.8.8.8", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serveTest(router, http.MethodPost, "/batch", "", tt.body); rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
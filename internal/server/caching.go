@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ipinfo/internal/db"
+)
+
+// lookupCache holds the caching headers of a response computed from the databases. It changes
+// whenever either database is replaced by a different build.
+type lookupCache struct {
+	etag     string
+	modified time.Time
+	control  string
+}
+
+// newLookupCache derives the caching headers of a lookup from the database builds and everything in
+// the request the response depends on. subject is the looked up IP address, network or ASN. Responses
+// that depend on the client, such as lookups of its own address or with an API key, are private.
+func newLookupCache(geoIP *db.GeoIPManager, r *http.Request, subject string, private bool) lookupCache {
+	city, asn := geoIP.BuildEpochs()
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n%d\n%s\n%s\n%s\n%s\n%s", city, asn, subject, r.URL.Path, r.URL.RawQuery,
+		r.Header.Get("Accept"), r.Header.Get("Accept-Language"))
+
+	var maxAge time.Duration
+	if next := geoIP.NextUpdate(); !next.IsZero() {
+		maxAge = max(time.Until(next), 0)
+	}
+
+	return lookupCache{
+		// The ETag is weak because the body may be compressed.
+		etag:     `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`,
+		modified: time.Unix(int64(max(city, asn)), 0),
+		control:  cacheControl(r, private, maxAge),
+	}
+}
+
+// notModified answers with 304 Not Modified and returns true if the client sent a matching If-None-Match.
+// It is called once the lookup succeeded, so a client holding an ETag still learns of errors, such as a
+// network that no longer fits the databases.
+func (c lookupCache) notModified(w http.ResponseWriter, r *http.Request) bool {
+	if !etagMatches(r.Header.Get("If-None-Match"), c.etag) {
+		return false
+	}
+	c.setHeaders(w)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// setHeaders sets the ETag, Last-Modified and Cache-Control headers.
+func (c lookupCache) setHeaders(w http.ResponseWriter) {
+	w.Header().Set("ETag", c.etag)
+	w.Header().Set("Last-Modified", c.modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", c.control)
+}
+
+// cacheControl returns a Cache-Control value allowing the response to be reused for maxAge.
+func cacheControl(r *http.Request, private bool, maxAge time.Duration) string {
+	scope := "public"
+	if private || requestAPIKey(r) != nil {
+		scope = "private"
+	}
+	return fmt.Sprintf("%s, max-age=%d", scope, int(maxAge.Seconds()))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing weakly as RFC 9110 requires.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestNotModifiedAfterLookup checks that If-None-Match is only answered with 304 once the lookup has
+// succeeded, so errors still reach clients that hold an ETag.
+func TestNotModifiedAfterLookup(t *testing.T) {
+	geoIP := newTestGeoIP(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		handle func(w http.ResponseWriter, r *http.Request)
+		want   int
+	}{
+		{"ip", "/8.8.8.8", func(w http.ResponseWriter, r *http.Request) { handleIPLookup(w, r, "8.8.8.8", geoIP) }, http.StatusNotModified},
+		{"bogon", "/10.0.0.1", func(w http.ResponseWriter, r *http.Request) { handleIPLookup(w, r, "10.0.0.1", geoIP) }, http.StatusNotModified},
+		{"network", "/8.8.8.0/24", func(w http.ResponseWriter, r *http.Request) { handleNetworkLookup(w, r, "8.8.8.0/24", geoIP) }, http.StatusNotModified},
+		{"split network", "/1.0.0.0/8", func(w http.ResponseWriter, r *http.Request) { handleNetworkLookup(w, r, "1.0.0.0/8", geoIP) }, http.StatusNotFound},
+		{"asn", "/AS15169", func(w http.ResponseWriter, r *http.Request) { handleASNLookup(w, r, "AS15169", geoIP) }, http.StatusNotModified},
+		{"unknown asn", "/AS64999", func(w http.ResponseWriter, r *http.Request) { handleASNLookup(w, r, "AS64999", geoIP) }, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("If-None-Match", "*")
+			tt.handle(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want == http.StatusNotModified && (rec.Header().Get("ETag") == "" || rec.Body.Len() > 0) {
+				t.Errorf("304 with ETag %q and body %q", rec.Header().Get("ETag"), rec.Body.String())
+			}
+			if tt.want != http.StatusNotModified && rec.Header().Get("ETag") != "" {
+				t.Errorf("error response has ETag %q", rec.Header().Get("ETag"))
+			}
+		})
+	}
+
+	// The ETag of a successful response revalidates it.
+	rec := httptest.NewRecorder()
+	handleASNLookup(rec, httptest.NewRequest(http.MethodGet, "/AS15169", nil), "AS15169", geoIP)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("status %d ETag %q, want 200 with a weak ETag", rec.Code, etag)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/AS15169", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rec = httptest.NewRecorder()
+	handleASNLookup(rec, req, "AS15169", geoIP)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("revalidation status %d, want 304", rec.Code)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{"*", true},
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"x", W/"abc"`, true},
+		{`"abcd"`, false},
+		{`W/"x"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.ifNoneMatch, `W/"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.ifNoneMatch, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressWriter is an encoder of a content coding that can be reused for another response.
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// contentCoding describes a Content-Encoding the server can compress responses with. Encoders are
+// pooled, since creating them allocates large buffers.
+type contentCoding struct {
+	name string
+	pool sync.Pool
+}
+
+// contentCodings lists the supported codings in order of preference, used when the client accepts
+// several equally.
+var contentCodings = []*contentCoding{
+	newContentCoding("zstd", func() compressWriter {
+		// A single goroutine per encoder keeps small responses cheap.
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	}),
+	newContentCoding("br", func() compressWriter {
+		// Brotli's default level is too slow for responses compressed on the fly.
+		return brotli.NewWriterLevel(nil, 5)
+	}),
+	newContentCoding("gzip", func() compressWriter {
+		return gzip.NewWriter(nil)
+	}),
+}
+
+// newContentCoding creates a coding whose pool makes encoders with newWriter.
+func newContentCoding(name string, newWriter func() compressWriter) *contentCoding {
+	return &contentCoding{name: name, pool: sync.Pool{New: func() any { return newWriter() }}}
+}
+
+// negotiateCoding selects the coding from the Accept-Encoding header, honoring q-values and *. It
+// returns nil if the response should not be compressed.
+func negotiateCoding(acceptEncoding string) *contentCoding {
+	if acceptEncoding == "" {
+		return nil
+	}
+
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		weights[name] = q
+	}
+
+	var best *contentCoding
+	bestQ := 0.0
+	for _, coding := range contentCodings {
+		q, ok := weights[coding.name]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressor is middleware that compresses response bodies of at least minSize bytes with the coding
+// negotiated through Accept-Encoding.
+type compressor struct {
+	minSize int
+}
+
+// newCompressor creates a compressor for bodies of at least minSize bytes.
+func newCompressor(minSize int) *compressor {
+	return &compressor{minSize: minSize}
+}
+
+// middleware compresses the responses of next where the client accepts it.
+func (c *compressor) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		coding := negotiateCoding(r.Header.Get("Accept-Encoding"))
+		if coding == nil || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, coding: coding, minSize: c.minSize}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressResponseWriter holds back the start of a response until it knows whether the body is large
+// enough to compress, then writes it either compressed or as is. A flush decides right away, so
+// streamed responses are compressed from their first line.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	coding  *contentCoding
+	minSize int
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	encoder compressWriter
+}
+
+// WriteHeader records the status code, which is sent once the coding is decided.
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if code < http.StatusOK {
+		// Informational responses such as 100 Continue go out immediately.
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	if !bodyAllowed(code) {
+		w.decide(false)
+	}
+}
+
+// Write buffers the body until minSize bytes have been written, then compresses it.
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf.Write(b)
+		if w.buf.Len() >= w.minSize {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// FlushError sends everything written so far, compressing from here on if the client accepts it.
+func (w *compressResponseWriter) FlushError() error {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.decide(true); err != nil {
+			return err
+		}
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Flush implements http.Flusher for handlers that do not use http.ResponseController.
+func (w *compressResponseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide sends the header, compressing the body if compress is set and the response allows it, and
+// writes out the buffered body.
+func (w *compressResponseWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	// Responses offering byte ranges, such as job results, are sent as is so ranges stay valid.
+	if compress && bodyAllowed(w.status) && header.Get("Content-Encoding") == "" && header.Get("Accept-Ranges") == "" &&
+		w.status != http.StatusPartialContent {
+		header.Set("Content-Encoding", w.coding.name)
+		header.Del("Content-Length")
+		w.encoder = w.coding.pool.Get().(compressWriter)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+// close writes out a body smaller than minSize as is, or finishes the compressed stream.
+func (w *compressResponseWriter) close() {
+	if !w.decided {
+		if w.status == 0 {
+			// The handler wrote nothing, which net/http answers with an empty 200.
+			return
+		}
+		if err := w.decide(false); err != nil {
+			slog.Debug("failed to write response", "error", err)
+		}
+		return
+	}
+	if w.encoder == nil {
+		return
+	}
+	if err := w.encoder.Close(); err != nil {
+		slog.Debug("failed to finish compressed response", "coding", w.coding.name, "error", err)
+	}
+	// The encoder must not keep the connection's writer alive in the pool.
+	w.encoder.Reset(nil)
+	w.coding.pool.Put(w.encoder)
+}
+
+// bodyAllowed reports whether a response with the given status can have a body.
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified && status >= http.StatusOK
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipinfo/internal/config"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateCoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br, zstd, gzip", "zstd"},
+		{"*", "zstd"},
+		{"GZIP;q=0.5, br;q=0.4", "gzip"},
+		{"zstd;q=0, *", "br"},
+		{"gzip;q=0", ""},
+		{"gzip;q=bad, br", "br"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if coding := negotiateCoding(tt.acceptEncoding); coding != nil {
+			got = coding.name
+		}
+		if got != tt.want {
+			t.Errorf("negotiateCoding(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+		}
+	}
+}
+
+// decode reads a response body in the given content coding.
+func decode(t *testing.T, coding string, body io.Reader) string {
+	t.Helper()
+	var r io.Reader
+	switch coding {
+	case "":
+		r = body
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	case "br":
+		r = brotli.NewReader(body)
+	case "zstd":
+		dec, err := zstd.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dec.Close()
+		r = dec
+	default:
+		t.Fatalf("unexpected coding %q", coding)
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(decoded)
+}
+
+func TestCompressor(t *testing.T) {
+	large := strings.Repeat("8.8.8.8 ", 200)
+	handler := newCompressor(1024).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("Content-Length", "1600")
+			io.WriteString(w, large[:800])
+			io.WriteString(w, large[800:])
+		case "/small":
+			io.WriteString(w, "8.8.8.8")
+		case "/ranges":
+			w.Header().Set("Accept-Ranges", "bytes")
+			io.WriteString(w, large)
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		wantCoding     string
+		wantBody       string
+	}{
+		{"/large", "gzip", "gzip", large},
+		{"/large", "br", "br", large},
+		{"/large", "zstd, gzip", "zstd", large},
+		{"/large", "", "", large},
+		{"/small", "gzip", "", "8.8.8.8"},
+		{"/ranges", "gzip", "", large},
+		{"/not-modified", "gzip", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.acceptEncoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantCoding {
+				t.Errorf("Content-Encoding %q, want %q", got, tt.wantCoding)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary %q, want Accept-Encoding", got)
+			}
+			if tt.wantCoding != "" && rec.Header().Get("Content-Length") != "" {
+				t.Errorf("compressed response kept Content-Length %s", rec.Header().Get("Content-Length"))
+			}
+			if got := decode(t, tt.wantCoding, rec.Body); got != tt.wantBody {
+				t.Errorf("body %d bytes, want %d", len(got), len(tt.wantBody))
+			}
+		})
+	}
+}
+
+func TestCompressorFlush(t *testing.T) {
+	// The handler sends its first line and waits until the client has read it, which only works if the
+	// flush reaches the connection through every wrapping writer.
+	read := make(chan struct{}, 1)
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first\n")
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Errorf("%T is not an http.Flusher", w)
+			return
+		}
+		flusher.Flush()
+		<-read
+		io.WriteString(w, "second\n")
+	})
+	handler = newCompressor(1024).middleware(handler)
+	handler = metricsMiddleware(handler)
+	handler = newAccessLogger(config.Default().Log).middleware(handler)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	for _, coding := range []string{"gzip", ""} {
+		t.Run("coding "+coding, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			req.Header.Set("Accept-Encoding", coding)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get("Content-Encoding"); got != coding {
+				t.Fatalf("Content-Encoding %q, want %q", got, coding)
+			}
+
+			var body io.Reader = resp.Body
+			if coding == "gzip" {
+				if body, err = gzip.NewReader(resp.Body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			lines := bufio.NewReader(body)
+			if line, err := lines.ReadString('\n'); line != "first\n" {
+				t.Fatalf("first line %q: %v", line, err)
+			}
+			read <- struct{}{}
+			if rest, err := io.ReadAll(lines); string(rest) != "second\n" {
+				t.Errorf("rest %q: %v", rest, err)
+			}
+		})
+	}
+}
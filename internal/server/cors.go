@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"ipinfo/internal/config"
+)
+
+// serverMethods lists the methods the API serves, for the Allow header of OPTIONS responses.
+const serverMethods = "GET, HEAD, POST, DELETE, OPTIONS"
+
+// corsPolicy answers preflight requests and adds CORS headers to the responses of allowed origins.
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     []string
+	wildcards   []string // host suffixes such as ".example.com"
+	schemes     []string // schemes of the wildcards, such as "https://"
+	methods     string
+	anyHeader   bool
+	headers     string
+	exposed     string
+	maxAge      string
+	credentials bool
+}
+
+// newCORSPolicy creates a policy from the configuration. Origins are compared case-insensitively, and
+// an origin such as https://*.example.com allows every subdomain of example.com but not example.com itself.
+func newCORSPolicy(cfg config.CORSConfig) *corsPolicy {
+	c := &corsPolicy{
+		methods:     strings.Join(cfg.AllowedMethods, ", "),
+		headers:     strings.Join(cfg.AllowedHeaders, ", "),
+		anyHeader:   slices.Contains(cfg.AllowedHeaders, "*"),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		maxAge:      strconv.Itoa(int(cfg.MaxAge.Seconds())),
+		credentials: cfg.AllowCredentials,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			c.anyOrigin = true
+		} else if scheme, host, ok := strings.Cut(origin, "://*."); ok {
+			c.schemes = append(c.schemes, scheme+"://")
+			c.wildcards = append(c.wildcards, "."+host)
+		} else {
+			c.origins = append(c.origins, origin)
+		}
+	}
+	return c
+}
+
+// enabled reports whether any origin is allowed.
+func (c *corsPolicy) enabled() bool {
+	return c.anyOrigin || len(c.origins) > 0 || len(c.wildcards) > 0
+}
+
+// allowed reports whether a request from origin may read the response.
+func (c *corsPolicy) allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if slices.Contains(c.origins, origin) {
+		return true
+	}
+	for i, suffix := range c.wildcards {
+		if sub, ok := strings.CutPrefix(origin, c.schemes[i]); ok && strings.HasSuffix(sub, suffix) && len(sub) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// middleware answers OPTIONS requests itself, as preflights or with the allowed methods, and adds the
+// CORS headers to the responses of allowed origins. It runs before authentication and rate limiting,
+// since browsers send preflights without credentials.
+func (c *corsPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if c.enabled() {
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+		}
+
+		if c.allowed(origin) {
+			if c.anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", c.methods)
+				if requested := r.Header.Get("Access-Control-Request-Headers"); c.anyHeader && requested != "" {
+					w.Header().Set("Access-Control-Allow-Headers", requested)
+				} else if c.headers != "" {
+					w.Header().Set("Access-Control-Allow-Headers", c.headers)
+				}
+				w.Header().Set("Access-Control-Max-Age", c.maxAge)
+			} else if c.exposed != "" {
+				w.Header().Set("Access-Control-Expose-Headers", c.exposed)
+			}
+		}
+
+		if r.Method == http.MethodOptions {
+			// A preflight from an origin that is not allowed gets no CORS headers, which fails it in the browser.
+			w.Header().Set("Allow", serverMethods)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
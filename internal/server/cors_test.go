@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+)
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	policy := newCORSPolicy(config.CORSConfig{AllowedOrigins: []string{"https://app.example.org", "https://*.Example.com"}})
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.org", true},
+		{"HTTPS://APP.EXAMPLE.ORG", true},
+		{"https://www.example.org", false},
+		{"https://www.example.com", true},
+		{"https://a.b.example.com", true},
+		{"https://example.com", false},
+		{"https://.example.com", false},
+		{"http://www.example.com", false},
+		{"https://www.example.com.evil.net", false},
+		{"https://evilexample.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := policy.allowed(tt.origin); got != tt.want {
+			t.Errorf("allowed(%q) = %t, want %t", tt.origin, got, tt.want)
+		}
+	}
+
+	if !newCORSPolicy(config.CORSConfig{AllowedOrigins: []string{"*"}}).allowed("https://anything.test") {
+		t.Error("* does not allow every origin")
+	}
+	if newCORSPolicy(config.CORSConfig{}).enabled() {
+		t.Error("CORS enabled without origins")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	cfg := config.Default()
+	cfg.CORS.AllowedOrigins = []string{"https://*.example.com"}
+	cfg.CORS.AllowCredentials = true
+	cfg.RateLimit.IP = config.RateLimit{Limit: 1, Window: time.Hour}
+	router := newTestRouterConfig(t, newTestAuthenticator(t, true), cfg)
+
+	// preflight sends an OPTIONS request from origin the way browsers do, without credentials.
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/8.8.8.8", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		req.Header.Set("Access-Control-Request-Headers", "x-api-key")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Preflights are answered before authentication and rate limiting, however many arrive.
+	for range 3 {
+		rec := preflight("https://app.example.com")
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("preflight: status %d, want 204", rec.Code)
+		}
+		for header, want := range map[string]string{
+			"Access-Control-Allow-Origin":      "https://app.example.com",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Allow-Methods":     "GET, POST, DELETE",
+			"Access-Control-Max-Age":           "600",
+		} {
+			if got := rec.Header().Get(header); got != want {
+				t.Errorf("preflight %s = %q, want %q", header, got, want)
+			}
+		}
+		if rec.Header().Get("RateLimit-Limit") != "" {
+			t.Error("preflight was rate limited")
+		}
+		if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Origin") || !slices.Contains(vary, "Access-Control-Request-Headers") {
+			t.Errorf("preflight Vary %q", vary)
+		}
+	}
+
+	// A preflight from another origin gets no CORS headers, which fails it in the browser.
+	rec := preflight("https://example.com")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("preflight from a disallowed origin: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	// The actual request gets the exposed headers, and still needs a key.
+	req := httptest.NewRequest(http.MethodGet, "/8.8.8.8", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("X-API-Key", "ip-secret")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("request: status %d, Access-Control-Allow-Origin %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "ETag, Location, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, X-Request-ID" {
+		t.Errorf("Access-Control-Expose-Headers = %q", got)
+	}
+	if rec.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Error("Access-Control-Allow-Methods set outside a preflight")
+	}
+
+	req.Header.Del("X-API-Key")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("request without a key: status %d, want 401 readable by the origin", rec.Code)
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	cfg := config.Default()
+	cfg.CORS.AllowedOrigins = []string{"*"}
+	cfg.CORS.AllowedHeaders = []string{"*"}
+	router := newTestRouterConfig(t, newTestAuthenticator(t, false), cfg)
+
+	req := httptest.NewRequest(http.MethodOptions, "/8.8.8.8", nil)
+	req.Header.Set("Origin", "https://anything.test")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "x-custom, content-type")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "x-custom, content-type" {
+		t.Errorf("Access-Control-Allow-Headers = %q, want the requested headers", got)
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("credentials allowed for *")
+	}
+
+	// A plain OPTIONS request lists the methods.
+	rec = serveTest(router, http.MethodOptions, "/8.8.8.8", "", "")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") != serverMethods {
+		t.Errorf("OPTIONS: status %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
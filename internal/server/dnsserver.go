@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/config"
+	"ipinfo/internal/db"
+
+	"github.com/miekg/dns"
+)
+
+// SOA timers of the zones. Nothing transfers the zones, so only the serial and the negative TTL matter.
+const (
+	soaRefresh = 3600
+	soaRetry   = 600
+	soaExpire  = 86400
+)
+
+// dnsFrontend answers IP-to-ASN TXT queries in the format of the Team Cymru IP to ASN service:
+// "4.4.8.8.<origin zone>" and nibble-reversed IPv6 addresses under the origin6 zone return
+// "ASN | prefix | country | registry | allocated", and "AS15169.<asn zone>" returns
+// "ASN | country | registry | allocated | name". The registry and allocation date are left empty
+// because the databases do not have them.
+type dnsFrontend struct {
+	geoIP       *db.GeoIPManager
+	address     string
+	originZone  string
+	origin6Zone string
+	asnZone     string
+	zones       []string
+	ttl         uint32
+	negativeTTL uint32
+	servers     []*dns.Server
+	access      *accessLogger
+}
+
+// newDNSFrontend creates a DNS frontend for the configured zones that logs queries to access.
+func newDNSFrontend(geoIP *db.GeoIPManager, cfg config.DNSServerConfig, access *accessLogger) *dnsFrontend {
+	f := &dnsFrontend{
+		geoIP:       geoIP,
+		address:     cfg.Address,
+		originZone:  dns.CanonicalName(cfg.OriginZone),
+		origin6Zone: dns.CanonicalName(cfg.Origin6Zone),
+		asnZone:     dns.CanonicalName(cfg.ASNZone),
+		ttl:         uint32(cfg.TTL.Seconds()),
+		negativeTTL: uint32(cfg.NegativeTTL.Seconds()),
+		access:      access,
+	}
+	// The longest zone is matched first, since the origin zones usually lie below the asn zone.
+	f.zones = []string{f.originZone, f.origin6Zone, f.asnZone}
+	slices.SortFunc(f.zones, func(a, b string) int { return len(b) - len(a) })
+	return f
+}
+
+// start listens on UDP and TCP and serves queries until shutdown is called.
+func (f *dnsFrontend) start() error {
+	packetConn, err := net.ListenPacket("udp", f.address)
+	if err != nil {
+		return fmt.Errorf("listening for dns over udp: %w", err)
+	}
+	listener, err := net.Listen("tcp", f.address)
+	if err != nil {
+		packetConn.Close()
+		return fmt.Errorf("listening for dns over tcp: %w", err)
+	}
+
+	f.servers = []*dns.Server{
+		{PacketConn: packetConn, Handler: f},
+		{Listener: listener, Handler: f},
+	}
+	slog.Info("dns server listening", "address", f.address, "zones", f.zones)
+	for _, server := range f.servers {
+		go func() {
+			if err := server.ActivateAndServe(); err != nil {
+				slog.Error("dns server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+	return nil
+}
+
+// shutdown stops the listeners and waits for running queries to finish until ctx is done.
+func (f *dnsFrontend) shutdown(ctx context.Context) {
+	for _, server := range f.servers {
+		if err := server.ShutdownContext(ctx); err != nil {
+			slog.Warn("dns server shutdown failed", "error", err)
+		}
+	}
+}
+
+// ServeDNS answers a query and logs it to the access log. Queries answered with SERVFAIL are logged at
+// error level, like HTTP server errors.
+func (f *dnsFrontend) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	start := time.Now()
+	resp := f.answer(context.Background(), req)
+	if err := w.WriteMsg(resp); err != nil {
+		slog.Warn("failed to write dns response", "error", err)
+	}
+
+	level, ok := f.access.entryLevel(context.Background(), resp.Rcode == dns.RcodeServerFailure)
+	if !ok {
+		return
+	}
+	client := w.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	attrs := []slog.Attr{
+		slog.Int("id", int(req.Id)),
+		slog.String("protocol", w.RemoteAddr().Network()),
+	}
+	if len(req.Question) > 0 {
+		attrs = append(attrs,
+			slog.String("name", req.Question[0].Name),
+			slog.String("type", dns.TypeToString[req.Question[0].Qtype]),
+		)
+	}
+	attrs = append(attrs,
+		slog.String("rcode", dns.RcodeToString[resp.Rcode]),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("client", client),
+	)
+	slog.LogAttrs(context.Background(), level, "dns query", attrs...)
+}
+
+// answer builds the response to a query. Names outside the zones are refused.
+func (f *dnsFrontend) answer(ctx context.Context, req *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	if opt := req.IsEdns0(); opt != nil {
+		resp.SetEdns0(dns.DefaultMsgSize, false)
+	}
+
+	if req.Opcode != dns.OpcodeQuery {
+		resp.SetRcode(req, dns.RcodeNotImplemented)
+		return resp
+	}
+	if len(req.Question) != 1 {
+		resp.SetRcode(req, dns.RcodeFormatError)
+		return resp
+	}
+
+	question := req.Question[0]
+	zone, subdomain, ok := f.zoneOf(dns.CanonicalName(question.Name))
+	if !ok || (question.Qclass != dns.ClassINET && question.Qclass != dns.ClassANY) {
+		resp.SetRcode(req, dns.RcodeRefused)
+		return resp
+	}
+	resp.Authoritative = true
+
+	if subdomain == "" {
+		if question.Qtype == dns.TypeSOA || question.Qtype == dns.TypeANY {
+			resp.Answer = append(resp.Answer, f.soa(zone))
+		} else {
+			resp.Ns = append(resp.Ns, f.soa(zone))
+		}
+		return resp
+	}
+
+	text, err := f.lookup(ctx, zone, subdomain)
+	if err != nil {
+		slog.Error("failed to answer dns query", "name", question.Name, "error", err)
+		resp.SetRcode(req, dns.RcodeServerFailure)
+		return resp
+	}
+	if text == "" {
+		resp.SetRcode(req, dns.RcodeNameError)
+		resp.Ns = append(resp.Ns, f.soa(zone))
+		return resp
+	}
+
+	if question.Qtype != dns.TypeTXT && question.Qtype != dns.TypeANY {
+		resp.Ns = append(resp.Ns, f.soa(zone))
+		return resp
+	}
+	resp.Answer = append(resp.Answer, &dns.TXT{
+		Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: f.ttl},
+		Txt: splitTXT(text),
+	})
+	return resp
+}
+
+// zoneOf returns the zone name belongs to and the part of name before the zone.
+func (f *dnsFrontend) zoneOf(name string) (zone, subdomain string, ok bool) {
+	for _, zone := range f.zones {
+		if name == zone {
+			return zone, "", true
+		}
+		if strings.HasSuffix(name, "."+zone) {
+			return zone, strings.TrimSuffix(name, "."+zone), true
+		}
+	}
+	return "", "", false
+}
+
+// lookup returns the TXT record of a name below a zone, or an empty string if the name does not exist.
+func (f *dnsFrontend) lookup(ctx context.Context, zone, subdomain string) (string, error) {
+	switch zone {
+	case f.originZone, f.origin6Zone:
+		var ip net.IP
+		if zone == f.originZone {
+			ip = parseReversedIPv4(subdomain)
+		} else {
+			ip = parseReversedIPv6(subdomain)
+		}
+		if ip == nil || common.IsBogon(ip) {
+			return "", nil
+		}
+		origin, err := common.LookupOrigin(ctx, f.geoIP, ip)
+		if err != nil || origin == nil {
+			return "", err
+		}
+		return strings.Join([]string{strconv.FormatUint(uint64(origin.ASN), 10), origin.Prefix, origin.Country, "", ""}, " | "), nil
+	default:
+		if strings.Contains(subdomain, ".") || !strings.HasPrefix(subdomain, "as") {
+			return "", nil
+		}
+		asn, err := parseASN(subdomain)
+		if err != nil {
+			return "", nil
+		}
+		data, err := common.LookupASNData(ctx, f.geoIP, asn)
+		if err != nil {
+			// The ASN announces no prefixes.
+			return "", nil
+		}
+		return strings.Join([]string{strconv.FormatUint(uint64(asn), 10), asnCountry(ctx, f.geoIP, asn), "", "", data.Details.Name}, " | "), nil
+	}
+}
+
+// soa returns the SOA record of a zone, which also sets the negative TTL. The serial is the build time
+// of the ASN database.
+func (f *dnsFrontend) soa(zone string) *dns.SOA {
+	var serial uint32
+	if asnDB := f.geoIP.GetASNDB(); asnDB != nil {
+		serial = uint32(asnDB.Metadata.BuildEpoch)
+	}
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: f.negativeTTL},
+		Ns:      "ns." + zone,
+		Mbox:    "hostmaster." + zone,
+		Serial:  serial,
+		Refresh: soaRefresh,
+		Retry:   soaRetry,
+		Expire:  soaExpire,
+		Minttl:  f.negativeTTL,
+	}
+}
+
+// parseReversedIPv4 parses up to four reversed octets, such as "4.4.8.8" for 8.8.4.4. Missing trailing
+// octets are zero.
+func parseReversedIPv4(labels string) net.IP {
+	octets := strings.Split(labels, ".")
+	if len(octets) > net.IPv4len {
+		return nil
+	}
+	ip := make(net.IP, net.IPv4len)
+	for i, octet := range octets {
+		n, err := strconv.ParseUint(octet, 10, 8)
+		if err != nil || (len(octet) > 1 && octet[0] == '0') {
+			return nil
+		}
+		ip[len(octets)-1-i] = byte(n)
+	}
+	return ip
+}
+
+// parseReversedIPv6 parses up to 32 reversed hex nibbles, as in ip6.arpa names. Missing trailing nibbles
+// are zero.
+func parseReversedIPv6(labels string) net.IP {
+	nibbles := strings.Split(labels, ".")
+	if len(nibbles) > 2*net.IPv6len {
+		return nil
+	}
+	digits := make([]byte, 2*net.IPv6len)
+	for i := range digits {
+		digits[i] = '0'
+	}
+	for i, nibble := range nibbles {
+		if len(nibble) != 1 {
+			return nil
+		}
+		digits[len(nibbles)-1-i] = nibble[0]
+	}
+	ip, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return nil
+	}
+	return ip
+}
+
+// splitTXT splits text into the 255-byte strings a TXT record is made of.
+func splitTXT(text string) []string {
+	var parts []string
+	for len(text) > 255 {
+		parts = append(parts, text[:255])
+		text = text[255:]
+	}
+	return append(parts, text)
+}
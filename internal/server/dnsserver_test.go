@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+
+	"github.com/miekg/dns"
+)
+
+// startTestDNS serves the test databases on 127.0.0.1 with the default zones and returns the UDP and TCP
+// addresses.
+func startTestDNS(t *testing.T) (f *dnsFrontend, udpAddr, tcpAddr string) {
+	t.Helper()
+	cfg := config.Default().DNSServer
+	cfg.Address = "127.0.0.1:0"
+	f = newDNSFrontend(newTestGeoIP(t), cfg, newAccessLogger(config.Default().Log))
+	if err := f.start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		f.shutdown(ctx)
+	})
+	return f, f.servers[0].PacketConn.LocalAddr().String(), f.servers[1].Listener.Addr().String()
+}
+
+// exchange sends a query and fails the test if no response arrives.
+func exchange(t *testing.T, client *dns.Client, addr string, msg *dns.Msg) *dns.Msg {
+	t.Helper()
+	resp, _, err := client.Exchange(msg, addr)
+	if err != nil {
+		t.Fatalf("exchange %v: %v", msg.Question, err)
+	}
+	return resp
+}
+
+func TestDNSServer(t *testing.T) {
+	f, udpAddr, tcpAddr := startTestDNS(t)
+	client := &dns.Client{Timeout: 5 * time.Second}
+
+	tests := []struct {
+		name  string
+		qname string
+		qtype uint16
+		rcode int
+		// txt is the expected TXT answer; soa is whether the authority section holds the zone's SOA.
+		txt string
+		soa string
+	}{
+		{"ipv4 origin", "8.8.8.8.origin.asn.example.", dns.TypeTXT, dns.RcodeSuccess, "15169 | 8.8.8.0/24 | US |  | ", ""},
+		{"ipv4 origin with missing octets", "8.8.8.origin.asn.example.", dns.TypeTXT, dns.RcodeSuccess, "15169 | 8.8.8.0/24 | US |  | ", ""},
+		{"ipv4 origin of another network", "1.1.1.1.Origin.ASN.Example.", dns.TypeTXT, dns.RcodeSuccess, "13335 | 1.1.1.0/24 | AU |  | ", ""},
+		{"ipv6 origin", "8.8.8.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.6.8.4.0.6.8.4.1.0.0.2.origin6.asn.example.", dns.TypeTXT, dns.RcodeSuccess, "15169 | 2001:4860::/32 | US |  | ", ""},
+		{"ipv6 origin with missing nibbles", "0.6.8.4.1.0.0.2.origin6.asn.example.", dns.TypeTXT, dns.RcodeSuccess, "15169 | 2001:4860::/32 | US |  | ", ""},
+		{"asn", "AS15169.asn.example.", dns.TypeTXT, dns.RcodeSuccess, "15169 | US |  |  | Google LLC", ""},
+		{"any query", "as13335.asn.example.", dns.TypeANY, dns.RcodeSuccess, "13335 | AU |  |  | Cloudflare, Inc.", ""},
+		{"address not in the database", "9.9.9.9.origin.asn.example.", dns.TypeTXT, dns.RcodeNameError, "", "origin.asn.example."},
+		{"bogon", "1.0.0.10.origin.asn.example.", dns.TypeTXT, dns.RcodeNameError, "", "origin.asn.example."},
+		{"leading zero", "08.8.8.8.origin.asn.example.", dns.TypeTXT, dns.RcodeNameError, "", "origin.asn.example."},
+		{"too many octets", "1.8.8.8.8.origin.asn.example.", dns.TypeTXT, dns.RcodeNameError, "", "origin.asn.example."},
+		{"unknown asn", "AS64999.asn.example.", dns.TypeTXT, dns.RcodeNameError, "", "asn.example."},
+		{"asn without prefix", "15169.asn.example.", dns.TypeTXT, dns.RcodeNameError, "", "asn.example."},
+		{"nested name in asn zone", "x.AS15169.asn.example.", dns.TypeTXT, dns.RcodeNameError, "", "asn.example."},
+		{"nodata for other types", "8.8.8.8.origin.asn.example.", dns.TypeA, dns.RcodeSuccess, "", "origin.asn.example."},
+		{"nodata at the apex", "origin6.asn.example.", dns.TypeTXT, dns.RcodeSuccess, "", "origin6.asn.example."},
+		{"outside the zones", "8.8.8.8.in-addr.arpa.", dns.TypeTXT, dns.RcodeRefused, "", ""},
+		{"suffix without a dot", "8.8.8.8.notasn.example.", dns.TypeTXT, dns.RcodeRefused, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := exchange(t, client, udpAddr, new(dns.Msg).SetQuestion(tt.qname, tt.qtype))
+			if resp.Rcode != tt.rcode {
+				t.Fatalf("rcode %s, want %s", dns.RcodeToString[resp.Rcode], dns.RcodeToString[tt.rcode])
+			}
+			if tt.rcode != dns.RcodeRefused && !resp.Authoritative {
+				t.Error("answer is not authoritative")
+			}
+
+			var txt []string
+			for _, rr := range resp.Answer {
+				if record, ok := rr.(*dns.TXT); ok {
+					txt = append(txt, strings.Join(record.Txt, ""))
+					if record.Hdr.Ttl != uint32(time.Hour.Seconds()) {
+						t.Errorf("TXT TTL %d, want the configured TTL", record.Hdr.Ttl)
+					}
+				}
+			}
+			if tt.txt == "" && len(txt) > 0 || tt.txt != "" && (len(txt) != 1 || txt[0] != tt.txt) {
+				t.Errorf("TXT %q, want %q", txt, tt.txt)
+			}
+
+			var soa *dns.SOA
+			if len(resp.Ns) == 1 {
+				soa, _ = resp.Ns[0].(*dns.SOA)
+			}
+			switch {
+			case tt.soa == "" && len(resp.Ns) > 0:
+				t.Errorf("authority %v, want none", resp.Ns)
+			case tt.soa != "" && (soa == nil || soa.Hdr.Name != tt.soa):
+				t.Errorf("authority %v, want the SOA of %s", resp.Ns, tt.soa)
+			case soa != nil && (soa.Minttl != uint32(f.negativeTTL) || soa.Serial != testBuildEpoch):
+				t.Errorf("SOA minimum %d serial %d, want the negative TTL and database build time", soa.Minttl, soa.Serial)
+			}
+		})
+	}
+
+	t.Run("soa at the apex", func(t *testing.T) {
+		resp := exchange(t, client, udpAddr, new(dns.Msg).SetQuestion("asn.example.", dns.TypeSOA))
+		if len(resp.Answer) != 1 || resp.Answer[0].Header().Rrtype != dns.TypeSOA || resp.Answer[0].Header().Name != "asn.example." {
+			t.Errorf("answer %v, want the SOA of asn.example.", resp.Answer)
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		tcp := &dns.Client{Net: "tcp", Timeout: 5 * time.Second}
+		resp := exchange(t, tcp, tcpAddr, new(dns.Msg).SetQuestion("8.8.8.8.origin.asn.example.", dns.TypeTXT))
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+			t.Errorf("rcode %s answer %v", dns.RcodeToString[resp.Rcode], resp.Answer)
+		}
+	})
+
+	t.Run("chaos class", func(t *testing.T) {
+		msg := new(dns.Msg).SetQuestion("8.8.8.8.origin.asn.example.", dns.TypeTXT)
+		msg.Question[0].Qclass = dns.ClassCHAOS
+		if resp := exchange(t, client, udpAddr, msg); resp.Rcode != dns.RcodeRefused {
+			t.Errorf("rcode %s, want REFUSED", dns.RcodeToString[resp.Rcode])
+		}
+	})
+
+	t.Run("two questions", func(t *testing.T) {
+		msg := new(dns.Msg).SetQuestion("8.8.8.8.origin.asn.example.", dns.TypeTXT)
+		msg.Question = append(msg.Question, msg.Question[0])
+		if resp := exchange(t, client, udpAddr, msg); resp.Rcode != dns.RcodeFormatError {
+			t.Errorf("rcode %s, want FORMERR", dns.RcodeToString[resp.Rcode])
+		}
+	})
+
+	t.Run("edns", func(t *testing.T) {
+		msg := new(dns.Msg).SetQuestion("8.8.8.8.origin.asn.example.", dns.TypeTXT)
+		msg.SetEdns0(4096, false)
+		if resp := exchange(t, client, udpAddr, msg); resp.IsEdns0() == nil {
+			t.Error("response to an EDNS query has no OPT record")
+		}
+	})
+}
+
+func TestDNSZoneOf(t *testing.T) {
+	cfg := config.Default().DNSServer
+	f := newDNSFrontend(nil, cfg, newAccessLogger(config.Default().Log))
+
+	tests := []struct {
+		name, zone, subdomain string
+		ok                    bool
+	}{
+		// The origin zones lie below the asn zone, and the longest matching zone wins.
+		{"4.4.8.8.origin.asn.example.", "origin.asn.example.", "4.4.8.8", true},
+		{"1.0.0.2.origin6.asn.example.", "origin6.asn.example.", "1.0.0.2", true},
+		{"as15169.asn.example.", "asn.example.", "as15169", true},
+		{"origin.asn.example.", "origin.asn.example.", "", true},
+		{"asn.example.", "asn.example.", "", true},
+		{"x.notorigin.asn.example.", "asn.example.", "x.notorigin", true},
+		{"example.", "", "", false},
+		{"fooasn.example.", "", "", false},
+	}
+	for _, tt := range tests {
+		zone, subdomain, ok := f.zoneOf(tt.name)
+		if zone != tt.zone || subdomain != tt.subdomain || ok != tt.ok {
+			t.Errorf("zoneOf(%q) = %q, %q, %v, want %q, %q, %v", tt.name, zone, subdomain, ok, tt.zone, tt.subdomain, tt.ok)
+		}
+	}
+}
+
+func TestParseReversedIPv4(t *testing.T) {
+	tests := []struct {
+		labels string
+		want   string
+	}{
+		{"4.4.8.8", "8.8.4.4"},
+		{"8.8.8", "8.8.8.0"},
+		{"10", "10.0.0.0"},
+		{"0.0.0.0", "0.0.0.0"},
+		{"255.255.255.255", "255.255.255.255"},
+		{"04.4.8.8", ""},
+		{"00.4.8.8", ""},
+		{"256.4.8.8", ""},
+		{"-1.4.8.8", ""},
+		{"+1.4.8.8", ""},
+		{"1.2.3.4.5", ""},
+		{"a.4.8.8", ""},
+		{"4..8.8", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := parseReversedIPv4(tt.labels)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("parseReversedIPv4(%q) = %s, want nil", tt.labels, got)
+			}
+			continue
+		}
+		if !got.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("parseReversedIPv4(%q) = %s, want %s", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestParseReversedIPv6(t *testing.T) {
+	full := "8.8.8.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.6.8.4.0.6.8.4.1.0.0.2"
+	tests := []struct {
+		labels string
+		want   string
+	}{
+		{full, "2001:4860:4860::8888"},
+		{"0.6.8.4.1.0.0.2", "2001:4860::"},
+		{"2", "2000::"},
+		{"F.E.D.C", "cdef::"},
+		{"0." + full, ""},
+		{"10.0.0.2", ""},
+		{"g.0.0.2", ""},
+		{"1..0.2", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := parseReversedIPv6(tt.labels)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("parseReversedIPv6(%q) = %s, want nil", tt.labels, got)
+			}
+			continue
+		}
+		if !got.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("parseReversedIPv6(%q) = %s, want %s", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestSplitTXT(t *testing.T) {
+	text := strings.Repeat("a", 600)
+	parts := splitTXT(text)
+	if len(parts) != 3 || len(parts[0]) != 255 || len(parts[1]) != 255 || strings.Join(parts, "") != text {
+		t.Errorf("splitTXT of 600 bytes gave parts of %d, want 255, 255, 90", len(parts))
+	}
+	if parts := splitTXT(""); len(parts) != 1 || parts[0] != "" {
+		t.Errorf("splitTXT(\"\") = %q, want one empty string", parts)
+	}
+}
@@ -0,0 +1,321 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonEncoder is the default encoder used when the client expresses no preference.
+var jsonEncoder = &encoder{
+	name:        "json",
+	contentType: "application/json; charset=utf-8",
+	formats:     []string{"json"},
+	mediaTypes:  []string{"application/json"},
+	encode:      encodeJSON,
+}
+
+// textEscaper keeps multi-line values such as raw whois text on a single line.
+var textEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// cborMode encodes maps deterministically so identical data produces identical bytes.
+var cborMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+func init() {
+	registerEncoder(jsonEncoder)
+	registerEncoder(&encoder{
+		name:        "text",
+		contentType: "text/plain; charset=utf-8",
+		formats:     []string{"text", "txt", "plain"},
+		mediaTypes:  []string{"text/plain"},
+		encode:      encodeText,
+	})
+	registerEncoder(&encoder{
+		name:        "csv",
+		contentType: "text/csv; charset=utf-8",
+		formats:     []string{"csv"},
+		mediaTypes:  []string{"text/csv"},
+		encode:      encodeCSV,
+	})
+	registerEncoder(&encoder{
+		name:        "yaml",
+		contentType: "application/yaml; charset=utf-8",
+		formats:     []string{"yaml", "yml"},
+		mediaTypes:  []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		encode:      encodeYAML,
+	})
+	registerEncoder(&encoder{
+		name:        "xml",
+		contentType: "application/xml; charset=utf-8",
+		formats:     []string{"xml"},
+		mediaTypes:  []string{"application/xml", "text/xml"},
+		encode:      encodeXML,
+	})
+	registerEncoder(&encoder{
+		name:        "msgpack",
+		contentType: "application/msgpack",
+		formats:     []string{"msgpack", "messagepack"},
+		mediaTypes:  []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		encode:      encodeMsgpack,
+	})
+	registerEncoder(&encoder{
+		name:        "cbor",
+		contentType: "application/cbor",
+		formats:     []string{"cbor"},
+		mediaTypes:  []string{"application/cbor"},
+		encode:      encodeCBOR,
+	})
+}
+
+// encodeJSON writes data as JSON, indented unless pretty output was disabled.
+func encodeJSON(w io.Writer, data any, pretty bool) error {
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(data)
+}
+
+// encodeText writes one key=value line per leaf, using dotted keys for nested fields.
+func encodeText(w io.Writer, data any, _ bool) error {
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, field := range flatten("", tree, nil) {
+		if field.key != "" {
+			b.WriteString(field.key)
+			b.WriteByte('=')
+		}
+		b.WriteString(textEscaper.Replace(field.value))
+		b.WriteByte('\n')
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// encodeCSV writes a header row of dotted keys followed by one row per record.
+// Lists are joined with semicolons so every record stays on a single row.
+func encodeCSV(w io.Writer, data any, _ bool) error {
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+
+	rows, ok := tree.([]any)
+	if !ok {
+		rows = []any{tree}
+	}
+
+	var header []string
+	columns := make(map[string]struct{})
+	records := make([]map[string][]string, len(rows))
+	for i, row := range rows {
+		records[i] = csvRecord(row, &header, columns)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := writer.Write(csvLine(header, record)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvRecord flattens a row into its values by column, appending columns not seen before to header.
+func csvRecord(row any, header *[]string, columns map[string]struct{}) map[string][]string {
+	record := make(map[string][]string)
+	for _, field := range flatten("", row, nil) {
+		key := field.key
+		if key == "" {
+			key = "value"
+		}
+		if _, ok := columns[key]; !ok {
+			columns[key] = struct{}{}
+			*header = append(*header, key)
+		}
+		record[key] = append(record[key], field.value)
+	}
+	return record
+}
+
+// csvLine lays out a record in header order, joining list values with semicolons.
+func csvLine(header []string, record map[string][]string) []string {
+	line := make([]string, len(header))
+	for i, key := range header {
+		line[i] = strings.Join(record[key], ";")
+	}
+	return line
+}
+
+// encodeYAML writes data as a YAML document, keeping the field order of the JSON output.
+func encodeYAML(w io.Writer, data any, _ bool) error {
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+
+	node, err := yamlNode(tree)
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// yamlNode converts a tree into a YAML node.
+func yamlNode(v any) (*yaml.Node, error) {
+	switch t := v.(type) {
+	case orderedMap:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, entry := range t {
+			value, err := yamlNode(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.Key}
+			node.Content = append(node.Content, key, value)
+		}
+		return node, nil
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range t {
+			value, err := yamlNode(item)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		return node, nil
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// encodeXML writes data as an XML document with a <response> root element.
+// Lists repeat their element, and keys that are not valid XML names become <item key="...">.
+func encodeXML(w io.Writer, data any, pretty bool) error {
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+	if list, ok := tree.([]any); ok {
+		tree = orderedMap{{Key: "item", Value: list}}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	if pretty {
+		encoder.Indent("", "  ")
+	}
+	if err := writeXMLElement(encoder, "response", tree); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// writeXMLElement writes a tree value as one or more XML elements.
+func writeXMLElement(encoder *xml.Encoder, name string, v any) error {
+	start := xmlStartElement(name)
+
+	switch t := v.(type) {
+	case []any:
+		for _, item := range t {
+			if err := writeXMLElement(encoder, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case orderedMap:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, entry := range t {
+			if err := writeXMLElement(encoder, entry.Key, entry.Value); err != nil {
+				return err
+			}
+		}
+		return encoder.EncodeToken(start.End())
+	}
+	return encoder.EncodeElement(scalarString(v), start)
+}
+
+// xmlStartElement returns the start element for a key, falling back to <item key="...">.
+func xmlStartElement(name string) xml.StartElement {
+	if isXMLName(name) {
+		return xml.StartElement{Name: xml.Name{Local: name}}
+	}
+	return xml.StartElement{
+		Name: xml.Name{Local: "item"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+	}
+}
+
+// isXMLName reports whether name can be used as an XML element name as is.
+func isXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// encodeMsgpack writes data as MessagePack.
+func encodeMsgpack(w io.Writer, data any, _ bool) error {
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+
+	encoder := msgpack.NewEncoder(w)
+	encoder.SetSortMapKeys(true)
+	encoder.UseCompactInts(true)
+	return encoder.Encode(plainTree(tree))
+}
+
+// encodeCBOR writes data as CBOR.
+func encodeCBOR(w io.Writer, data any, _ bool) error {
+	tree, err := toTree(data)
+	if err != nil {
+		return err
+	}
+	return cborMode.NewEncoder(w).Encode(plainTree(tree))
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"ipinfo/internal/config"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// encoderSample has the shapes responses are made of: nested objects, lists, missing values and
+// multi-line text.
+type encoderSample struct {
+	IP      string   `json:"ip"`
+	ASN     uint     `json:"asn"`
+	Bogon   bool     `json:"bogon"`
+	Postal  *string  `json:"postal"`
+	Tags    []string `json:"tags"`
+	Details struct {
+		Name string  `json:"name"`
+		Lat  float64 `json:"lat"`
+	} `json:"details"`
+	Raw string `json:"raw"`
+}
+
+func newEncoderSample() encoderSample {
+	sample := encoderSample{IP: "8.8.8.8", ASN: 15169, Tags: []string{"dns", "anycast"}, Raw: "line 1\nline 2"}
+	sample.Details.Name = "Google LLC"
+	sample.Details.Lat = 37.386
+	return sample
+}
+
+func TestEncoders(t *testing.T) {
+	tests := []struct {
+		format string
+		pretty bool
+		want   string
+	}{
+		{"json", false, `{"ip":"8.8.8.8","asn":15169,"bogon":false,"postal":null,"tags":["dns","anycast"],"details":{"name":"Google LLC","lat":37.386},"raw":"line 1\nline 2"}` + "\n"},
+		{"text", false, "ip=8.8.8.8\nasn=15169\nbogon=false\npostal=\ntags=dns\ntags=anycast\ndetails.name=Google LLC\ndetails.lat=37.386\nraw=line 1\\nline 2\n"},
+		{"csv", false, "ip,asn,bogon,postal,tags,details.name,details.lat,raw\n8.8.8.8,15169,false,,dns;anycast,Google LLC,37.386,\"line 1\nline 2\"\n"},
+		{"yaml", false, "ip: 8.8.8.8\nasn: 15169\nbogon: false\npostal: null\ntags:\n  - dns\n  - anycast\ndetails:\n  name: Google LLC\n  lat: 37.386\nraw: |-\n  line 1\n  line 2\n"},
+		{"xml", false, `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><ip>8.8.8.8</ip><asn>15169</asn><bogon>false</bogon><postal></postal><tags>dns</tags><tags>anycast</tags><details><name>Google LLC</name><lat>37.386</lat></details><raw>line 1&#xA;line 2</raw></response>` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tt.format, newEncoderSample(), tt.pretty); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+
+	// The binary formats decode to the same values as the JSON output.
+	want := map[string]any{
+		"ip": "8.8.8.8", "asn": uint64(15169), "bogon": false, "postal": nil, "tags": []any{"dns", "anycast"},
+		"details": map[string]any{"name": "Google LLC", "lat": 37.386}, "raw": "line 1\nline 2",
+	}
+	for format, decode := range map[string]func([]byte, *map[string]any) error{
+		"msgpack": func(b []byte, v *map[string]any) error { return msgpack.Unmarshal(b, v) },
+		"cbor":    func(b []byte, v *map[string]any) error { return cbor.Unmarshal(b, v) },
+	} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, format, newEncoderSample(), false); err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if err := decode(buf.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(normalizeNumbers(got), normalizeNumbers(want)) {
+			t.Errorf("%s decoded to %v, want %v", format, got, want)
+		}
+	}
+
+	if err := Encode(&bytes.Buffer{}, "pdf", newEncoderSample(), false); err == nil {
+		t.Error("unsupported format accepted")
+	}
+}
+
+// normalizeNumbers converts the integer types the binary decoders choose to uint64, and the string
+// keyed maps of CBOR to map[string]any.
+func normalizeNumbers(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for key, value := range t {
+			t[key] = normalizeNumbers(value)
+		}
+		return t
+	case map[any]any:
+		m := make(map[string]any, len(t))
+		for key, value := range t {
+			m[key.(string)] = normalizeNumbers(value)
+		}
+		return m
+	case []any:
+		for i, item := range t {
+			t[i] = normalizeNumbers(item)
+		}
+		return t
+	case int8, int16, int32, int64, uint8, uint16, uint32:
+		return reflect.ValueOf(t).Convert(reflect.TypeOf(uint64(0))).Interface()
+	}
+	return v
+}
+
+func TestNegotiateEncoder(t *testing.T) {
+	tests := []struct {
+		query  string
+		accept string
+		want   string
+	}{
+		{"", "", "json"},
+		{"", "*/*", "json"},
+		{"", "text/csv", "csv"},
+		{"", "application/x-yaml", "yaml"},
+		{"", "application/xml;q=0.5, application/cbor", "cbor"},
+		{"", "text/plain;q=0.2, application/msgpack;q=0.9", "msgpack"},
+		{"", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "json"},
+		{"", "image/png", "json"},
+		{"?format=TXT", "application/xml", "text"},
+		{"?format=yml", "", "yaml"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/8.8.8.8"+tt.query, nil)
+		req.Header.Set("Accept", tt.accept)
+		enc, err := negotiateEncoder(req)
+		if err != nil {
+			t.Errorf("%q %q: %v", tt.query, tt.accept, err)
+			continue
+		}
+		if enc.name != tt.want {
+			t.Errorf("%q %q: got %s, want %s", tt.query, tt.accept, enc.name, tt.want)
+		}
+	}
+
+	if _, err := negotiateEncoder(httptest.NewRequest(http.MethodGet, "/8.8.8.8?format=pdf", nil)); err == nil {
+		t.Error("unsupported format accepted")
+	}
+}
+
+func TestResponseFormats(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+
+	tests := []struct {
+		target      string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"/8.8.8.8", "", http.StatusOK, "application/json; charset=utf-8"},
+		{"/8.8.8.8?format=csv", "", http.StatusOK, "text/csv; charset=utf-8"},
+		{"/AS15169", "application/yaml", http.StatusOK, "application/yaml; charset=utf-8"},
+		{"/8.8.8.0/24", "application/cbor", http.StatusOK, "application/cbor"},
+		{"/8.8.8.8?format=pdf", "", http.StatusBadRequest, "application/json; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s %q: status %d, content type %q, want %d %q", tt.target, tt.accept, rec.Code, rec.Header().Get("Content-Type"), tt.status, tt.contentType)
+		}
+	}
+
+	// Compact JSON is one line.
+	rec := serveTest(router, http.MethodGet, "/8.8.8.8?pretty=false", "", "")
+	if body := rec.Body.Bytes(); bytes.Count(body, []byte("\n")) != 1 {
+		t.Errorf("pretty=false body is not a single line: %s", body)
+	}
+}
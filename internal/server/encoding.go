@@ -0,0 +1,248 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// encodeFunc writes data to w in a specific format.
+type encodeFunc func(w io.Writer, data any, pretty bool) error
+
+// encoder describes a response format that can be selected by the client.
+type encoder struct {
+	name        string
+	contentType string
+	formats     []string
+	mediaTypes  []string
+	encode      encodeFunc
+}
+
+var (
+	encoders            []*encoder
+	encodersByFormat    = make(map[string]*encoder)
+	encodersByMediaType = make(map[string]*encoder)
+)
+
+// registerEncoder makes an encoder selectable through ?format= and the Accept header.
+func registerEncoder(enc *encoder) {
+	encoders = append(encoders, enc)
+	for _, format := range enc.formats {
+		encodersByFormat[format] = enc
+	}
+	for _, mediaType := range enc.mediaTypes {
+		encodersByMediaType[mediaType] = enc
+	}
+}
+
+// negotiateEncoder selects the response encoder from the format query parameter or the Accept header.
+func negotiateEncoder(r *http.Request) (*encoder, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if enc, ok := encodersByFormat[strings.ToLower(format)]; ok {
+			return enc, nil
+		}
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	// Browsers list application/xml ahead of */*, so they keep getting the default format.
+	accept := r.Header.Get("Accept")
+	if accept == "" || strings.Contains(accept, "text/html") {
+		return jsonEncoder, nil
+	}
+
+	best, bestQ := jsonEncoder, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qValue, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qValue, 64); err != nil {
+				continue
+			}
+		}
+
+		enc, ok := encodersByMediaType[mediaType]
+		if mediaType == "*/*" || mediaType == "application/*" {
+			enc, ok = jsonEncoder, true
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best, nil
+}
+
+// wantsPretty reports whether the client asked for indented output; it defaults to true.
+func wantsPretty(r *http.Request) bool {
+	pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return err != nil || pretty
+}
+
+// orderedMap is a decoded JSON object that keeps the key order of the original struct.
+type orderedMap []mapEntry
+
+// mapEntry is a single key/value pair of an orderedMap.
+type mapEntry struct {
+	Key   string
+	Value any
+}
+
+// MarshalJSON encodes the map as a JSON object in its original key order.
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// toTree converts any response value into a generic tree of orderedMap, []any and scalars
+// using its JSON representation, so every encoder sees the same field names.
+func toTree(data any) (any, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	return decodeTree(decoder)
+}
+
+// decodeTree reads the next JSON value from the decoder into a generic tree.
+func decodeTree(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '{' {
+			m := orderedMap{}
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeTree(decoder)
+				if err != nil {
+					return nil, err
+				}
+				m = append(m, mapEntry{Key: keyToken.(string), Value: value})
+			}
+			_, err := decoder.Token()
+			return m, err
+		}
+
+		list := []any{}
+		for decoder.More() {
+			value, err := decodeTree(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		if f, err := t.Float64(); err == nil {
+			return f, nil
+		}
+		return t.String(), nil
+	}
+	return token, nil
+}
+
+// plainTree converts ordered maps into regular maps for encoders that do not preserve key order.
+func plainTree(v any) any {
+	switch t := v.(type) {
+	case orderedMap:
+		m := make(map[string]any, len(t))
+		for _, entry := range t {
+			m[entry.Key] = plainTree(entry.Value)
+		}
+		return m
+	case []any:
+		list := make([]any, len(t))
+		for i, item := range t {
+			list[i] = plainTree(item)
+		}
+		return list
+	}
+	return v
+}
+
+// flatField is a leaf value of a tree addressed by its dotted key path.
+type flatField struct {
+	key   string
+	value string
+}
+
+// flatten walks a tree and returns its leaves in order; list items repeat their parent key.
+func flatten(prefix string, v any, out []flatField) []flatField {
+	switch t := v.(type) {
+	case orderedMap:
+		for _, entry := range t {
+			key := entry.Key
+			if prefix != "" {
+				key = prefix + "." + entry.Key
+			}
+			out = flatten(key, entry.Value, out)
+		}
+	case []any:
+		for _, item := range t {
+			out = flatten(prefix, item, out)
+		}
+	default:
+		out = append(out, flatField{key: prefix, value: scalarString(t)})
+	}
+	return out
+}
+
+// scalarString formats a tree leaf as text.
+func scalarString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case uint64:
+		return strconv.FormatUint(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
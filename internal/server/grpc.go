@@ -0,0 +1,438 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/config"
+	"ipinfo/internal/db"
+	"ipinfo/internal/tracing"
+	ipinfov1 "ipinfo/proto/ipinfo/v1"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// grpcService implements the gRPC API with the same lookups as the HTTP API.
+type grpcService struct {
+	ipinfov1.UnimplementedIPInfoServiceServer
+	geoIP  *db.GeoIPManager
+	limits *rateLimiter
+}
+
+// newGRPCServer creates a gRPC server with the lookup and health services, and the reflection service
+// if enabled. Lookups are subject to the API keys and rate limits of the HTTP API, and calls are logged
+// to access. It serves TLS when tlsConfig is set.
+func newGRPCServer(geoIP *db.GeoIPManager, auth *authenticator, limits *rateLimiter, access *accessLogger, cfg config.GRPCConfig, tlsConfig *tls.Config) (*grpc.Server, *health.Server) {
+	guard := &grpcGuard{auth: auth, limits: limits}
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(access.grpcUnary, guard.unary),
+		grpc.ChainStreamInterceptor(access.grpcStream, guard.stream),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig.Clone())))
+	}
+
+	server := grpc.NewServer(options...)
+	ipinfov1.RegisterIPInfoServiceServer(server, &grpcService{geoIP: geoIP, limits: limits})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ipinfov1.IPInfoService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	if cfg.Reflection {
+		reflection.Register(server)
+	}
+	return server, healthServer
+}
+
+// LookupIP returns the location and network of an IP address.
+func (s *grpcService) LookupIP(ctx context.Context, req *ipinfov1.LookupIPRequest) (*ipinfov1.IPInfo, error) {
+	ip := net.ParseIP(req.GetIp())
+	if ip == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid ip address")
+	}
+	if common.IsBogon(ip) {
+		return &ipinfov1.IPInfo{Ip: ip.String(), Bogon: true}, nil
+	}
+
+	data := common.LookupIPData(ctx, s.geoIP, ip, matchLanguage(s.geoIP, req.GetLanguage(), ""))
+	if data == nil {
+		return nil, status.Error(codes.NotFound, "could not retrieve data for the specified ip")
+	}
+	return ipInfoProto(data), nil
+}
+
+// LookupASN returns the name and announced prefixes of an ASN.
+func (s *grpcService) LookupASN(ctx context.Context, req *ipinfov1.LookupASNRequest) (*ipinfov1.ASNInfo, error) {
+	if req.GetAsn() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid asn: must be a positive number")
+	}
+
+	data, err := common.LookupASNData(ctx, s.geoIP, uint(req.GetAsn()))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return asnInfoProto(data), nil
+}
+
+// LookupDomain returns the WHOIS and DNS records of a domain.
+func (s *grpcService) LookupDomain(ctx context.Context, req *ipinfov1.LookupDomainRequest) (*ipinfov1.DomainInfo, error) {
+	domain, err := normalizeDomain(req.GetDomain())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid domain name")
+	}
+
+	data, err := common.LookupDomainData(ctx, domain)
+	if err != nil {
+		slog.Error("failed to look up domain data", "domain", domain, "error", err)
+		return nil, status.Error(codes.Internal, "error retrieving data for domain")
+	}
+	return domainInfoProto(domain, data), nil
+}
+
+// BatchLookup answers every query on the stream in order, looking up a bounded number of queries at once.
+// Each query that is looked up is charged to the API key's quota, and the stream ends with
+// ResourceExhausted at the first query over quota. Queries outside the key's tier are answered with an error, and domain queries wait
+// for the domain rate limit, as in a batch request.
+func (s *grpcService) BatchLookup(stream ipinfov1.IPInfoService_BatchLookupServer) error {
+	ctx := stream.Context()
+	key := grpcAPIKey(ctx)
+	identity := grpcIdentity(ctx)
+	pending := make(chan chan *ipinfov1.BatchLookupResponse, streamConcurrency)
+	recvErr := make(chan error, 1)
+
+	go func() {
+		defer close(pending)
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					recvErr <- err
+				}
+				return
+			}
+			query := strings.TrimSpace(req.GetQuery())
+			permitErr := key.permits(query)
+			if permitErr == nil && queryEndpoint(query) != "" {
+				if retryAfter, ok := key.charge(1); !ok {
+					recvErr <- grpcRetryError(ctx, errQuotaExceeded.Error(), retryAfter)
+					return
+				}
+			}
+
+			result := make(chan *ipinfov1.BatchLookupResponse, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				if permitErr != nil {
+					result <- &ipinfov1.BatchLookupResponse{Query: req.GetQuery(), Result: &ipinfov1.BatchLookupResponse_Error{Error: permitErr.Error()}}
+					return
+				}
+				if err := s.limits.waitQuery(ctx, identity, query); err != nil {
+					result <- &ipinfov1.BatchLookupResponse{Query: req.GetQuery(), Result: &ipinfov1.BatchLookupResponse_Error{Error: err.Error()}}
+					return
+				}
+				result <- s.lookupBatchQuery(ctx, req)
+			}()
+		}
+	}()
+
+	for result := range pending {
+		if err := stream.Send(<-result); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case err := <-recvErr:
+		return err
+	default:
+		return nil
+	}
+}
+
+// lookupBatchQuery looks up a single query of a batch stream, reporting failures in the response.
+func (s *grpcService) lookupBatchQuery(ctx context.Context, req *ipinfov1.BatchLookupRequest) *ipinfov1.BatchLookupResponse {
+	query := strings.TrimSpace(req.GetQuery())
+	response := &ipinfov1.BatchLookupResponse{Query: req.GetQuery()}
+
+	data, err := lookupBatchItem(ctx, s.geoIP, query, matchLanguage(s.geoIP, req.GetLanguage(), ""))
+	if err != nil {
+		response.Result = &ipinfov1.BatchLookupResponse_Error{Error: err.Error()}
+		return response
+	}
+
+	switch data := data.(type) {
+	case *common.DataStruct:
+		response.Result = &ipinfov1.BatchLookupResponse_Ip{Ip: ipInfoProto(data)}
+	case *common.NetworkDataResponse:
+		response.Result = &ipinfov1.BatchLookupResponse_Network{Network: networkInfoProto(data)}
+	case *common.ASNDataResponse:
+		response.Result = &ipinfov1.BatchLookupResponse_Asn{Asn: asnInfoProto(data)}
+	case *common.DomainDataResponse:
+		domain, _ := normalizeDomain(query)
+		response.Result = &ipinfov1.BatchLookupResponse_Domain{Domain: domainInfoProto(domain, data)}
+	case bogonDataStruct:
+		if strings.Contains(data.IP, "/") {
+			response.Result = &ipinfov1.BatchLookupResponse_Network{Network: &ipinfov1.NetworkInfo{Network: data.IP, Bogon: true}}
+		} else {
+			response.Result = &ipinfov1.BatchLookupResponse_Ip{Ip: &ipinfov1.IPInfo{Ip: data.IP, Bogon: true}}
+		}
+	default:
+		response.Result = &ipinfov1.BatchLookupResponse_Error{Error: fmt.Sprintf("unsupported result %T", data)}
+	}
+	return response
+}
+
+// ipInfoProto converts IP data to its protobuf message.
+func ipInfoProto(data *common.DataStruct) *ipinfov1.IPInfo {
+	info := &ipinfov1.IPInfo{
+		Ip:                deref(data.IP),
+		Hostname:          deref(data.Hostname),
+		Asn:               uint32(deref(data.ASN)),
+		AsName:            deref(data.ASName),
+		Org:               deref(data.Org),
+		Network:           deref(data.Network),
+		City:              deref(data.City),
+		Region:            deref(data.Region),
+		Postal:            deref(data.Postal),
+		Country:           deref(data.Country),
+		CountryName:       deref(data.CountryName),
+		IsInEuropeanUnion: data.IsEU,
+		Continent:         deref(data.Continent),
+		ContinentCode:     deref(data.ContinentCode),
+		Timezone:          deref(data.Timezone),
+		Loc:               deref(data.Loc),
+		AccuracyRadius:    uint32(deref(data.AccuracyRadius)),
+	}
+	for _, subdivision := range data.Subdivisions {
+		info.Subdivisions = append(info.Subdivisions, &ipinfov1.Subdivision{
+			Code: deref(subdivision.Code),
+			Name: deref(subdivision.Name),
+		})
+	}
+	return info
+}
+
+// networkInfoProto converts network data to its protobuf message.
+func networkInfoProto(data *common.NetworkDataResponse) *ipinfov1.NetworkInfo {
+	return &ipinfov1.NetworkInfo{
+		Network:      data.Network,
+		PrefixLength: int32(data.PrefixLength),
+		FirstAddress: data.FirstAddress,
+		LastAddress:  data.LastAddress,
+		AddressCount: data.AddressCount.String(),
+		CityNetwork:  deref(data.CityNetwork),
+		AsnNetwork:   deref(data.ASNNetwork),
+		Org:          deref(data.Org),
+		Country:      deref(data.Country),
+	}
+}
+
+// asnInfoProto converts ASN data to its protobuf message.
+func asnInfoProto(data *common.ASNDataResponse) *ipinfov1.ASNInfo {
+	return &ipinfov1.ASNInfo{
+		Asn:          uint32(data.Details.ASN),
+		Name:         data.Details.Name,
+		Ipv4Prefixes: data.Prefixes.IPv4,
+		Ipv6Prefixes: data.Prefixes.IPv6,
+	}
+}
+
+// domainInfoProto converts domain data to its protobuf message.
+func domainInfoProto(domain string, data *common.DomainDataResponse) *ipinfov1.DomainInfo {
+	info := &ipinfov1.DomainInfo{
+		Domain: domain,
+		Dns: &ipinfov1.DNSRecords{
+			A:     data.DNS.A,
+			Aaaa:  data.DNS.AAAA,
+			Cname: data.DNS.CNAME,
+			Mx:    data.DNS.MX,
+			Txt:   data.DNS.TXT,
+			Ns:    data.DNS.NS,
+			Soa:   data.DNS.SOA,
+			Caa:   data.DNS.CAA,
+		},
+	}
+
+	switch whois := data.Whois.(type) {
+	case string:
+		info.Whois = &ipinfov1.Whois{Raw: whois}
+	case common.WhoisInfo:
+		info.Whois = &ipinfov1.Whois{
+			Registrant: whoisContactProto(whois.Registrant),
+			Admin:      whoisContactProto(whois.Admin),
+			Tech:       whoisContactProto(whois.Tech),
+		}
+		if d := whois.Domain; d != nil {
+			info.Whois.Domain = &ipinfov1.WhoisDomain{
+				Id:             d.ID,
+				Domain:         d.Domain,
+				WhoisServer:    d.WhoisServer,
+				Status:         d.Status,
+				NameServers:    d.NameServers,
+				Dnssec:         d.DNSSEC,
+				CreatedDate:    d.CreatedDate,
+				UpdatedDate:    d.UpdatedDate,
+				ExpirationDate: d.ExpirationDate,
+			}
+		}
+		if r := whois.Registrar; r != nil {
+			info.Whois.Registrar = &ipinfov1.WhoisRegistrar{
+				Id:          r.ID,
+				Name:        r.Name,
+				Email:       r.Email,
+				Phone:       r.Phone,
+				ReferralUrl: r.ReferralURL,
+			}
+		}
+	}
+	return info
+}
+
+// whoisContactProto converts a WHOIS contact to its protobuf message.
+func whoisContactProto(contact *common.WhoisContact) *ipinfov1.WhoisContact {
+	if contact == nil {
+		return nil
+	}
+	return &ipinfov1.WhoisContact{
+		Id:           contact.ID,
+		Name:         contact.Name,
+		Organization: contact.Organization,
+		Street:       contact.Street,
+		City:         contact.City,
+		Province:     contact.Province,
+		PostalCode:   contact.PostalCode,
+		Country:      contact.Country,
+		Phone:        contact.Phone,
+		Fax:          contact.Fax,
+		Email:        contact.Email,
+	}
+}
+
+// deref returns the value p points to, or the zero value if p is nil.
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// grpcUnary traces and logs each unary call like the access log does for HTTP requests.
+func (l *accessLogger) grpcUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, span, requestID := grpcStartCall(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	tracing.End(span, err)
+	l.logGRPCCall(ctx, info.FullMethod, requestID, err, time.Since(start))
+	return resp, err
+}
+
+// grpcStream traces and logs each streaming call.
+func (l *accessLogger) grpcStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, span, requestID := grpcStartCall(stream.Context(), info.FullMethod)
+	err := handler(srv, &grpcContextStream{ServerStream: stream, ctx: ctx})
+	tracing.End(span, err)
+	l.logGRPCCall(ctx, info.FullMethod, requestID, err, time.Since(start))
+	return err
+}
+
+// grpcStartCall starts the span of a call as a child of the trace context the client propagated in its
+// metadata, if any, and returns it with the call's request ID. The request ID is taken from the
+// x-request-id metadata like the X-Request-ID header of HTTP requests, or generated, and is sent back
+// in the response header.
+func grpcStartCall(ctx context.Context, fullMethod string) (context.Context, trace.Span, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, grpcMetadataCarrier(md))
+	ctx, span := tracing.Start(ctx, fullMethod, attribute.String("rpc.system", "grpc"))
+
+	var requestID string
+	if ids := md.Get(requestIDHeader); len(ids) > 0 && validRequestID(ids[0]) {
+		requestID = ids[0]
+	} else {
+		requestID = newRequestID()
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID)); err != nil {
+		slog.Debug("failed to set grpc request id header", "error", err)
+	}
+	return ctx, span, requestID
+}
+
+// logGRPCCall writes the log entry of a call. Calls that fail with a server error are logged at error level.
+func (l *accessLogger) logGRPCCall(ctx context.Context, fullMethod, requestID string, err error, duration time.Duration) {
+	code := status.Code(err)
+	level, ok := l.entryLevel(ctx, code == codes.Internal || code == codes.Unknown || code == codes.DataLoss)
+	if !ok {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("request_id", requestID),
+		slog.String("method", fullMethod),
+		slog.String("code", code.String()),
+		slog.Duration("duration", duration),
+		slog.String("client", grpcPeer(ctx)),
+	}
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		attrs = append(attrs, slog.String("trace_id", span.TraceID().String()))
+	}
+	slog.LogAttrs(ctx, level, "grpc request", attrs...)
+}
+
+// grpcMetadataCarrier adapts incoming call metadata to the propagation.TextMapCarrier interface.
+type grpcMetadataCarrier metadata.MD
+
+func (c grpcMetadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c grpcMetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c grpcMetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// grpcPeer returns the IP address of the client of a call.
+func grpcPeer(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
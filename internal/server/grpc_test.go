@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+	ipinfov1 "ipinfo/proto/ipinfo/v1"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGRPCCallLog(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	conn := dialTestGRPC(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+	client := ipinfov1.NewIPInfoServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		"x-request-id", "abc-123",
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	)
+	var header metadata.MD
+	if _, err := client.LookupIP(ctx, &ipinfov1.LookupIPRequest{Ip: "10.0.0.1"}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "abc-123" {
+		t.Errorf("x-request-id header %v, want abc-123", got)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry %q: %v", logs.String(), err)
+	}
+	want := map[string]any{
+		"msg":        "grpc request",
+		"request_id": "abc-123",
+		"method":     ipinfov1.IPInfoService_LookupIP_FullMethodName,
+		"code":       "OK",
+		"client":     "bufconn",
+		"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("log entry %s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["duration"].(float64); !ok {
+		t.Errorf("log entry has no duration: %v", entry)
+	}
+}
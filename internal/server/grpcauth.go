@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"time"
+
+	ipinfov1 "ipinfo/proto/ipinfo/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMethod is what a lookup method of the gRPC API is authorized and rate limited as.
+type grpcMethod struct {
+	endpoint string
+	class    string
+}
+
+// grpcMethods maps the lookup methods of the gRPC API to the endpoint API key tiers allow and the rate
+// limit class they are charged to. Other methods, such as health checks and reflection, are public.
+var grpcMethods = map[string]grpcMethod{
+	ipinfov1.IPInfoService_LookupIP_FullMethodName:     {endpoint: endpointIP, class: rateClassIP},
+	ipinfov1.IPInfoService_LookupASN_FullMethodName:    {endpoint: endpointASN, class: rateClassASN},
+	ipinfov1.IPInfoService_LookupDomain_FullMethodName: {endpoint: endpointDomain, class: rateClassDomain},
+	ipinfov1.IPInfoService_BatchLookup_FullMethodName:  {endpoint: endpointBatch, class: rateClassBulk},
+}
+
+// grpcGuard applies the API keys, tiers, quotas and rate limits of the HTTP API to gRPC calls. The key
+// is sent as "authorization: Bearer <key>" or "x-api-key" metadata.
+type grpcGuard struct {
+	auth   *authenticator
+	limits *rateLimiter
+}
+
+// unary checks a unary call before it is handled.
+func (g *grpcGuard) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := g.admit(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// stream checks a streaming call before it is handled.
+func (g *grpcGuard) stream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := g.admit(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &grpcContextStream{ServerStream: stream, ctx: ctx})
+}
+
+// admit checks a call the way the auth and rate limit middleware check a request, returning the context
+// carrying the call's API key.
+func (g *grpcGuard) admit(ctx context.Context, fullMethod string) (context.Context, error) {
+	method, ok := grpcMethods[fullMethod]
+	if !ok {
+		return ctx, nil
+	}
+
+	if g.auth.enabled() {
+		token := grpcToken(ctx)
+		if token == "" {
+			return ctx, status.Error(codes.Unauthenticated, "please provide an api key")
+		}
+		key, ok := g.auth.keys[sha256.Sum256([]byte(token))]
+		if !ok {
+			return ctx, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+		if !key.allows(method.endpoint) {
+			return ctx, status.Error(codes.PermissionDenied, "this api key cannot access this method")
+		}
+
+		// Batch calls are charged per query as the queries are looked up, so the call itself only needs
+		// quota left.
+		cost := int64(1)
+		if method.endpoint == endpointBatch {
+			cost = 0
+		}
+		if retryAfter, ok := g.auth.consume(key, time.Now(), cost); !ok {
+			return ctx, grpcRetryError(ctx, errQuotaExceeded.Error(), retryAfter)
+		}
+		ctx = context.WithValue(ctx, apiKeyContextKey{}, key)
+	}
+
+	if _, result := g.limits.take(ctx, grpcIdentity(ctx), method.class); !result.allowed {
+		return ctx, grpcRetryError(ctx, "rate limit exceeded", result.retryAfter)
+	}
+	return ctx, nil
+}
+
+// grpcRetryError returns a ResourceExhausted error, telling the client in the retry-after trailer how
+// many seconds to wait.
+func grpcRetryError(ctx context.Context, message string, retryAfter time.Duration) error {
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.Itoa(max(ceilSeconds(retryAfter), 1))))
+	return status.Error(codes.ResourceExhausted, message)
+}
+
+// grpcToken returns the API key from the authorization or x-api-key metadata of a call.
+func grpcToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcAPIKey returns the API key that authenticated a call, or nil.
+func grpcAPIKey(ctx context.Context) *apiKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*apiKey)
+	return key
+}
+
+// grpcIdentity identifies the client a call is counted against: its API key, or else its IP address.
+func grpcIdentity(ctx context.Context) string {
+	if key := grpcAPIKey(ctx); key != nil {
+		return "key:" + key.name
+	}
+	return "ip:" + grpcPeer(ctx)
+}
+
+// grpcContextStream is a server stream with the context of an admitted call.
+type grpcContextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcContextStream) Context() context.Context {
+	return s.ctx
+}
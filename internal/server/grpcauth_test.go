@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+	ipinfov1 "ipinfo/proto/ipinfo/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testKeysFile holds an IP-only key and a batch key, each with a small daily quota.
+const testKeysFile = `
+tiers:
+  ip-only:
+    endpoints: [ip]
+    daily_quota: 2
+  batch:
+    endpoints: [ip, batch, stream]
+    daily_quota: 3
+keys:
+  - name: ip
+    key: ip-secret
+    tier: ip-only
+  - name: batch
+    key: batch-secret
+    tier: batch
+`
+
+// newTestAuthenticator loads testKeysFile, or returns a disabled authenticator if keys is false.
+func newTestAuthenticator(t *testing.T, keys bool) *authenticator {
+	t.Helper()
+	dir := t.TempDir()
+	if !keys {
+		return newAuthenticator(config.AuthConfig{UsageFile: filepath.Join(dir, "usage.json")})
+	}
+	keysFile := filepath.Join(dir, "keys.yaml")
+	if err := os.WriteFile(keysFile, []byte(testKeysFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth := newAuthenticator(config.AuthConfig{KeysFile: keysFile, UsageFile: filepath.Join(dir, "usage.json")})
+	if err := auth.loadKeys(); err != nil {
+		t.Fatalf("loading keys: %v", err)
+	}
+	return auth
+}
+
+// dialTestGRPC serves the gRPC API in memory and returns a client connection to it.
+func dialTestGRPC(t *testing.T, auth *authenticator, limits config.RateLimitConfig) *grpc.ClientConn {
+	t.Helper()
+	server, _ := newGRPCServer(nil, auth, newRateLimiter(limits), newAccessLogger(config.Default().Log), config.GRPCConfig{}, nil)
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// withKey sends key as a bearer token in the authorization metadata.
+func withKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key)
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	conn := dialTestGRPC(t, newTestAuthenticator(t, true), config.RateLimitConfig{})
+	client := ipinfov1.NewIPInfoServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	bogon := &ipinfov1.LookupIPRequest{Ip: "10.0.0.1"}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		call func(ctx context.Context) error
+		want codes.Code
+	}{
+		{"no key", ctx, func(ctx context.Context) error { _, err := client.LookupIP(ctx, bogon); return err }, codes.Unauthenticated},
+		{"invalid key", withKey(ctx, "wrong"), func(ctx context.Context) error { _, err := client.LookupIP(ctx, bogon); return err }, codes.Unauthenticated},
+		{"x-api-key", metadata.AppendToOutgoingContext(ctx, "x-api-key", "ip-secret"), func(ctx context.Context) error { _, err := client.LookupIP(ctx, bogon); return err }, codes.OK},
+		{"outside tier", withKey(ctx, "ip-secret"), func(ctx context.Context) error {
+			_, err := client.LookupASN(ctx, &ipinfov1.LookupASNRequest{Asn: 13335})
+			return err
+		}, codes.PermissionDenied},
+		{"within quota", withKey(ctx, "ip-secret"), func(ctx context.Context) error { _, err := client.LookupIP(ctx, bogon); return err }, codes.OK},
+		{"health without key", ctx, func(ctx context.Context) error {
+			_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			return err
+		}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call(tt.ctx)); got != tt.want {
+				t.Errorf("code %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Both calls of the ip key's daily quota are used up above.
+	var trailer metadata.MD
+	_, err := client.LookupIP(withKey(ctx, "ip-secret"), bogon, grpc.Trailer(&trailer))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("call over quota: %v, want ResourceExhausted", err)
+	}
+	if len(trailer.Get("retry-after")) != 1 {
+		t.Errorf("call over quota has no retry-after trailer: %v", trailer)
+	}
+}
+
+func TestGRPCBatchQuota(t *testing.T) {
+	conn := dialTestGRPC(t, newTestAuthenticator(t, true), config.RateLimitConfig{})
+	client := ipinfov1.NewIPInfoServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.BatchLookup(withKey(ctx, "batch-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The quota of 3 covers the three IPs; the ASN is outside the tier and costs nothing.
+	for _, query := range []string{"10.0.0.1", "AS13335", "192.168.0.1", "10.0.0.2", "10.0.0.3"} {
+		if err := stream.Send(&ipinfov1.BatchLookupRequest{Query: query}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []*ipinfov1.BatchLookupResponse
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			if status.Code(err) != codes.ResourceExhausted {
+				t.Errorf("stream ended with %v, want ResourceExhausted", err)
+			}
+			break
+		}
+		results = append(results, res)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	if results[0].GetIp() == nil || !results[0].GetIp().GetBogon() {
+		t.Errorf("first result %v, want bogon ip", results[0])
+	}
+	if results[1].GetError() == "" {
+		t.Errorf("ASN result %v, want tier error", results[1])
+	}
+	if results[2].GetIp() == nil || results[3].GetIp() == nil {
+		t.Errorf("results %v, want ips after the ASN", results[2:])
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	perMinute := config.RateLimit{Limit: 1, Window: time.Minute}
+	conn := dialTestGRPC(t, newTestAuthenticator(t, false), config.RateLimitConfig{IP: perMinute, Bulk: config.RateLimit{Limit: 10, Window: time.Minute}})
+	client := ipinfov1.NewIPInfoServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := client.LookupIP(ctx, &ipinfov1.LookupIPRequest{Ip: "10.0.0.1"}); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if _, err := client.LookupIP(ctx, &ipinfov1.LookupIPRequest{Ip: "10.0.0.1"}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second call: %v, want ResourceExhausted", err)
+	}
+
+	// IP queries of a batch are only limited by the bulk class, so the empty IP bucket does not stop them.
+	stream, err := client.BatchLookup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&ipinfov1.BatchLookupRequest{Query: "10.0.0.2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetIp() == nil || !res.GetIp().GetBogon() {
+		t.Errorf("batch query %v, want bogon ip", res)
+	}
+	if _, err := stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Errorf("stream end: %v, want EOF", err)
+	}
+}
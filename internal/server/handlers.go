@@ -1,19 +1,21 @@
 package server
 
 import (
+	"errors"
 	"log/slog"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"ipinfo/internal/common"
 	"ipinfo/internal/db"
-
-	"golang.org/x/net/idna"
 )
 
-const favicon = `<svg xmlns="http://www.w3.org/2000/svg" width="16" height="16"></svg>`
+// favicon is a globe, drawn in the accent color of the HTML pages.
+const favicon = `<svg xmlns="http://www.w3.org/2000/svg" width="16" height="16" viewBox="0 0 16 16" fill="none" stroke="#2563eb" stroke-width="1.2">` +
+	`<circle cx="8" cy="8" r="6.8"/><ellipse cx="8" cy="8" rx="3" ry="6.8"/><path d="M1.2 8h13.6M2.3 4.4h11.4M2.3 11.6h11.4"/></svg>`
 
 // faviconHandler handles requests for the favicon.
 func faviconHandler(w http.ResponseWriter, _ *http.Request) {
@@ -23,115 +25,184 @@ func faviconHandler(w http.ResponseWriter, _ *http.Request) {
 }
 
 // handleDomainLookup handles domain lookup requests.
-func handleDomainLookup(w http.ResponseWriter, _ *http.Request, domain string) {
-	punycodeDomain, err := idna.ToASCII(domain)
+func handleDomainLookup(w http.ResponseWriter, r *http.Request, domain, field string) {
+	punycodeDomain, err := normalizeDomain(domain)
 	if err != nil {
-		sendJSONError(w, "Please provide a valid domain name.", http.StatusBadRequest)
+		sendError(w, r, "Please provide a valid domain name.", http.StatusBadRequest)
 		return
 	}
 
-	if len(punycodeDomain) > 253 {
-		sendJSONError(w, "Please provide a valid domain name.", http.StatusBadRequest)
+	fields := requestedFields(r, field)
+	if !validFields(domainDataType, fields) {
+		sendError(w, r, "Please provide a valid field.", http.StatusBadRequest)
 		return
 	}
 
-	data, err := common.LookupDomainData(punycodeDomain)
+	data, cached, err := common.LookupDomainDataCached(r.Context(), punycodeDomain)
+	setCacheHit(r, cached)
 	if err != nil {
 		slog.Error("failed to look up domain data", "domain", punycodeDomain, "error", err)
-		sendJSONError(w, "Error retrieving data for domain.", http.StatusInternalServerError)
+		sendError(w, r, "Error retrieving data for domain.", http.StatusInternalServerError)
 		return
 	}
 
-	sendJSONResponse(w, data, http.StatusOK)
+	// The response is as fresh as its shortest-lived DNS record.
+	var maxAge time.Duration
+	if !data.Expires.IsZero() {
+		maxAge = max(time.Until(data.Expires), 0)
+	}
+	w.Header().Set("Cache-Control", cacheControl(r, false, maxAge))
+
+	sendFieldsResponse(w, r, data, fields)
 }
 
 // handleASNLookup handles ASN lookup requests.
-func handleASNLookup(w http.ResponseWriter, _ *http.Request, path string, geoIP *db.GeoIPManager) {
-	upperPath := strings.ToUpper(path)
-	cleanPath := path
-
-	if strings.HasPrefix(upperPath, "ASN") {
-		cleanPath = path[3:]
-	} else if strings.HasPrefix(upperPath, "AS") {
-		cleanPath = path[2:]
+func handleASNLookup(w http.ResponseWriter, r *http.Request, path string, geoIP *db.GeoIPManager) {
+	asn, err := parseASN(path)
+	if err != nil {
+		sendError(w, r, "Invalid ASN: must be a positive number.", http.StatusBadRequest)
+		return
 	}
 
-	asnStr := strings.Trim(cleanPath, "/ ")
-
-	asn, err := strconv.ParseUint(asnStr, 10, 32)
-	if err != nil || asn == 0 {
-		sendJSONError(w, "Invalid ASN: must be a positive number.", http.StatusBadRequest)
+	fields := requestedFields(r, "")
+	if !validFields(asnDataType, fields) {
+		sendError(w, r, "Please provide a valid field.", http.StatusBadRequest)
 		return
 	}
 
-	data, err := common.LookupASNData(geoIP, uint(asn))
+	cache := newLookupCache(geoIP, r, strconv.FormatUint(uint64(asn), 10), false)
+
+	data, cached, err := common.LookupASNDataCached(r.Context(), geoIP, asn)
+	setCacheHit(r, cached)
 	if err != nil {
 		if strings.Contains(err.Error(), "no prefixes found") {
-			sendJSONError(w, err.Error(), http.StatusNotFound)
+			sendError(w, r, err.Error(), http.StatusNotFound)
 		} else {
 			slog.Error("failed to look up asn data", "asn", asn, "error", err)
-			sendJSONError(w, "Error retrieving data for ASN.", http.StatusInternalServerError)
+			sendError(w, r, "Error retrieving data for ASN.", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	sendJSONResponse(w, data, http.StatusOK)
+	if cache.notModified(w, r) {
+		return
+	}
+	cache.setHeaders(w)
+	sendFieldsResponse(w, r, data, fields)
 }
 
 // handleIPLookup handles IP lookup requests.
 func handleIPLookup(w http.ResponseWriter, r *http.Request, path string, geoIP *db.GeoIPManager) {
 	parts := strings.Split(path, "/")
 	var ipAddress, field string
+	self := false
 
 	switch len(parts) {
 	case 0:
-		ipAddress = GetRealIP(r)
+		ipAddress, self = GetRealIP(r), true
 	case 1:
 		if parts[0] == "" {
-			ipAddress = GetRealIP(r)
-		} else if _, ok := fieldMap[parts[0]]; ok {
-			ipAddress = GetRealIP(r)
+			ipAddress, self = GetRealIP(r), true
+		} else if validFields(ipDataType, []string{parts[0]}) {
+			ipAddress, self = GetRealIP(r), true
 			field = parts[0]
 		} else {
 			ipAddress = parts[0]
 		}
 	case 2:
+		if _, err := strconv.Atoi(parts[1]); err == nil {
+			handleNetworkLookup(w, r, path, geoIP)
+			return
+		}
 		ipAddress = parts[0]
 		field = parts[1]
 	default:
-		sendJSONError(w, "Invalid request format.", http.StatusBadRequest)
+		sendError(w, r, "Invalid request format.", http.StatusBadRequest)
 		return
 	}
 
 	ip := net.ParseIP(ipAddress)
 	if ip == nil {
-		sendJSONError(w, "Please provide a valid IP address.", http.StatusBadRequest)
+		sendError(w, r, "Please provide a valid IP address.", http.StatusBadRequest)
 		return
 	}
 
-	if field != "" {
-		if _, ok := fieldMap[field]; !ok {
-			sendJSONError(w, "Please provide a valid field.", http.StatusBadRequest)
-			return
-		}
+	fields := requestedFields(r, field)
+	if !validFields(ipDataType, fields) {
+		sendError(w, r, "Please provide a valid field.", http.StatusBadRequest)
+		return
 	}
 
+	// A lookup of the client's own address depends on who asks, so only the client may cache it.
+	cache := newLookupCache(geoIP, r, ip.String(), self)
+
 	if common.IsBogon(ip) {
-		sendJSONResponse(w, bogonDataStruct{IP: ip.String(), Bogon: true}, http.StatusOK)
+		if cache.notModified(w, r) {
+			return
+		}
+		cache.setHeaders(w)
+		sendResponse(w, r, bogonDataStruct{IP: ip.String(), Bogon: true}, http.StatusOK)
 		return
 	}
 
-	data := common.LookupIPData(geoIP, ip)
+	lang := requestLanguage(r, geoIP)
+	data, cached := common.LookupIPDataCached(r.Context(), geoIP, ip, lang)
+	setCacheHit(r, cached)
 	if data == nil {
-		sendJSONError(w, "Could not retrieve data for the specified IP.", http.StatusNotFound)
+		sendError(w, r, "Could not retrieve data for the specified IP.", http.StatusNotFound)
 		return
 	}
 
-	if field != "" {
-		value := getField(data, field)
-		sendJSONResponse(w, map[string]*string{field: value}, http.StatusOK)
+	if cache.notModified(w, r) {
 		return
 	}
+	cache.setHeaders(w)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
 
-	sendJSONResponse(w, data, http.StatusOK)
+	sendFieldsResponse(w, r, data, fields)
+}
+
+// handleNetworkLookup handles CIDR network lookup requests.
+func handleNetworkLookup(w http.ResponseWriter, r *http.Request, cidr string, geoIP *db.GeoIPManager) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		sendError(w, r, "Please provide a valid CIDR network.", http.StatusBadRequest)
+		return
+	}
+
+	fields := requestedFields(r, "")
+	if !validFields(networkDataType, fields) {
+		sendError(w, r, "Please provide a valid field.", http.StatusBadRequest)
+		return
+	}
+
+	cache := newLookupCache(geoIP, r, network.String(), false)
+
+	if common.IsBogon(network.IP) {
+		if cache.notModified(w, r) {
+			return
+		}
+		cache.setHeaders(w)
+		sendResponse(w, r, bogonDataStruct{IP: network.String(), Bogon: true}, http.StatusOK)
+		return
+	}
+
+	data, cached, err := common.LookupNetworkDataCached(r.Context(), geoIP, network)
+	setCacheHit(r, cached)
+	if errors.Is(err, common.ErrNetworkSplit) {
+		sendError(w, r, "The network spans several database networks; please query a smaller prefix.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to look up network data", "network", network.String(), "error", err)
+		sendError(w, r, "Error retrieving data for network.", http.StatusInternalServerError)
+		return
+	}
+
+	if cache.notModified(w, r) {
+		return
+	}
+	cache.setHeaders(w)
+	sendFieldsResponse(w, r, data, fields)
 }
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"ipinfo/internal/config"
+)
+
+func TestNetworkLookup(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+
+	tests := []struct {
+		target string
+		want   map[string]string
+	}{
+		{"/8.8.8.0/24", map[string]string{
+			"network": "8.8.8.0/24", "prefix_length": "24", "first_address": "8.8.8.0", "last_address": "8.8.8.255",
+			"address_count": "256", "city_network": "8.8.8.0/24", "asn_network": "8.8.8.0/24", "org": "AS15169 Google LLC", "country": "US",
+		}},
+		// A prefix inside a database network is reported with the enclosing network, and the host bits are dropped.
+		{"/1.1.1.77/25", map[string]string{
+			"network": "1.1.1.0/25", "first_address": "1.1.1.0", "last_address": "1.1.1.127", "address_count": "128",
+			"asn_network": "1.1.1.0/24", "country": "AU",
+		}},
+		{"/2001:4860::/32", map[string]string{
+			"network": "2001:4860::/32", "last_address": "2001:4860:ffff:ffff:ffff:ffff:ffff:ffff",
+			"address_count": "79228162514264337593543950336",
+		}},
+		{"/10.0.0.0/8", map[string]string{"ip": "10.0.0.0/8", "bogon": "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serveTest(router, http.MethodGet, tt.target, "", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			// Numbers are compared as written, since address counts of IPv6 prefixes exceed a float64.
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				got := string(bytes.Trim(body[key], `"`))
+				if got != want {
+					t.Errorf("%s = %s, want %s", key, got, want)
+				}
+			}
+		})
+	}
+
+	for target, want := range map[string]int{
+		"/8.8.0.0/16": http.StatusNotFound,
+		"/8.8.8.0/33": http.StatusBadRequest,
+	} {
+		if rec := serveTest(router, http.MethodGet, target, "", ""); rec.Code != want {
+			t.Errorf("%s: status %d, want %d: %s", target, rec.Code, want, rec.Body.String())
+		}
+	}
+
+	// A single address reports the database network that contains it.
+	if ip := jsonBody(t, serveTest(router, http.MethodGet, "/8.8.8.8", "", "")); ip["network"] != "8.8.8.0/24" {
+		t.Errorf("8.8.8.8 network %v, want 8.8.8.0/24", ip["network"])
+	}
+}
+
+func TestIPLookup(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+
+	tests := []struct {
+		target string
+		want   map[string]any
+	}{
+		{"/81.2.69.142", map[string]any{
+			"ip": "81.2.69.142", "asn": nil, "as_name": nil, "org": nil, "network": nil,
+			"city": "London", "region": "England",
+			"subdivisions": []any{
+				map[string]any{"code": "ENG", "name": "England"},
+				map[string]any{"code": "LND", "name": "City of London"},
+			},
+			"postal": "EC2V", "country": "GB", "country_name": "United Kingdom", "is_in_european_union": false,
+			"continent": "Europe", "continent_code": "EU", "timezone": "Europe/London", "loc": "51.5142,-0.0931",
+			"accuracy_radius": 20.0,
+		}},
+		{"/8.8.8.8", map[string]any{
+			"ip": "8.8.8.8", "asn": 15169.0, "as_name": "Google LLC", "org": "AS15169 Google LLC", "network": "8.8.8.0/24",
+			"city": "Mountain View", "region": nil, "subdivisions": []any{}, "postal": nil,
+			"country": "US", "country_name": "United States", "is_in_european_union": false,
+			"continent": "North America", "continent_code": "NA", "timezone": "America/Los_Angeles", "loc": "37.3860,-122.0838",
+			"accuracy_radius": 100.0,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got := jsonBody(t, serveTest(router, http.MethodGet, tt.target, "", ""))
+			// The hostname depends on the resolver of the machine running the test.
+			if _, ok := got["hostname"]; !ok {
+				t.Error("hostname is missing")
+			}
+			delete(got, "hostname")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalizedNames(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		lang           string
+		want           map[string]any
+	}{
+		{"default", "", "", "en", map[string]any{"city": "London", "country_name": "United Kingdom", "region": "England", "continent": "Europe"}},
+		{"lang parameter", "?lang=fr", "de", "fr", map[string]any{"city": "Londres", "country_name": "Royaume-Uni", "region": "Angleterre", "continent": "Europe"}},
+		{"accept-language", "", "de-DE, en;q=0.5", "de", map[string]any{"city": "London", "country_name": "Vereinigtes Königreich", "region": "England", "continent": "Europa"}},
+		{"missing names fall back to english", "?lang=pt-BR", "", "pt-BR", map[string]any{"city": "Londres", "country_name": "United Kingdom"}},
+	}
+	// The responses are cached per language, so the languages must not mix on a second round.
+	for range 2 {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/81.2.69.142"+tt.query, nil)
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				if got := rec.Header().Get("Content-Language"); got != tt.lang {
+					t.Errorf("Content-Language %q, want %q", got, tt.lang)
+				}
+				if !slices.Contains(rec.Header().Values("Vary"), "Accept-Language") {
+					t.Errorf("Vary %q does not name Accept-Language", rec.Header().Values("Vary"))
+				}
+				body := jsonBody(t, rec)
+				for key, want := range tt.want {
+					if body[key] != want {
+						t.Errorf("%s = %v, want %v", key, body[key], want)
+					}
+				}
+			})
+		}
+	}
+}
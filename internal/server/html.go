@@ -0,0 +1,292 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"html/template"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/config"
+)
+
+// templateFS holds the templates of the HTML pages.
+//
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// pageTemplates holds one template per page, each combined with the shared layout.
+var pageTemplates = parsePageTemplates("ip", "asn", "domain", "fields", "error")
+
+// mapZoom is the zoom level of the map tile shown for an IP address, about the size of a region.
+const mapZoom = 8
+
+// mapTileSize is the width and height of a map tile in pixels.
+const mapTileSize = 256
+
+// htmlPagesContextKey is the context key of the htmlPages serving a request.
+type htmlPagesContextKey struct{}
+
+// htmlPages renders responses as HTML pages for browsers, leaving other clients with the negotiated
+// format.
+type htmlPages struct {
+	enabled    bool
+	mapTileURL string
+	csp        string
+}
+
+// htmlPage is the data passed to a page template.
+type htmlPage struct {
+	Title  string
+	Query  string
+	Status int
+	Self   bool
+	IP     *common.DataStruct
+	EU     bool
+	Map    *mapView
+	ASN    *common.ASNDataResponse
+	Domain *domainView
+	Fields []fieldRow
+	Error  string
+}
+
+// fieldRow is a leaf of a response shown on the generic page, addressed by its dotted key path.
+type fieldRow struct {
+	Key   string
+	Value string
+}
+
+// mapView places an IP address on a configured map tile, or on an offline placeholder of the world.
+type mapView struct {
+	Lat, Lon float64
+	// Y is the latitude flipped for the placeholder's SVG coordinates.
+	Y       float64
+	TileURL string
+	// MarkerX and MarkerY are the pixel position of the address within the tile.
+	MarkerX, MarkerY int
+}
+
+// domainView splits a domain lookup into the rows the domain page shows.
+type domainView struct {
+	Name     string
+	Records  []dnsRecordRow
+	Whois    *common.WhoisInfo
+	WhoisRaw string
+}
+
+// dnsRecordRow lists the values of one DNS record type.
+type dnsRecordRow struct {
+	Type   string
+	Values []string
+	// Addresses is set for A and AAAA records, whose values link to their IP pages.
+	Addresses bool
+}
+
+// newHTMLPages creates the HTML renderer from the configuration.
+func newHTMLPages(cfg config.UIConfig) *htmlPages {
+	imgSrc := "'self' data:"
+	if u, err := url.Parse(cfg.MapTileURL); err == nil && cfg.MapTileURL != "" {
+		imgSrc += " " + u.Scheme + "://" + u.Host
+	}
+	return &htmlPages{
+		enabled:    cfg.Enabled,
+		mapTileURL: cfg.MapTileURL,
+		csp:        "default-src 'none'; style-src 'unsafe-inline'; img-src " + imgSrc + "; form-action 'self'; base-uri 'none'",
+	}
+}
+
+// middleware lets sendResponse render HTML pages for the requests next serves.
+func (p *htmlPages) middleware(next http.Handler) http.Handler {
+	if !p.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), htmlPagesContextKey{}, p)))
+	})
+}
+
+// requestHTMLPages returns the HTML renderer for r if the client asked for a page, or nil.
+func requestHTMLPages(r *http.Request) *htmlPages {
+	p, ok := r.Context().Value(htmlPagesContextKey{}).(*htmlPages)
+	if !ok || !wantsHTML(r) {
+		return nil
+	}
+	return p
+}
+
+// wantsHTML reports whether r is a page load of a browser: a GET or HEAD that accepts text/html and
+// does not ask for a format explicitly.
+func wantsHTML(r *http.Request) bool {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.URL.Query().Get("format") != "" {
+		return false
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != "text/html" {
+			continue
+		}
+		qValue, ok := params["q"]
+		if !ok {
+			return true
+		}
+		q, err := strconv.ParseFloat(qValue, 64)
+		return err == nil && q > 0
+	}
+	return false
+}
+
+// render sends data as the HTML page matching its type, with the given status code.
+func (p *htmlPages) render(w http.ResponseWriter, r *http.Request, data any, statusCode int) {
+	path := strings.Trim(r.URL.Path, "/")
+	page := htmlPage{Title: path, Query: path, Status: statusCode}
+	name := "fields"
+
+	switch d := data.(type) {
+	case *common.DataStruct:
+		name = "ip"
+		page.IP = d
+		page.Self = path == ""
+		page.EU = d.IsEU != nil && *d.IsEU
+		if d.IP != nil {
+			page.Title = *d.IP
+		}
+		page.Map = p.mapView(d.Loc)
+	case *common.ASNDataResponse:
+		name = "asn"
+		page.ASN = d
+		page.Title = "AS" + strconv.FormatUint(uint64(d.Details.ASN), 10)
+	case *common.DomainDataResponse:
+		name = "domain"
+		domain, _, _ := strings.Cut(path, "/")
+		page.Domain = newDomainView(domain, d)
+		page.Title = domain
+	case errorResponse:
+		name = "error"
+		page.Error = d.Error
+		page.Title = http.StatusText(statusCode)
+	case bogonDataStruct:
+		page.Title = d.IP
+		page.Self = path == ""
+	}
+
+	if name == "fields" {
+		tree, err := toTree(data)
+		if err != nil {
+			slog.Error("failed to convert response for page", "error", err)
+		}
+		for _, field := range flatten("", tree, nil) {
+			page.Fields = append(page.Fields, fieldRow{Key: field.key, Value: field.value})
+		}
+	}
+
+	var body bytes.Buffer
+	if err := pageTemplates[name].ExecuteTemplate(&body, "layout.html", page); err != nil {
+		slog.Error("failed to render page", "page", name, "error", err)
+		http.Error(w, "Error rendering page.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", p.csp)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		slog.Error("failed to write response", "error", err)
+	}
+}
+
+// mapView places the coordinates loc, formatted as "lat,lon", on the map. It returns nil if loc is not
+// set or invalid.
+func (p *htmlPages) mapView(loc *string) *mapView {
+	if loc == nil {
+		return nil
+	}
+	latText, lonText, ok := strings.Cut(*loc, ",")
+	if !ok {
+		return nil
+	}
+	lat, err := strconv.ParseFloat(latText, 64)
+	if err != nil {
+		return nil
+	}
+	lon, err := strconv.ParseFloat(lonText, 64)
+	if err != nil {
+		return nil
+	}
+
+	view := &mapView{Lat: lat, Lon: lon, Y: -lat}
+	if p.mapTileURL == "" {
+		return view
+	}
+
+	// Web Mercator tile coordinates, as used by OpenStreetMap and most tile servers.
+	n := math.Exp2(mapZoom)
+	latRad := max(min(lat, 85.0511), -85.0511) * math.Pi / 180
+	x := (lon + 180) / 360 * n
+	y := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n
+	tileX, tileY := min(math.Floor(x), n-1), min(math.Floor(y), n-1)
+
+	view.TileURL = strings.NewReplacer(
+		"{z}", strconv.Itoa(mapZoom),
+		"{x}", strconv.Itoa(int(tileX)),
+		"{y}", strconv.Itoa(int(tileY)),
+	).Replace(p.mapTileURL)
+	view.MarkerX = int((x - tileX) * mapTileSize)
+	view.MarkerY = int((y - tileY) * mapTileSize)
+	return view
+}
+
+// newDomainView collects the DNS records of a domain lookup in a fixed order and picks the parsed or
+// raw WHOIS data.
+func newDomainView(name string, data *common.DomainDataResponse) *domainView {
+	view := &domainView{Name: name}
+	dns := data.DNS
+	for _, row := range []dnsRecordRow{
+		{Type: "A", Values: dns.A, Addresses: true},
+		{Type: "AAAA", Values: dns.AAAA, Addresses: true},
+		{Type: "CNAME", Values: strings.Fields(dns.CNAME)},
+		{Type: "MX", Values: dns.MX},
+		{Type: "NS", Values: dns.NS},
+		{Type: "TXT", Values: dns.TXT},
+		{Type: "SOA", Values: dns.SOA},
+		{Type: "CAA", Values: dns.CAA},
+	} {
+		if len(row.Values) > 0 {
+			view.Records = append(view.Records, row)
+		}
+	}
+
+	switch whois := data.Whois.(type) {
+	case common.WhoisInfo:
+		view.Whois = &whois
+	case string:
+		view.WhoisRaw = whois
+	}
+	return view
+}
+
+// handleSearch sends the search box of the HTML pages to the page of the query.
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	// Leading slashes are trimmed so the target stays on this server.
+	query := strings.Trim(strings.TrimSpace(r.URL.Query().Get("q")), "/")
+	target := (&url.URL{Path: "/" + query}).EscapedPath()
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// parsePageTemplates parses the named page templates, each together with templates/layout.html.
+func parsePageTemplates(names ...string) map[string]*template.Template {
+	layout := template.Must(template.New("layout.html").ParseFS(templateFS, "templates/layout.html"))
+	pages := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		page := template.Must(layout.Clone())
+		pages[name] = template.Must(page.ParseFS(templateFS, "templates/"+name+".html"))
+	}
+	return pages
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipinfo/internal/config"
+)
+
+func TestWantsHTML(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		accept string
+		want   bool
+	}{
+		{http.MethodGet, "/8.8.8.8", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{http.MethodHead, "/8.8.8.8", "text/html", true},
+		{http.MethodGet, "/8.8.8.8", "", false},
+		{http.MethodGet, "/8.8.8.8", "*/*", false},
+		{http.MethodGet, "/8.8.8.8", "application/json", false},
+		{http.MethodGet, "/8.8.8.8", "application/json, text/html;q=0", false},
+		{http.MethodGet, "/8.8.8.8?format=json", "text/html", false},
+		{http.MethodPost, "/batch", "text/html", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.Header.Set("Accept", tt.accept)
+		if got := wantsHTML(req); got != tt.want {
+			t.Errorf("wantsHTML(%s %s, Accept %q) = %t, want %t", tt.method, tt.target, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLPages(t *testing.T) {
+	cfg := config.Default()
+	cfg.UI.MapTileURL = "https://tile.example.org/{z}/{x}/{y}.png"
+	router := newTestRouterConfig(t, newTestAuthenticator(t, false), cfg)
+
+	browse := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := browse("/81.2.69.142")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "img-src 'self' data: https://tile.example.org;") {
+		t.Errorf("Content-Security-Policy %q does not allow the tile server", csp)
+	}
+	for _, want := range []string{"<h1>81.2.69.142</h1>", "<td>London</td>", "United Kingdom (GB)", `src="https://tile.example.org/8/127/85.png"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("IP page does not contain %s", want)
+		}
+	}
+
+	if rec := browse("/AS15169"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Google LLC") {
+		t.Errorf("ASN page: status %d", rec.Code)
+	}
+	if rec := browse("/not-a-query!"); rec.Code != http.StatusBadRequest || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("error page: status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	// The search box sends the browser to the page of the query.
+	if rec := browse("/search?q=+8.8.8.8+"); rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/8.8.8.8" {
+		t.Errorf("search: status %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := browse("/search?q=//evil.example"); rec.Header().Get("Location") != "/evil.example" {
+		t.Errorf("search left the server: Location %q", rec.Header().Get("Location"))
+	}
+}
+
+func TestHTMLPagesLeaveAPIClientsAlone(t *testing.T) {
+	withUI := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+	cfg := config.Default()
+	cfg.UI.Enabled = false
+	withoutUI := newTestRouterConfig(t, newTestAuthenticator(t, false), cfg)
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+	}{
+		{"no accept", "/81.2.69.142", ""},
+		{"curl", "/81.2.69.142", "*/*"},
+		{"json", "/AS15169", "application/json"},
+		{"explicit format", "/81.2.69.142?format=json", "text/html"},
+		{"error", "/not-a-query!", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies [2]string
+			for i, router := range []http.Handler{withUI, withoutUI} {
+				req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+				req.Header.Set("Accept", tt.accept)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+					t.Errorf("content type %q, want JSON", rec.Header().Get("Content-Type"))
+				}
+				bodies[i] = rec.Body.String()
+			}
+			if bodies[0] != bodies[1] {
+				t.Errorf("response with the UI enabled differs:\n%s\nwithout:\n%s", bodies[0], bodies[1])
+			}
+		})
+	}
+
+	// With the UI disabled, browsers get JSON too.
+	req := httptest.NewRequest(http.MethodGet, "/81.2.69.142", nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	withoutUI.ServeHTTP(rec, req)
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("disabled UI: content type %q, want JSON", rec.Header().Get("Content-Type"))
+	}
+}
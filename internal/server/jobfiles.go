@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// jobLineLimit caps the length of a single NDJSON input line in bytes.
+const jobLineLimit = 1 << 20
+
+// jobCSVHeaders are first-column names that mark the first row of a CSV upload as a header.
+var jobCSVHeaders = map[string]struct{}{
+	"ip":      {},
+	"query":   {},
+	"domain":  {},
+	"host":    {},
+	"address": {},
+}
+
+// storeJobInput writes an uploaded file to path and returns the number of queries it contains.
+func storeJobInput(path string, body io.Reader, format jobFormat) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+
+	file, err = os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var total int64
+	err = readJobQueries(file, format, func(string) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("%w: no queries found", errInvalidJobInput)
+	}
+	return total, nil
+}
+
+// readJobQueries calls yield for every query of a CSV or NDJSON input, stopping at the first error.
+// CSV queries are taken from the first column; NDJSON lines are strings or objects with a query, ip or domain field.
+func readJobQueries(r io.Reader, format jobFormat, yield func(query string) error) error {
+	if format == jobCSV {
+		return readCSVQueries(r, yield)
+	}
+	return readNDJSONQueries(r, yield)
+}
+
+// readCSVQueries reads the first column of every CSV record, skipping a header row.
+func readCSVQueries(r io.Reader, yield func(query string) error) error {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.LazyQuotes = true
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidJobInput, err)
+		}
+
+		query := strings.TrimSpace(record[0])
+		if first {
+			query = strings.TrimPrefix(query, "\ufeff")
+			if _, ok := jobCSVHeaders[strings.ToLower(query)]; ok {
+				continue
+			}
+		}
+		if query == "" {
+			continue
+		}
+		if err := yield(query); err != nil {
+			return err
+		}
+	}
+}
+
+// readNDJSONQueries reads one query from every non-empty line.
+func readNDJSONQueries(r io.Reader, yield func(query string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), jobLineLimit)
+
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		query, err := ndjsonQuery(raw)
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", errInvalidJobInput, line, err)
+		}
+		if query == "" {
+			continue
+		}
+		if err := yield(query); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidJobInput, err)
+	}
+	return nil
+}
+
+// ndjsonQuery extracts the query from a single NDJSON value.
+func ndjsonQuery(raw []byte) (string, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case map[string]any:
+		for _, key := range []string{"query", "ip", "domain"} {
+			if query, ok := v[key].(string); ok {
+				return strings.TrimSpace(query), nil
+			}
+		}
+	}
+	return "", errors.New("expected a string or an object with a query, ip or domain field")
+}
+
+// convertResultsToCSV rewrites an NDJSON result file as CSV with one row per query.
+// The file is read twice, first to collect the columns and then to write the rows, so memory stays bounded.
+func convertResultsToCSV(src, dst string) error {
+	header := []string{"query", "error"}
+	columns := map[string]struct{}{"query": {}, "error": {}}
+	err := eachResultRow(src, func(row any) error {
+		csvRecord(row, &header, columns)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	writer := csv.NewWriter(buffered)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	err = eachResultRow(src, func(row any) error {
+		return writer.Write(csvLine(header, csvRecord(row, &header, columns)))
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// eachResultRow calls fn with every result of an NDJSON result file, with the data fields lifted next to the query.
+func eachResultRow(path string, fn func(row any) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	decoder.UseNumber()
+	for {
+		tree, err := decodeTree(decoder)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result, _ := tree.(orderedMap)
+		row := orderedMap{}
+		for _, entry := range result {
+			if data, ok := entry.Value.(orderedMap); ok && entry.Key == "data" {
+				row = append(row, data...)
+				continue
+			}
+			row = append(row, entry)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
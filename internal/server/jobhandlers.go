@@ -0,0 +1,195 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// jobFormatsByMediaType maps upload content types to job input formats.
+var jobFormatsByMediaType = map[string]jobFormat{
+	"text/csv":             jobCSV,
+	"application/csv":      jobCSV,
+	"application/x-ndjson": jobNDJSON,
+	"application/ndjson":   jobNDJSON,
+	"application/jsonl":    jobNDJSON,
+	"application/x-jsonl":  jobNDJSON,
+}
+
+// jobFormatsByExtension maps uploaded file extensions to job input formats.
+var jobFormatsByExtension = map[string]jobFormat{
+	".csv":    jobCSV,
+	".ndjson": jobNDJSON,
+	".jsonl":  jobNDJSON,
+}
+
+// handleCreateJob accepts a CSV or NDJSON upload, either as the request body or as the file field of a form,
+// and queues a job for it.
+func (m *jobManager) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	// The server's timeouts run from the end of the headers, so a long upload would leave no time to
+	// answer. Both are lifted while reading, and the answer gets its own deadline once the body is read.
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+	respondBy := func() { _ = rc.SetWriteDeadline(time.Now().Add(jobResponseTimeout)) }
+	r.Body = http.MaxBytesReader(w, r.Body, m.maxUpload)
+
+	body, input, err := jobUpload(r)
+	if err != nil {
+		respondBy()
+		sendError(w, r, "Please upload a CSV or NDJSON file of IPs or domains.", http.StatusBadRequest)
+		return
+	}
+
+	output := input
+	if value := r.URL.Query().Get("output"); value != "" {
+		output = jobFormat(strings.ToLower(value))
+		if output != jobCSV && output != jobNDJSON {
+			respondBy()
+			sendError(w, r, "Please provide a valid output format (csv or ndjson).", http.StatusBadRequest)
+			return
+		}
+	}
+
+	j, err := m.create(body, input, output, requestLanguage(r, m.geoIP), requestAPIKey(r), rateIdentity(r))
+	respondBy()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			sendError(w, r, fmt.Sprintf("Upload exceeds the maximum of %d bytes.", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, errInvalidJobInput):
+			sendError(w, r, "Please upload a CSV or NDJSON file of IPs or domains: "+err.Error(), http.StatusBadRequest)
+		case errors.Is(err, errJobQueueFull):
+			sendError(w, r, "Too many jobs are queued, please try again later.", http.StatusServiceUnavailable)
+		default:
+			slog.Error("failed to create job", "error", err)
+			sendError(w, r, "Error creating job.", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	sendResponse(w, r, j, http.StatusAccepted)
+}
+
+// handleGetJob reports the status and progress of a job.
+func (m *jobManager) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := m.requestedJob(w, r)
+	if !ok {
+		return
+	}
+	sendResponse(w, r, j, http.StatusOK)
+}
+
+// handleJobResult downloads the result file of a completed job.
+func (m *jobManager) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	j, ok := m.requestedJob(w, r)
+	if !ok {
+		return
+	}
+	if j.Status != jobCompleted {
+		sendError(w, r, "Job has not completed yet.", http.StatusConflict)
+		return
+	}
+
+	file, err := os.Open(m.resultPath(j))
+	if err != nil {
+		slog.Error("failed to open job result", "id", j.ID, "error", err)
+		sendError(w, r, "Error reading job result.", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	contentType := "application/x-ndjson"
+	if j.OutputFormat == jobCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	name := j.ID + "." + string(j.OutputFormat)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	http.ServeContent(w, r, name, *j.FinishedAt, file)
+}
+
+// handleDeleteJob cancels a job if it is still running and deletes it with its files.
+func (m *jobManager) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := m.requestedJob(w, r)
+	if !ok {
+		return
+	}
+	if err := m.remove(j.ID); err != nil {
+		sendError(w, r, "Job not found.", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestedJob looks up the job named in the path, sending a 404 response if it does not exist
+// or belongs to another API key.
+func (m *jobManager) requestedJob(w http.ResponseWriter, r *http.Request) (job, bool) {
+	id := r.PathValue("id")
+	if !validJobID(id) {
+		sendError(w, r, "Job not found.", http.StatusNotFound)
+		return job{}, false
+	}
+	j, err := m.get(id)
+	if key := requestAPIKey(r); err != nil || (key != nil && key.name != j.owner) {
+		sendError(w, r, "Job not found.", http.StatusNotFound)
+		return job{}, false
+	}
+	return j, true
+}
+
+// jobUpload returns the uploaded file and its format from the input query parameter, content type or file name.
+func jobUpload(r *http.Request) (io.Reader, jobFormat, error) {
+	format := jobFormat(strings.ToLower(r.URL.Query().Get("input")))
+	if format != "" && format != jobCSV && format != jobNDJSON {
+		return nil, "", fmt.Errorf("unsupported input format: %s", format)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if format == "" {
+			format = jobFormatsByMediaType[mediaType]
+		}
+		if format == "" {
+			return nil, "", fmt.Errorf("unsupported content type: %s", mediaType)
+		}
+		return r.Body, format, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		if format == "" {
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			format = jobFormatsByMediaType[partType]
+		}
+		if format == "" {
+			format = jobFormatsByExtension[strings.ToLower(filepath.Ext(part.FileName()))]
+		}
+		if format == "" {
+			return nil, "", fmt.Errorf("unsupported file: %s", part.FileName())
+		}
+		return part, format, nil
+	}
+}
@@ -0,0 +1,508 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ipinfo/internal/config"
+	"ipinfo/internal/db"
+)
+
+const (
+	// jobConcurrency bounds the number of lookups in flight for a single job.
+	jobConcurrency = 8
+	// jobCleanupInterval is how often expired jobs are removed from disk.
+	jobCleanupInterval = 10 * time.Minute
+	// jobResponseTimeout is how long the answer to an upload may take once the upload has been read.
+	jobResponseTimeout = 10 * time.Second
+)
+
+var (
+	errJobNotFound     = errors.New("job not found")
+	errJobQueueFull    = errors.New("job queue is full")
+	errInvalidJobInput = errors.New("invalid job input")
+)
+
+// jobStatus is the lifecycle stage of a job.
+type jobStatus string
+
+const (
+	jobQueued    jobStatus = "queued"
+	jobRunning   jobStatus = "running"
+	jobCompleted jobStatus = "completed"
+	jobFailed    jobStatus = "failed"
+)
+
+// jobFormat is the file format of a job's input or result.
+type jobFormat string
+
+const (
+	jobCSV    jobFormat = "csv"
+	jobNDJSON jobFormat = "ndjson"
+)
+
+// job is the state of a bulk lookup job, as reported to clients and stored next to its files.
+type job struct {
+	ID           string     `json:"id"`
+	Status       jobStatus  `json:"status"`
+	InputFormat  jobFormat  `json:"input_format"`
+	OutputFormat jobFormat  `json:"output_format"`
+	Language     string     `json:"language"`
+	Total        int64      `json:"total"`
+	Processed    int64      `json:"processed"`
+	Failed       int64      `json:"failed"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	ResultURL    string     `json:"result_url,omitempty"`
+
+	// owner names the API key that created the job, which is the only key that may access it.
+	owner string
+	// key is the API key that created the job and identity is the client its lookups are rate limited as.
+	// Neither is persisted because unfinished jobs do not survive a restart.
+	key      *apiKey
+	identity string
+}
+
+// storedJob is the metadata of a job as written to disk.
+type storedJob struct {
+	job
+	Owner string `json:"owner,omitempty"`
+}
+
+// jobManager runs bulk lookup jobs on a bounded worker pool and keeps their files on disk.
+type jobManager struct {
+	geoIP     *db.GeoIPManager
+	limits    *rateLimiter
+	dir       string
+	workers   int
+	retention time.Duration
+	maxUpload int64
+	queue     chan string
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// newJobManager creates a job manager.
+func newJobManager(geoIP *db.GeoIPManager, limits *rateLimiter, cfg config.JobsConfig) *jobManager {
+	return &jobManager{
+		geoIP:     geoIP,
+		limits:    limits,
+		dir:       cfg.Dir,
+		workers:   cfg.Workers,
+		retention: cfg.Retention,
+		maxUpload: int64(cfg.MaxUploadMB) << 20,
+		queue:     make(chan string, cfg.QueueSize),
+		jobs:      make(map[string]*job),
+		cancels:   make(map[string]context.CancelFunc),
+	}
+}
+
+// start loads the jobs kept on disk and starts the workers and the cleanup loop until ctx is done.
+func (m *jobManager) start(ctx context.Context) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("creating jobs directory: %w", err)
+	}
+	if err := m.load(); err != nil {
+		return fmt.Errorf("loading jobs: %w", err)
+	}
+
+	for range m.workers {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			for {
+				select {
+				case id := <-m.queue:
+					m.run(ctx, id)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(max(min(jobCleanupInterval, m.retention), time.Minute))
+		defer ticker.Stop()
+		for {
+			m.cleanup()
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// wait blocks until the workers and the cleanup loop have stopped.
+func (m *jobManager) wait() {
+	m.wg.Wait()
+}
+
+// load restores the jobs found on disk, marking jobs that were interrupted by a restart as failed.
+func (m *jobManager) load() error {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !validJobID(entry.Name()) {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(m.dir, entry.Name(), "job.json"))
+		if err != nil {
+			slog.Warn("skipping job without metadata", "id", entry.Name(), "error", err)
+			continue
+		}
+		var stored storedJob
+		if err := json.Unmarshal(raw, &stored); err != nil || stored.ID != entry.Name() {
+			slog.Warn("skipping job with invalid metadata", "id", entry.Name(), "error", err)
+			continue
+		}
+		j := stored.job
+		j.owner = stored.Owner
+
+		if j.Status == jobQueued || j.Status == jobRunning {
+			m.finish(&j, errors.New("interrupted by a server restart"))
+			m.save(j)
+		}
+		m.jobs[j.ID] = &j
+	}
+	return nil
+}
+
+// create stores an uploaded input file and queues a job for it.
+func (m *jobManager) create(body io.Reader, input, output jobFormat, lang string, key *apiKey, identity string) (job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return job{}, err
+	}
+
+	dir := filepath.Join(m.dir, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return job{}, err
+	}
+
+	total, err := storeJobInput(filepath.Join(dir, "input"), body, input)
+	if err != nil {
+		m.removeFiles(id)
+		return job{}, err
+	}
+
+	j := &job{
+		ID:           id,
+		Status:       jobQueued,
+		InputFormat:  input,
+		OutputFormat: output,
+		Language:     lang,
+		Total:        total,
+		CreatedAt:    time.Now().UTC(),
+		key:          key,
+		identity:     identity,
+	}
+	if key != nil {
+		j.owner = key.name
+	}
+	m.save(*j)
+
+	m.mu.Lock()
+	m.jobs[id] = j
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- id:
+		return *j, nil
+	default:
+		m.remove(id)
+		return job{}, errJobQueueFull
+	}
+}
+
+// get returns a snapshot of a job.
+func (m *jobManager) get(id string) (job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return job{}, errJobNotFound
+	}
+	return *j, nil
+}
+
+// remove cancels a job if it is still running and deletes it together with its files.
+func (m *jobManager) remove(id string) error {
+	m.mu.Lock()
+	_, ok := m.jobs[id]
+	cancel := m.cancels[id]
+	delete(m.jobs, id)
+	delete(m.cancels, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return errJobNotFound
+	}
+	if cancel != nil {
+		cancel()
+	}
+	m.removeFiles(id)
+	return nil
+}
+
+// cleanup removes finished jobs whose retention time has passed.
+func (m *jobManager) cleanup() {
+	now := time.Now()
+	var expired []string
+
+	m.mu.Lock()
+	for id, j := range m.jobs {
+		if j.ExpiresAt != nil && now.After(*j.ExpiresAt) {
+			expired = append(expired, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range expired {
+		if err := m.remove(id); err == nil {
+			slog.Info("removed expired job", "id", id)
+		}
+	}
+}
+
+// run processes a queued job and records its outcome.
+func (m *jobManager) run(ctx context.Context, id string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok || j.Status != jobQueued {
+		m.mu.Unlock()
+		return
+	}
+	now := time.Now().UTC()
+	j.Status, j.StartedAt = jobRunning, &now
+	m.cancels[id] = cancel
+	snapshot := *j
+	m.mu.Unlock()
+
+	m.save(snapshot)
+	slog.Info("job started", "id", id, "total", snapshot.Total)
+
+	err := m.process(ctx, snapshot)
+	if err != nil && ctx.Err() != nil {
+		err = errors.New("interrupted by server shutdown")
+	}
+
+	m.mu.Lock()
+	j, ok = m.jobs[id]
+	if ok {
+		delete(m.cancels, id)
+		m.finish(j, err)
+		snapshot = *j
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		// The job was removed while running; drop anything written since.
+		m.removeFiles(id)
+		return
+	}
+
+	m.save(snapshot)
+	if err != nil {
+		slog.Error("job failed", "id", id, "error", err)
+		return
+	}
+	slog.Info("job completed", "id", id, "processed", snapshot.Processed, "failed", snapshot.Failed)
+}
+
+// finish records the outcome of a job and when it expires.
+func (m *jobManager) finish(j *job, err error) {
+	now := time.Now().UTC()
+	expires := now.Add(m.retention)
+	j.FinishedAt, j.ExpiresAt = &now, &expires
+
+	if err != nil {
+		j.Status, j.Error = jobFailed, err.Error()
+		return
+	}
+	j.Status, j.ResultURL = jobCompleted, "/jobs/"+j.ID+"/result"
+}
+
+// process looks up every query of a job in order and writes the result file.
+func (m *jobManager) process(ctx context.Context, j job) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	input, err := os.Open(filepath.Join(m.dir, j.ID, "input"))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	resultsPath := filepath.Join(m.dir, j.ID, "results.ndjson")
+	results, err := os.Create(resultsPath)
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	var readErr error
+	pending := make(chan chan streamResult, jobConcurrency)
+	go func() {
+		defer close(pending)
+		readErr = readJobQueries(input, j.InputFormat, func(query string) error {
+			result := make(chan streamResult, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			go func() {
+				if err := m.limits.waitQuery(ctx, j.identity, query); err != nil {
+					result <- streamResult{Query: query, Error: err.Error()}
+					return
+				}
+				result <- lookupJobItem(ctx, m.geoIP, j.key, query, j.Language)
+			}()
+			return nil
+		})
+	}()
+
+	writer := bufio.NewWriter(results)
+	encoder := json.NewEncoder(writer)
+	var writeErr error
+	for result := range pending {
+		res := <-result
+		if writeErr != nil {
+			continue
+		}
+		if writeErr = encoder.Encode(res); writeErr != nil {
+			cancel()
+			continue
+		}
+		m.progress(j.ID, res.Error != "")
+	}
+
+	if writeErr != nil {
+		return fmt.Errorf("writing results: %w", writeErr)
+	}
+	if readErr != nil {
+		return fmt.Errorf("reading input: %w", readErr)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+
+	if j.OutputFormat == jobCSV {
+		if err := convertResultsToCSV(resultsPath, filepath.Join(m.dir, j.ID, "results.csv")); err != nil {
+			return fmt.Errorf("writing csv results: %w", err)
+		}
+		return os.Remove(resultsPath)
+	}
+	return nil
+}
+
+// progress counts a processed query of a running job.
+func (m *jobManager) progress(id string, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if j, ok := m.jobs[id]; ok {
+		j.Processed++
+		if failed {
+			j.Failed++
+		}
+	}
+}
+
+// save writes the metadata of a job next to its files.
+func (m *jobManager) save(j job) {
+	raw, err := json.Marshal(storedJob{job: j, Owner: j.owner})
+	if err != nil {
+		slog.Error("failed to encode job metadata", "id", j.ID, "error", err)
+		return
+	}
+
+	path := filepath.Join(m.dir, j.ID, "job.json")
+	if err := os.WriteFile(path+".tmp", raw, 0o644); err != nil {
+		slog.Warn("failed to write job metadata", "id", j.ID, "error", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		slog.Warn("failed to write job metadata", "id", j.ID, "error", err)
+	}
+}
+
+// removeFiles deletes the directory of a job.
+func (m *jobManager) removeFiles(id string) {
+	if err := os.RemoveAll(filepath.Join(m.dir, id)); err != nil {
+		slog.Warn("failed to remove job files", "id", id, "error", err)
+	}
+}
+
+// resultPath returns the path of a job's result file.
+func (m *jobManager) resultPath(j job) string {
+	return filepath.Join(m.dir, j.ID, "results."+string(j.OutputFormat))
+}
+
+// lookupJobItem resolves a single query of a job; IP results are not cached.
+func lookupJobItem(ctx context.Context, geoIP *db.GeoIPManager, key *apiKey, query, lang string) streamResult {
+	if err := key.permits(query); err != nil {
+		return streamResult{Query: query, Error: err.Error()}
+	}
+	if _, ok := key.charge(1); !ok {
+		return streamResult{Query: query, Error: errQuotaExceeded.Error()}
+	}
+	if net.ParseIP(query) != nil {
+		return lookupStreamItem(ctx, geoIP, query, lang)
+	}
+
+	data, err := lookupBatchItem(ctx, geoIP, query, lang)
+	if err != nil {
+		return streamResult{Query: query, Error: err.Error()}
+	}
+	return streamResult{Query: query, Data: data}
+}
+
+// newJobID returns a random job identifier.
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validJobID reports whether id has the form of a job identifier, so it is safe to use in a path.
+func validJobID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"ipinfo/internal/db"
+)
+
+// LookupAddress resolves an IP address or CIDR network the same way a batch entry is resolved,
+// with place names in the closest available match for lang.
+func LookupAddress(ctx context.Context, geoIP *db.GeoIPManager, query, lang string) (any, error) {
+	data, ok, err := lookupAddressItem(ctx, geoIP, strings.TrimSpace(query), matchLanguage(geoIP, lang, ""))
+	if !ok {
+		return nil, errors.New("invalid query: must be an ip address or cidr network")
+	}
+	return data, err
+}
+
+// LookupASN resolves an ASN such as "AS13335" or "13335".
+func LookupASN(ctx context.Context, geoIP *db.GeoIPManager, query string) (any, error) {
+	return lookupASNItem(ctx, geoIP, strings.TrimSpace(query))
+}
+
+// LookupDomain resolves the WHOIS and DNS records of a domain.
+func LookupDomain(ctx context.Context, query string) (any, error) {
+	return lookupDomainItem(ctx, strings.TrimSpace(query))
+}
+
+// Result combines a query with its data or error, as written in NDJSON by the stream and job endpoints.
+func Result(query string, data any, err error) any {
+	result := streamResult{Query: query, Data: data}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ResultRow combines a query with its data or error into a single row with the data fields next to the
+// query, laid out like the rows of CSV job results.
+func ResultRow(query string, data any, err error) (any, error) {
+	row := orderedMap{{Key: "query", Value: query}, {Key: "error", Value: ""}}
+	if err != nil {
+		row[1].Value = err.Error()
+	}
+	if data != nil {
+		tree, err := toTree(data)
+		if err != nil {
+			return nil, err
+		}
+		fields, _ := tree.(orderedMap)
+		row = append(row, fields...)
+	}
+	return row, nil
+}
+
+// Encode writes data in one of the response formats, such as "json", "csv" or "text".
+func Encode(w io.Writer, format string, data any, pretty bool) error {
+	enc, ok := encodersByFormat[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	return enc.encode(w, data, pretty)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/config"
+	"ipinfo/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestLookupAddress(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	provider := tracing.Install(exporter, config.TracingConfig{SampleRate: 1, ServiceName: "ipinfo-test"})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+	// A fresh cache, so that no earlier test has filled it.
+	common.Configure(config.Default())
+	geoIP := newTestGeoIP(t)
+
+	// reverseDNSSpans looks up the query and counts the reverse DNS queries it made.
+	reverseDNSSpans := func(ctx context.Context, query string) (*common.DataStruct, int) {
+		t.Helper()
+		exporter.Reset()
+		data, err := LookupAddress(ctx, geoIP, query, "")
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for _, span := range exporter.GetSpans() {
+			if span.Name == "reverse_dns" {
+				count++
+			}
+		}
+		ipData, _ := data.(*common.DataStruct)
+		return ipData, count
+	}
+
+	data, spans := reverseDNSSpans(common.WithoutReverseDNS(context.Background()), "8.8.8.8")
+	if spans != 0 {
+		t.Errorf("offline lookup made %d reverse DNS queries", spans)
+	}
+	if data == nil || *data.Country != "US" || data.Hostname != nil {
+		t.Errorf("offline lookup data %+v", data)
+	}
+	if _, spans := reverseDNSSpans(common.WithoutReverseDNS(context.Background()), "8.8.8.0/30"); spans != 0 {
+		t.Errorf("offline network lookup made %d reverse DNS queries", spans)
+	}
+
+	// The offline lookup did not fill the cache, so the next lookup resolves the hostname.
+	if _, spans := reverseDNSSpans(context.Background(), "8.8.8.8"); spans != 1 {
+		t.Errorf("lookup made %d reverse DNS queries, want 1", spans)
+	}
+
+	if _, err := LookupAddress(context.Background(), geoIP, "AS15169", ""); err == nil {
+		t.Error("ASN accepted as an address")
+	}
+}
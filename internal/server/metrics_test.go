@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"ipinfo/internal/config"
+)
+
+func TestMetrics(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, true), config.RateLimitConfig{})
+	metricsServer := newMetricsServer(config.Default().Server)
+
+	if rec := serveTest(router, http.MethodGet, "/health", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("health: status %d", rec.Code)
+	}
+
+	// The API does not serve the metrics, not even to a valid key.
+	rec := serveTest(router, http.MethodGet, "/metrics", "batch-secret", "")
+	if strings.Contains(rec.Body.String(), "ipinfo_http_requests_total") {
+		t.Errorf("API router served the metrics with status %d", rec.Code)
+	}
+	if rec := serveTest(router, http.MethodGet, "/metrics", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("/metrics without a key: status %d, want 401", rec.Code)
+	}
+
+	rec = serveTest(metricsServer.Handler, http.MethodGet, "/metrics", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("metrics listener: status %d", rec.Code)
+	}
+	if want := `ipinfo_http_requests_total{code="200",method="GET",route="health"}`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("metrics do not contain %s", want)
+	}
+	if rec := serveTest(metricsServer.Handler, http.MethodGet, "/8.8.8.8", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("metrics listener served a lookup with status %d", rec.Code)
+	}
+}
@@ -1,57 +1,73 @@
 package server
 
 import (
-	"compress/gzip"
-	"fmt"
-	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"ipinfo/internal/metrics"
 )
 
-// gzipResponseWriter is a wrapper for gzip compression.
-type gzipResponseWriter struct {
+// statusRecorder remembers the status code and the number of body bytes written by a handler.
+type statusRecorder struct {
 	http.ResponseWriter
-	Writer *gzip.Writer
+	status int
+	size   int
 }
 
-func (w gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
 }
 
-func (w gzipResponseWriter) Close() {
-	if err := w.Writer.Close(); err != nil {
-		slog.Error("failed to close gzip writer", "error", err)
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
 	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
 }
 
-// newGzipResponseWriter wraps the ResponseWriter if the client accepts gzip.
-func newGzipResponseWriter(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
-	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-		w.Header().Set("Content-Encoding", "gzip")
-		gz := gzip.NewWriter(w)
-		return gzipResponseWriter{ResponseWriter: w, Writer: gz}
-	}
-	return w
+// FlushError sends the response written so far.
+func (w *statusRecorder) FlushError() error {
+	return http.NewResponseController(w.ResponseWriter).Flush()
 }
 
-// loggingMiddleware logs the incoming HTTP request and its duration.
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/favicon.ico" {
-			next.ServeHTTP(w, r)
-			return
-		}
+// Flush implements http.Flusher for handlers that do not use http.ResponseController.
+func (w *statusRecorder) Flush() {
+	_ = w.FlushError()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
 
+// metricsMiddleware counts requests and records their latency by route, method and status code.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		duration := time.Since(start)
-
-		slog.Info(fmt.Sprintf("%s %s from %s in %s",
-			r.Method,
-			r.URL.Path,
-			GetRealIP(r),
-			duration,
-		))
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		labels := []string{routeLabel(r), r.Method, strconv.Itoa(recorder.status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
 	})
 }
+
+// routeLabel names the route of a request for metrics, keeping the number of distinct values small.
+func routeLabel(r *http.Request) string {
+	if endpoint := requestEndpoint(r); endpoint != "" {
+		return endpoint
+	}
+	first, _, _ := strings.Cut(strings.Trim(r.URL.Path, "/"), "/")
+	return first
+}
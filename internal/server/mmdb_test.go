@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"ipinfo/internal/config"
+	"ipinfo/internal/db"
+)
+
+// testBuildEpoch is the build time of the test databases, which the DNS server uses as the SOA serial.
+const testBuildEpoch = 1767225600
+
+// testASNNetworks and testCityNetworks are the contents of the test databases.
+var (
+	testASNNetworks = map[string]map[string]any{
+		"8.8.8.0/24": {"autonomous_system_number": uint32(15169), "autonomous_system_organization": "Google LLC"},
+		"1.1.1.0/24": {"autonomous_system_number": uint32(13335), "autonomous_system_organization": "Cloudflare, Inc."},
+		"2001:4860::/32": {
+			"autonomous_system_number": uint32(15169), "autonomous_system_organization": "Google LLC",
+		},
+	}
+	testCityNetworks = map[string]map[string]any{
+		"8.8.8.0/24":     testCityRecord("Mountain View", "US", "United States", 37.386, -122.0838),
+		"1.1.1.0/24":     testCityRecord("Sydney", "AU", "Australia", -33.8688, 151.209),
+		"2001:4860::/32": testCityRecord("Mountain View", "US", "United States", 37.386, -122.0838),
+		// A city record with every field and names in several languages, which no ASN announces.
+		"81.2.69.0/24": {
+			"city":      map[string]any{"names": map[string]any{"en": "London", "fr": "Londres", "pt-BR": "Londres"}},
+			"continent": map[string]any{"code": "EU", "names": map[string]any{"en": "Europe", "de": "Europa"}},
+			"country": map[string]any{
+				"iso_code":             "GB",
+				"is_in_european_union": false,
+				"names":                map[string]any{"en": "United Kingdom", "de": "Vereinigtes Königreich", "fr": "Royaume-Uni"},
+			},
+			"location": map[string]any{"accuracy_radius": uint16(20), "latitude": 51.5142, "longitude": -0.0931},
+			"postal":   map[string]any{"code": "EC2V"},
+			"subdivisions": []any{
+				map[string]any{"iso_code": "ENG", "names": map[string]any{"en": "England", "fr": "Angleterre"}},
+				map[string]any{"iso_code": "LND", "names": map[string]any{"en": "City of London"}},
+			},
+		},
+	}
+)
+
+// testLanguages are the languages of place names in the test databases.
+var testLanguages = []any{"en", "de", "fr", "pt-BR"}
+
+// testCityRecord returns a city database record.
+func testCityRecord(city, country, countryName string, lat, lon float64) map[string]any {
+	return map[string]any{
+		"city":      map[string]any{"names": map[string]any{"en": city}},
+		"continent": map[string]any{"code": "NA", "names": map[string]any{"en": "North America"}},
+		"country": map[string]any{
+			"iso_code":             country,
+			"is_in_european_union": false,
+			"names":                map[string]any{"en": countryName},
+		},
+		"location": map[string]any{"accuracy_radius": uint16(100), "latitude": lat, "longitude": lon},
+	}
+}
+
+// newTestGeoIP writes the test databases and opens them.
+func newTestGeoIP(t *testing.T) *db.GeoIPManager {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.DatabaseConfig{CityPath: filepath.Join(dir, "city.mmdb"), ASNPath: filepath.Join(dir, "asn.mmdb")}
+	if err := writeTestMMDB(cfg.CityPath, "DBIP-City-Lite", testCityNetworks); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestMMDB(cfg.ASNPath, "DBIP-ASN-Lite", testASNNetworks); err != nil {
+		t.Fatal(err)
+	}
+	geoIP, err := db.OpenGeoIPManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(geoIP.Close)
+	return geoIP
+}
+
+// mmdbNode is a node of the search tree of a MaxMind DB. A node with data is a leaf.
+type mmdbNode struct {
+	children [2]*mmdbNode
+	data     []byte
+	index    uint32
+}
+
+// writeTestMMDB writes an IPv6 MaxMind DB with 24-bit records that maps each network to its record.
+// IPv4 networks are stored in the IPv4-compatible range ::/96, as readers expect.
+func writeTestMMDB(path, databaseType string, networks map[string]map[string]any) error {
+	root := &mmdbNode{}
+	var data bytes.Buffer
+	for _, cidr := range slices.Sorted(maps.Keys(networks)) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		ones, bits := network.Mask.Size()
+		ip := network.IP.To16()
+		if bits == 32 {
+			ip = append(make(net.IP, 12), network.IP.To4()...)
+			ones += 96
+		}
+
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &mmdbNode{}
+			}
+			node = node.children[bit]
+		}
+		node.data = binary.BigEndian.AppendUint32(nil, uint32(data.Len()))
+		if err := encodeMMDB(&data, networks[cidr]); err != nil {
+			return fmt.Errorf("encoding %s: %w", cidr, err)
+		}
+	}
+
+	var nodes []*mmdbNode
+	var number func(node *mmdbNode)
+	number = func(node *mmdbNode) {
+		if node == nil || node.data != nil {
+			return
+		}
+		node.index = uint32(len(nodes))
+		nodes = append(nodes, node)
+		number(node.children[0])
+		number(node.children[1])
+	}
+	number(root)
+
+	nodeCount := uint32(len(nodes))
+	var out bytes.Buffer
+	for _, node := range nodes {
+		for _, child := range node.children {
+			record := nodeCount
+			switch {
+			case child == nil:
+			case child.data != nil:
+				record = nodeCount + 16 + binary.BigEndian.Uint32(child.data)
+			default:
+				record = child.index
+			}
+			out.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	if err := encodeMMDB(&out, map[string]any{
+		"node_count":                  nodeCount,
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(6),
+		"database_type":               databaseType,
+		"languages":                   testLanguages,
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(testBuildEpoch),
+		"description":                 map[string]any{"en": "test database"},
+	}); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0o600)
+}
+
+// encodeMMDB appends a value in the data section format of a MaxMind DB.
+func encodeMMDB(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case string:
+		mmdbControl(buf, 2, len(v))
+		buf.WriteString(v)
+	case float64:
+		mmdbControl(buf, 3, 8)
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+	case uint16:
+		mmdbUint(buf, 5, uint64(v))
+	case uint32:
+		mmdbUint(buf, 6, uint64(v))
+	case uint64:
+		mmdbUint(buf, 9, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		mmdbControl(buf, 14, size)
+	case map[string]any:
+		mmdbControl(buf, 7, len(v))
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			if err := encodeMMDB(buf, key); err != nil {
+				return err
+			}
+			if err := encodeMMDB(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		mmdbControl(buf, 11, len(v))
+		for _, item := range v {
+			if err := encodeMMDB(buf, item); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", value)
+	}
+	return nil
+}
+
+// mmdbUint appends an unsigned integer in as few bytes as it needs.
+func mmdbUint(buf *bytes.Buffer, typ int, v uint64) {
+	raw := binary.BigEndian.AppendUint64(nil, v)
+	raw = bytes.TrimLeft(raw, "\x00")
+	mmdbControl(buf, typ, len(raw))
+	buf.Write(raw)
+}
+
+// mmdbControl appends the control byte of a value, with the extended type byte for types above 7.
+// Sizes up to 284 are supported, which is plenty for the test data.
+func mmdbControl(buf *bytes.Buffer, typ, size int) {
+	control := byte(typ << 5)
+	if typ > 7 {
+		control = 0
+	}
+	if size < 29 {
+		control |= byte(size)
+	} else {
+		control |= 29
+	}
+	buf.WriteByte(control)
+	if typ > 7 {
+		buf.WriteByte(byte(typ - 7))
+	}
+	if size >= 29 {
+		buf.WriteByte(byte(size - 29))
+	}
+}
@@ -0,0 +1,604 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	// bigIntType is encoded as a JSON number rather than as a struct.
+	bigIntType = reflect.TypeOf(big.Int{})
+	// timeType is encoded as an RFC 3339 string rather than as a struct.
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// schemaDocuments maps the names served under /schemas/ to their root types.
+var schemaDocuments = map[string]reflect.Type{
+	"ip.json":      ipDataType,
+	"network.json": networkDataType,
+	"asn.json":     asnDataType,
+	"domain.json":  domainDataType,
+	"whois.json":   whoisInfoType,
+	"bogon.json":   bogonDataType,
+	"error.json":   errorType,
+	"job.json":     jobType,
+}
+
+// schemaBuilder derives JSON Schemas from the response structs, so the spec always matches them.
+type schemaBuilder struct {
+	refPrefix string
+	defs      map[string]any
+}
+
+// newSchemaBuilder creates a schemaBuilder whose references point at refPrefix.
+func newSchemaBuilder(refPrefix string) *schemaBuilder {
+	return &schemaBuilder{
+		refPrefix: refPrefix,
+		defs:      make(map[string]any),
+	}
+}
+
+// schemaFor returns the schema for t, registering named structs as definitions.
+func (b *schemaBuilder) schemaFor(t reflect.Type) map[string]any {
+	if t == bigIntType {
+		return map[string]any{"type": "integer"}
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := b.defs[name]; !ok {
+			b.defs[name] = nil
+			b.defs[name] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": b.refPrefix + name}
+	}
+	return map[string]any{}
+}
+
+// structSchema returns an object schema with one property per JSON field of t.
+// Fields without omitempty are required, and those that can encode as null are nullable.
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := orderedMap{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		omitEmpty := strings.Contains(","+options+",", ",omitempty,")
+
+		schema, ok := b.fieldOverride(t, field)
+		if !ok {
+			schema = b.schemaFor(field.Type)
+			if !omitEmpty && isNullable(field.Type) {
+				schema = nullable(schema)
+			}
+		}
+
+		properties = append(properties, mapEntry{Key: name, Value: schema})
+		if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// fieldOverride describes fields whose Go type does not capture their JSON shape.
+func (b *schemaBuilder) fieldOverride(t reflect.Type, field reflect.StructField) (map[string]any, bool) {
+	if t == domainDataType && field.Name == "Whois" {
+		return map[string]any{
+			"anyOf": []any{
+				b.schemaFor(whoisInfoType),
+				map[string]any{"type": "string", "description": "Raw WHOIS text, returned when it could not be parsed."},
+				map[string]any{"type": "null"},
+			},
+		}, true
+	}
+	if t == streamType && field.Name == "Data" {
+		return map[string]any{"oneOf": []any{b.schemaFor(ipDataType), b.schemaFor(bogonDataType)}}, true
+	}
+	return nil, false
+}
+
+// isNullable reports whether a value of type t can be encoded as JSON null.
+func isNullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// nullable extends a schema to also accept null.
+func nullable(schema map[string]any) map[string]any {
+	if typeName, ok := schema["type"].(string); ok {
+		extended := make(map[string]any, len(schema))
+		for key, value := range schema {
+			extended[key] = value
+		}
+		extended["type"] = []string{typeName, "null"}
+		return extended
+	}
+	if len(schema) == 0 {
+		return schema
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+// schemaName returns the exported schema name for a Go type.
+func schemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	if len(name) > 0 {
+		name[0] = unicode.ToUpper(name[0])
+	}
+	return string(name)
+}
+
+// buildJSONSchema returns a standalone JSON Schema document for t.
+func buildJSONSchema(id string, t reflect.Type) map[string]any {
+	b := newSchemaBuilder("#/$defs/")
+	root := b.schemaFor(t)
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     id,
+		"$ref":    root["$ref"],
+		"$defs":   b.defs,
+	}
+}
+
+// buildOpenAPISpec returns the OpenAPI 3.1 description of every route served by newRouter.
+// When authentication is enabled, every lookup route requires one of the API key security schemes.
+func buildOpenAPISpec(maxBatchSize int, authEnabled bool) map[string]any {
+	b := newSchemaBuilder("#/components/schemas/")
+
+	ipSchema := b.schemaFor(ipDataType)
+	networkSchema := b.schemaFor(networkDataType)
+	asnSchema := b.schemaFor(asnDataType)
+	domainSchema := b.schemaFor(domainDataType)
+	bogonSchema := b.schemaFor(bogonDataType)
+	errorSchema := b.schemaFor(errorType)
+	jobSchema := b.schemaFor(jobType)
+	b.schemaFor(whoisInfoType)
+
+	projectionSchema := map[string]any{
+		"type":        "object",
+		"description": "The requested fields, nested the same way as in the full response.",
+	}
+
+	errorResponses := func(codes map[string]string) map[string]any {
+		responses := make(map[string]any, len(codes))
+		for code, description := range codes {
+			responses[code] = map[string]any{"description": description, "content": responseContent(errorSchema)}
+		}
+		return responses
+	}
+
+	withErrors := func(ok map[string]any, codes map[string]string) map[string]any {
+		responses := errorResponses(codes)
+		responses["200"] = ok
+		return responses
+	}
+
+	commonParams := []any{
+		map[string]any{"$ref": "#/components/parameters/fields"},
+		map[string]any{"$ref": "#/components/parameters/lang"},
+		map[string]any{"$ref": "#/components/parameters/format"},
+		map[string]any{"$ref": "#/components/parameters/pretty"},
+	}
+
+	queryParam := map[string]any{
+		"name":        "query",
+		"in":          "path",
+		"required":    true,
+		"description": "An IP address, an ASN such as AS13335, a domain name, or a top-level IP field to look up for the caller's address.",
+		"schema":      map[string]any{"type": "string"},
+	}
+
+	fieldParam := map[string]any{
+		"name":        "field",
+		"in":          "path",
+		"required":    true,
+		"description": "A dotted field path of the IP or domain response, or a prefix length that turns the IP address into a CIDR network.",
+		"schema":      map[string]any{"type": "string"},
+	}
+
+	createJobResponses := errorResponses(map[string]string{
+		"400": "The upload is not a valid CSV or NDJSON file of queries.",
+		"413": "The upload is too large.",
+		"503": "The job queue is full.",
+	})
+	createJobResponses["202"] = map[string]any{
+		"description": "The job was queued. The Location header points at the job.",
+		"content":     responseContent(jobSchema),
+	}
+
+	jobIDParam := map[string]any{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]any{"type": "string", "pattern": "^[0-9a-f]{32}$"},
+	}
+
+	paths := map[string]any{
+		"/": map[string]any{
+			"get": map[string]any{
+				"summary":    "Look up the caller's IP address",
+				"parameters": commonParams,
+				"responses": withErrors(map[string]any{
+					"description": "IP details, or a bogon marker for reserved addresses.",
+					"content":     responseContent(map[string]any{"oneOf": []any{ipSchema, bogonSchema, projectionSchema}}),
+				}, map[string]string{
+					"400": "Invalid field or format.",
+					"404": "No data for the IP address.",
+				}),
+			},
+		},
+		"/{query}": map[string]any{
+			"get": map[string]any{
+				"summary":    "Look up an IP address, ASN or domain",
+				"parameters": append([]any{queryParam}, commonParams...),
+				"responses": withErrors(map[string]any{
+					"description": "IP, ASN or domain details depending on the query.",
+					"content":     responseContent(map[string]any{"oneOf": []any{ipSchema, bogonSchema, asnSchema, domainSchema, projectionSchema}}),
+				}, map[string]string{
+					"400": "Invalid query, field or format.",
+					"404": "No data for the query.",
+					"500": "The lookup failed.",
+				}),
+			},
+		},
+		"/{query}/{field}": map[string]any{
+			"get": map[string]any{
+				"summary":    "Look up a single field or a CIDR network",
+				"parameters": append([]any{queryParam, fieldParam}, commonParams...),
+				"responses": withErrors(map[string]any{
+					"description": "The requested field, or network details when field is a prefix length.",
+					"content":     responseContent(map[string]any{"oneOf": []any{projectionSchema, networkSchema, bogonSchema}}),
+				}, map[string]string{
+					"400": "Invalid query, field or format.",
+					"404": "No data for the query.",
+					"500": "The lookup failed.",
+				}),
+			},
+		},
+		"/batch": map[string]any{
+			"post": map[string]any{
+				"summary": "Look up many IP addresses, networks, ASNs and domains at once",
+				"parameters": []any{
+					map[string]any{"$ref": "#/components/parameters/lang"},
+					map[string]any{"$ref": "#/components/parameters/format"},
+					map[string]any{"$ref": "#/components/parameters/pretty"},
+				},
+				"requestBody": map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"application/json": map[string]any{
+							"schema": map[string]any{
+								"type":     "array",
+								"items":    map[string]any{"type": "string"},
+								"minItems": 1,
+								"maxItems": maxBatchSize,
+							},
+						},
+					},
+				},
+				"responses": withErrors(map[string]any{
+					"description": "One result per distinct query, keyed by the query.",
+					"content": responseContent(map[string]any{
+						"type": "object",
+						"additionalProperties": map[string]any{
+							"oneOf": []any{ipSchema, bogonSchema, networkSchema, asnSchema, domainSchema, errorSchema},
+						},
+					}),
+				}, map[string]string{
+					"400": "The body is not a non-empty JSON array of strings.",
+					"413": "The batch has too many items.",
+				}),
+			},
+		},
+		"/stream": map[string]any{
+			"post": map[string]any{
+				"summary":     "Look up a newline-delimited list of IP addresses as a stream",
+				"description": "Results are written as NDJSON in input order while the request body is still being read.",
+				"parameters":  []any{map[string]any{"$ref": "#/components/parameters/lang"}},
+				"requestBody": map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"text/plain": map[string]any{"schema": map[string]any{"type": "string", "description": "One IP address per line."}},
+					},
+				},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "One result per non-empty input line.",
+						"content":     map[string]any{"application/x-ndjson": map[string]any{"schema": b.schemaFor(streamType)}},
+					},
+				},
+			},
+		},
+		"/jobs": map[string]any{
+			"post": map[string]any{
+				"summary":     "Start a bulk lookup job",
+				"description": "Accepts a CSV file with one IP address or domain in the first column, or an NDJSON file of strings or objects with a query, ip or domain field, either as the request body or as the file field of a form.",
+				"parameters": []any{
+					map[string]any{"$ref": "#/components/parameters/lang"},
+					map[string]any{"$ref": "#/components/parameters/format"},
+					map[string]any{"$ref": "#/components/parameters/pretty"},
+					map[string]any{
+						"name":        "input",
+						"in":          "query",
+						"description": "Input format. Defaults to the content type or file extension of the upload.",
+						"schema":      map[string]any{"type": "string", "enum": []string{string(jobCSV), string(jobNDJSON)}},
+					},
+					map[string]any{
+						"name":        "output",
+						"in":          "query",
+						"description": "Result format. Defaults to the input format.",
+						"schema":      map[string]any{"type": "string", "enum": []string{string(jobCSV), string(jobNDJSON)}},
+					},
+				},
+				"requestBody": map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"text/csv":             map[string]any{"schema": map[string]any{"type": "string"}},
+						"application/x-ndjson": map[string]any{"schema": map[string]any{"type": "string"}},
+						"multipart/form-data": map[string]any{
+							"schema": map[string]any{
+								"type":       "object",
+								"properties": map[string]any{"file": map[string]any{"type": "string", "contentMediaType": "application/octet-stream"}},
+								"required":   []string{"file"},
+							},
+						},
+					},
+				},
+				"responses": createJobResponses,
+			},
+		},
+		"/jobs/{id}": map[string]any{
+			"parameters": []any{jobIDParam},
+			"get": map[string]any{
+				"summary":    "Get the status and progress of a job",
+				"parameters": []any{map[string]any{"$ref": "#/components/parameters/format"}, map[string]any{"$ref": "#/components/parameters/pretty"}},
+				"responses": withErrors(map[string]any{
+					"description": "The job.",
+					"content":     responseContent(jobSchema),
+				}, map[string]string{"404": "The job does not exist or has expired."}),
+			},
+			"delete": map[string]any{
+				"summary": "Cancel a job and delete it with its files",
+				"responses": map[string]any{
+					"204": map[string]any{"description": "The job was deleted."},
+					"404": map[string]any{"description": "The job does not exist or has expired.", "content": responseContent(errorSchema)},
+				},
+			},
+		},
+		"/jobs/{id}/result": map[string]any{
+			"parameters": []any{jobIDParam},
+			"get": map[string]any{
+				"summary": "Download the result file of a completed job",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "One row or line per query, in input order.",
+						"content": map[string]any{
+							"text/csv":             map[string]any{"schema": map[string]any{"type": "string"}},
+							"application/x-ndjson": map[string]any{"schema": b.schemaFor(streamType)},
+						},
+					},
+					"404": map[string]any{"description": "The job does not exist or has expired.", "content": responseContent(errorSchema)},
+					"409": map[string]any{"description": "The job has not completed.", "content": responseContent(errorSchema)},
+				},
+			},
+		},
+		"/health": map[string]any{
+			"get": map[string]any{
+				"summary":  "Check that the service is running",
+				"security": []any{},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The service is healthy.",
+						"content":     map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}},
+					},
+				},
+			},
+		},
+		"/openapi.json": map[string]any{
+			"get": map[string]any{
+				"summary":  "Get this OpenAPI description",
+				"security": []any{},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The OpenAPI document.",
+						"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}},
+					},
+				},
+			},
+		},
+		"/schemas/{name}": map[string]any{
+			"get": map[string]any{
+				"summary":  "Get the JSON Schema of a response type",
+				"security": []any{},
+				"parameters": []any{map[string]any{
+					"name":     "name",
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]any{"type": "string", "enum": sortedKeys(schemaDocuments)},
+				}},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The JSON Schema document.",
+						"content":     map[string]any{"application/schema+json": map[string]any{"schema": map[string]any{"type": "object"}}},
+					},
+					"404": map[string]any{"description": "Unknown schema."},
+				},
+			},
+		},
+	}
+
+	var formats []string
+	for _, enc := range encoders {
+		formats = append(formats, enc.formats[0])
+	}
+
+	spec := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "ipinfo",
+			"description": "IP geolocation, ASN and domain WHOIS/DNS lookups.",
+			"version":     buildVersion(),
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.defs,
+			"securitySchemes": map[string]any{
+				"bearer":       map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKeyHeader": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"apiKeyQuery":  map[string]any{"type": "apiKey", "in": "query", "name": "token"},
+			},
+			"parameters": map[string]any{
+				"fields": map[string]any{
+					"name":        "fields",
+					"in":          "query",
+					"description": "Comma-separated list of dotted field paths to return, such as city,country or details.name.",
+					"schema":      map[string]any{"type": "string"},
+				},
+				"lang": map[string]any{
+					"name":        "lang",
+					"in":          "query",
+					"description": "Language for place names. Overrides the Accept-Language header and falls back to English.",
+					"schema":      map[string]any{"type": "string"},
+				},
+				"format": map[string]any{
+					"name":        "format",
+					"in":          "query",
+					"description": "Response format. Overrides the Accept header.",
+					"schema":      map[string]any{"type": "string", "enum": formats, "default": jsonEncoder.formats[0]},
+				},
+				"pretty": map[string]any{
+					"name":        "pretty",
+					"in":          "query",
+					"description": "Indent JSON and XML output.",
+					"schema":      map[string]any{"type": "boolean", "default": true},
+				},
+			},
+		},
+	}
+
+	if authEnabled {
+		spec["security"] = []any{
+			map[string]any{"bearer": []any{}},
+			map[string]any{"apiKeyHeader": []any{}},
+			map[string]any{"apiKeyQuery": []any{}},
+		}
+	}
+	return spec
+}
+
+// responseContent lists schema under the media type of every registered encoder.
+func responseContent(schema map[string]any) map[string]any {
+	content := make(map[string]any, len(encoders))
+	for _, enc := range encoders {
+		content[enc.mediaTypes[0]] = map[string]any{"schema": schema}
+	}
+	return content
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]reflect.Type) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// buildVersion returns the module version the binary was built from.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// openAPIHandler serves the OpenAPI document.
+func openAPIHandler(maxBatchSize int, authEnabled bool) http.HandlerFunc {
+	spec, err := json.MarshalIndent(buildOpenAPISpec(maxBatchSize, authEnabled), "", "  ")
+	if err != nil {
+		slog.Error("failed to build openapi spec", "error", err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			sendError(w, r, "Error building API description.", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(spec)
+	}
+}
+
+// schemaHandler serves the JSON Schema documents of the response types.
+func schemaHandler() http.HandlerFunc {
+	documents := make(map[string][]byte, len(schemaDocuments))
+	for name, t := range schemaDocuments {
+		document, err := json.MarshalIndent(buildJSONSchema("/schemas/"+name, t), "", "  ")
+		if err != nil {
+			slog.Error("failed to build json schema", "name", name, "error", err)
+			continue
+		}
+		documents[name] = document
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		document, ok := documents[r.PathValue("name")]
+		if !ok {
+			sendError(w, r, "Please provide a valid schema name.", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(document)
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ipinfo/internal/common"
+)
+
+// servedSchemas fetches /openapi.json and returns its component schemas.
+func servedSchemas(t *testing.T) map[string]any {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	openAPIHandler(100, false)(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", rec.Code)
+	}
+	var spec struct {
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decoding spec: %v", err)
+	}
+	return spec.Components.Schemas
+}
+
+// jsonFieldNames returns the names t encodes its fields as, in order.
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// filled returns a value of type t with every pointer, slice and field set, so that each property is
+// present when encoded.
+func filled(t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+	fill(v)
+	return v
+}
+
+// fill sets v and everything it holds to a non-zero value.
+func fill(v reflect.Value) {
+	switch v.Type() {
+	case bigIntType:
+		v.Set(reflect.ValueOf(*big.NewInt(256)))
+		return
+	case timeType:
+		v.Set(reflect.ValueOf(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem())
+	case reflect.String:
+		v.SetString("value")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i))
+			}
+		}
+	}
+}
+
+// validate checks a decoded JSON value against a schema of the spec and reports every mismatch.
+func validate(t *testing.T, schemas map[string]any, path string, value any, schema map[string]any) {
+	t.Helper()
+	if errs := schemaErrors(schemas, path, value, schema); len(errs) > 0 {
+		t.Errorf("%s", strings.Join(errs, "\n"))
+	}
+}
+
+// schemaErrors lists where value does not match schema.
+func schemaErrors(schemas map[string]any, path string, value any, schema map[string]any) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		target, ok := schemas[name].(map[string]any)
+		if !ok {
+			return []string{path + ": unresolved reference " + ref}
+		}
+		return schemaErrors(schemas, path, value, target)
+	}
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if options, ok := schema[key].([]any); ok {
+			for _, option := range options {
+				if len(schemaErrors(schemas, path, value, option.(map[string]any))) == 0 {
+					return nil
+				}
+			}
+			return []string{path + ": matches none of " + key}
+		}
+	}
+
+	if types, ok := schema["type"]; ok {
+		allowed := []string{}
+		switch ty := types.(type) {
+		case string:
+			allowed = append(allowed, ty)
+		case []any:
+			for _, name := range ty {
+				allowed = append(allowed, name.(string))
+			}
+		}
+		if kind := jsonKind(value); !slices.Contains(allowed, kind) && !(kind == "integer" && slices.Contains(allowed, "number")) {
+			return []string{path + ": " + kind + " is not " + strings.Join(allowed, " or ")}
+		}
+	}
+
+	var errs []string
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		for key, item := range v {
+			propertySchema, ok := properties[key].(map[string]any)
+			if !ok {
+				propertySchema = additional
+			}
+			if propertySchema == nil {
+				errs = append(errs, path+"."+key+": not in the schema")
+				continue
+			}
+			errs = append(errs, schemaErrors(schemas, path+"."+key, item, propertySchema)...)
+		}
+		required, _ := schema["required"].([]any)
+		for _, key := range required {
+			if _, ok := v[key.(string)]; !ok {
+				errs = append(errs, path+"."+key.(string)+": required but missing")
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for _, item := range v {
+				errs = append(errs, schemaErrors(schemas, path+"[]", item, items)...)
+			}
+		}
+	}
+	return errs
+}
+
+// jsonKind names the JSON Schema type of a decoded JSON value.
+func jsonKind(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+// encodeDecoded round-trips value through JSON into generic maps and slices.
+func encodeDecoded(t *testing.T, value any) any {
+	t.Helper()
+	raw, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("encoding %T: %v", value, err)
+	}
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("decoding %T: %v", value, err)
+	}
+	return decoded
+}
+
+// TestSpecMatchesResponseStructs fails when a response struct and the schema published for it in the
+// OpenAPI document drift apart.
+func TestSpecMatchesResponseStructs(t *testing.T) {
+	schemas := servedSchemas(t)
+
+	for _, ty := range []reflect.Type{ipDataType, asnDataType, networkDataType, domainDataType, whoisInfoType} {
+		t.Run(ty.Name(), func(t *testing.T) {
+			schema, ok := schemas[schemaName(ty)].(map[string]any)
+			if !ok {
+				t.Fatalf("no schema %s in the spec", schemaName(ty))
+			}
+
+			properties, _ := schema["properties"].(map[string]any)
+			var specNames []string
+			for name := range properties {
+				specNames = append(specNames, name)
+			}
+			structNames := jsonFieldNames(ty)
+			slices.Sort(specNames)
+			slices.Sort(structNames)
+			if !slices.Equal(specNames, structNames) {
+				t.Errorf("spec properties %v, struct fields %v", specNames, structNames)
+			}
+
+			ref := map[string]any{"$ref": "#/components/schemas/" + schemaName(ty)}
+			validate(t, schemas, ty.Name(), encodeDecoded(t, filled(ty).Interface()), ref)
+			validate(t, schemas, ty.Name()+"(zero)", encodeDecoded(t, reflect.New(ty).Elem().Interface()), ref)
+		})
+	}
+}
+
+// TestSpecMatchesDomainWhoisVariants checks the three shapes the whois field of a domain response takes.
+func TestSpecMatchesDomainWhoisVariants(t *testing.T) {
+	schemas := servedSchemas(t)
+	ref := map[string]any{"$ref": "#/components/schemas/" + schemaName(domainDataType)}
+
+	parsed := filled(whoisInfoType).Interface().(common.WhoisInfo)
+	for name, whois := range map[string]any{"parsed": parsed, "raw": "Domain Name: EXAMPLE.COM", "missing": nil} {
+		t.Run(name, func(t *testing.T) {
+			data := &common.DomainDataResponse{Whois: whois, DNS: common.DNSData{A: []string{"192.0.2.1"}, CNAME: "example.net"}}
+			validate(t, schemas, "domain", encodeDecoded(t, data), ref)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// requestedFields collects the field paths from the URL path segment and the fields query parameter.
+func requestedFields(r *http.Request, pathField string) []string {
+	var fields []string
+	if pathField != "" {
+		fields = append(fields, pathField)
+	}
+	for _, field := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// validFields reports whether every dotted field path exists in the JSON representation of t.
+func validFields(t reflect.Type, fields []string) bool {
+	for _, field := range fields {
+		if !hasFieldPath(t, strings.Split(field, ".")) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasFieldPath follows a dotted JSON path through the struct type t.
+// Paths into interface values, such as parsed whois data, cannot be checked and are accepted.
+func hasFieldPath(t reflect.Type, path []string) bool {
+	for len(path) > 0 {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		case reflect.Interface:
+			return true
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return false
+			}
+			t, path = t.Elem(), path[1:]
+		case reflect.Struct:
+			field, ok := jsonField(t, path[0])
+			if !ok {
+				return false
+			}
+			t, path = field.Type, path[1:]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// jsonField finds the exported struct field encoded under the given JSON name.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tagName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tagName == "-" {
+			continue
+		}
+		if tagName == "" {
+			tagName = field.Name
+		}
+		if tagName == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// projectFields reduces data to the requested dotted field paths, keeping their nesting.
+func projectFields(data any, fields []string) (any, error) {
+	tree, err := toTree(data)
+	if err != nil {
+		return nil, err
+	}
+
+	projected := orderedMap{}
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		projected = insertPath(projected, path, extractPath(tree, path))
+	}
+	return projected, nil
+}
+
+// extractPath returns the value at path, applying the rest of the path to every item of a list.
+func extractPath(v any, path []string) any {
+	if len(path) == 0 {
+		return v
+	}
+
+	switch t := v.(type) {
+	case orderedMap:
+		for _, entry := range t {
+			if entry.Key == path[0] {
+				return extractPath(entry.Value, path[1:])
+			}
+		}
+	case []any:
+		list := make([]any, len(t))
+		for i, item := range t {
+			list[i] = extractPath(item, path)
+		}
+		return list
+	}
+	return nil
+}
+
+// insertPath sets value at path in m, creating intermediate maps as needed.
+func insertPath(m orderedMap, path []string, value any) orderedMap {
+	for i, entry := range m {
+		if entry.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			m[i].Value = value
+		} else {
+			child, _ := entry.Value.(orderedMap)
+			m[i].Value = insertPath(child, path[1:], value)
+		}
+		return m
+	}
+
+	if len(path) == 1 {
+		return append(m, mapEntry{Key: path[0], Value: value})
+	}
+	return append(m, mapEntry{Key: path[0], Value: insertPath(nil, path[1:], value)})
+}
+
+// sendFieldsResponse sends data, reduced to the requested fields when there are any.
+func sendFieldsResponse(w http.ResponseWriter, r *http.Request, data any, fields []string) {
+	if len(fields) == 0 {
+		sendResponse(w, r, data, http.StatusOK)
+		return
+	}
+
+	projected, err := projectFields(data, fields)
+	if err != nil {
+		slog.Error("failed to project response fields", "fields", fields, "error", err)
+		sendError(w, r, "Error selecting fields.", http.StatusInternalServerError)
+		return
+	}
+	sendResponse(w, r, projected, http.StatusOK)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ipinfo/internal/config"
+)
+
+func TestValidFields(t *testing.T) {
+	tests := []struct {
+		typ    reflect.Type
+		fields string
+		want   bool
+	}{
+		{ipDataType, "city,country,org", true},
+		{ipDataType, "subdivisions.name", true},
+		{ipDataType, "city,nope", false},
+		{ipDataType, "city.name", false},
+		{asnDataType, "details.name,prefixes.ipv4", true},
+		{asnDataType, "details.nope", false},
+		{domainDataType, "dns.MX", true},
+		{domainDataType, "whois.registrar.name", true},
+		{domainDataType, "expires", false},
+		{networkDataType, "address_count", true},
+	}
+	for _, tt := range tests {
+		if got := validFields(tt.typ, strings.Split(tt.fields, ",")); got != tt.want {
+			t.Errorf("validFields(%s, %q) = %t, want %t", tt.typ, tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestProjectFields(t *testing.T) {
+	type contact struct {
+		Code string `json:"code"`
+		Name string `json:"name"`
+	}
+	data := struct {
+		IP       string    `json:"ip"`
+		Details  contact   `json:"details"`
+		Contacts []contact `json:"contacts"`
+	}{IP: "8.8.8.8", Details: contact{"US", "Google"}, Contacts: []contact{{"a", "Alice"}, {"b", "Bob"}}}
+
+	projected, err := projectFields(data, []string{"details.name", "ip", "contacts.code", "details.code", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(projected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fields keep the requested order and their nesting, and lists are projected item by item.
+	if want := `{"details":{"name":"Google","code":"US"},"ip":"8.8.8.8","contacts":{"code":["a","b"]},"missing":null}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestFieldsQuery(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/8.8.8.8?fields=city,country,org&pretty=false", `{"city":"Mountain View","country":"US","org":"AS15169 Google LLC"}`},
+		{"/8.8.8.8/country?pretty=false", `{"country":"US"}`},
+		{"/8.8.8.8/country?fields=asn&pretty=false", `{"country":"US","asn":15169}`},
+		{"/AS13335?fields=details.name,details.asn&pretty=false", `{"details":{"name":"Cloudflare, Inc.","asn":13335}}`},
+		{"/8.8.8.0/24?fields=prefix_length&pretty=false", `{"prefix_length":24}`},
+	}
+	for _, tt := range tests {
+		rec := serveTest(router, http.MethodGet, tt.target, "", "")
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != tt.want {
+			t.Errorf("%s: status %d, body %s, want %s", tt.target, rec.Code, got, tt.want)
+		}
+	}
+
+	for _, target := range []string{"/8.8.8.8?fields=nope", "/8.8.8.8/nope", "/AS13335?fields=details.nope", "/8.8.8.0/24?fields=city"} {
+		if rec := serveTest(router, http.MethodGet, target, "", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ipinfo/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Route classes that are rate limited separately.
+const (
+	rateClassIP     = "ip"
+	rateClassASN    = "asn"
+	rateClassDomain = "domain"
+	rateClassBulk   = "bulk"
+)
+
+// memoryBucketSweepInterval is how often idle buckets are dropped from the in-memory store.
+const memoryBucketSweepInterval = time.Minute
+
+// rateLimit is a token bucket that holds up to limit tokens and refills them evenly over window.
+type rateLimit struct {
+	limit  int
+	window time.Duration
+}
+
+// rate returns the number of tokens added per second.
+func (l rateLimit) rate() float64 {
+	return float64(l.limit) / l.window.Seconds()
+}
+
+// newRateLimit converts a configured rate limit.
+func newRateLimit(cfg config.RateLimit) rateLimit {
+	return rateLimit{limit: cfg.Limit, window: cfg.Window}
+}
+
+// rateLimitResult is the state of a bucket after taking a token.
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+// bucketResult derives the reported state of a bucket from its remaining tokens.
+func bucketResult(limit rateLimit, allowed bool, tokens float64) rateLimitResult {
+	rate := limit.rate()
+	result := rateLimitResult{
+		allowed:   allowed,
+		remaining: int(math.Floor(tokens)),
+		reset:     time.Duration((float64(limit.limit) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		result.retryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return result
+}
+
+// rateLimitStore keeps token buckets, either in memory or shared between instances.
+type rateLimitStore interface {
+	take(ctx context.Context, key string, limit rateLimit) (rateLimitResult, error)
+}
+
+// memoryBucket is the state of a token bucket in memory.
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   rateLimit
+}
+
+// memoryStore keeps token buckets in memory for a single instance.
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+// newMemoryStore creates an empty in-memory store.
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *memoryStore) take(_ context.Context, key string, limit rateLimit) (rateLimitResult, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > memoryBucketSweepInterval {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.limit), updated: now, limit: limit}
+		s.buckets[key] = bucket
+	}
+
+	bucket.tokens = min(float64(limit.limit), bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.rate())
+	bucket.updated = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	return bucketResult(limit, allowed, bucket.tokens), nil
+}
+
+// sweep drops buckets that have refilled completely, since they are equal to a new bucket.
+func (s *memoryStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) >= bucket.limit.window {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// redisTokenBucket refills and takes from a bucket atomically, using the Redis clock so instances agree on time.
+var redisTokenBucket = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or limit
+local updated = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - updated) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((limit - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// redisStore keeps token buckets in Redis so every instance shares the same limits.
+type redisStore struct {
+	client *redis.Client
+}
+
+func (s *redisStore) take(ctx context.Context, key string, limit rateLimit) (rateLimitResult, error) {
+	perMillisecond := limit.rate() / 1000
+	values, err := redisTokenBucket.Run(ctx, s.client, []string{"ipinfo:ratelimit:" + key}, limit.limit, perMillisecond).Slice()
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	if len(values) != 2 {
+		return rateLimitResult{}, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	tokenText, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokenText, 64)
+	if err != nil {
+		return rateLimitResult{}, fmt.Errorf("parsing remaining tokens: %w", err)
+	}
+	return bucketResult(limit, allowed == 1, tokens), nil
+}
+
+// rateLimiter limits requests per client and route class with token buckets.
+type rateLimiter struct {
+	limits   map[string]rateLimit
+	redisURL string
+	store    rateLimitStore
+}
+
+// newRateLimiter creates a rate limiter. Buckets are kept in memory unless a Redis URL is configured.
+func newRateLimiter(cfg config.RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		limits: map[string]rateLimit{
+			rateClassIP:     newRateLimit(cfg.IP),
+			rateClassASN:    newRateLimit(cfg.ASN),
+			rateClassDomain: newRateLimit(cfg.Domain),
+			rateClassBulk:   newRateLimit(cfg.Bulk),
+		},
+		redisURL: cfg.RedisURL,
+		store:    newMemoryStore(),
+	}
+}
+
+// start connects to Redis when buckets are shared, closing the connection once ctx is done.
+func (l *rateLimiter) start(ctx context.Context) error {
+	if l.redisURL == "" {
+		return nil
+	}
+
+	options, err := redis.ParseURL(l.redisURL)
+	if err != nil {
+		return fmt.Errorf("parsing rate limit redis url: %w", err)
+	}
+	client := redis.NewClient(options)
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return fmt.Errorf("connecting to rate limit redis: %w", err)
+	}
+
+	l.store = &redisStore{client: client}
+	slog.Info("sharing rate limits through redis", "address", options.Addr)
+
+	go func() {
+		<-ctx.Done()
+		if err := client.Close(); err != nil {
+			slog.Warn("failed to close rate limit redis client", "error", err)
+		}
+	}()
+	return nil
+}
+
+// take removes a token from the client's bucket for class. Unlimited classes always allow the request,
+// and so does a store error, so an unavailable Redis does not take the service down.
+func (l *rateLimiter) take(ctx context.Context, identity, class string) (rateLimit, rateLimitResult) {
+	limit := l.limits[class]
+	if limit.limit == 0 {
+		return limit, rateLimitResult{allowed: true}
+	}
+
+	result, err := l.store.take(ctx, class+":"+identity, limit)
+	if err != nil {
+		slog.Warn("rate limit store failed, allowing request", "class", class, "error", err)
+		return rateLimit{}, rateLimitResult{allowed: true}
+	}
+	return limit, result
+}
+
+// waitQuery blocks until a single batch, stream or job query may be looked up, on top of the bulk request
+// itself. Only domain queries, which reach WHOIS and DNS servers, are charged, to the domain bucket, so
+// large bulk requests of IPs and ASNs are not held to the interactive rate.
+func (l *rateLimiter) waitQuery(ctx context.Context, identity, query string) error {
+	if queryEndpoint(query) != endpointDomain {
+		return nil
+	}
+	for {
+		_, result := l.take(ctx, identity, rateClassDomain)
+		if result.allowed {
+			return nil
+		}
+		select {
+		case <-time.After(result.retryAfter):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// middleware rejects requests once the client has used up the bucket of the route's class,
+// and reports the bucket state in RateLimit headers.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := requestRateClass(r)
+		if class == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit, result := l.take(r.Context(), rateIdentity(r), class)
+		if limit.limit == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.limit, int(limit.window.Seconds())))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+
+		if !result.allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.retryAfter), 1)))
+			sendError(w, r, "Rate limit exceeded, please slow down.", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestRateClass returns the rate limit class of a request, or "" for routes that are not limited.
+func requestRateClass(r *http.Request) string {
+	switch requestEndpoint(r) {
+	case endpointIP, endpointNetwork:
+		return rateClassIP
+	case endpointASN:
+		return rateClassASN
+	case endpointDomain:
+		return rateClassDomain
+	case endpointBatch, endpointStream:
+		return rateClassBulk
+	case endpointJobs:
+		// Polling and downloading jobs is cheap; only starting one is limited.
+		if r.Method == http.MethodPost {
+			return rateClassBulk
+		}
+	}
+	return ""
+}
+
+// rateIdentity identifies the client a request is counted against: its API key, or else its IP address.
+func rateIdentity(r *http.Request) string {
+	if key := requestAPIKey(r); key != nil {
+		return "key:" + key.name
+	}
+	return "ip:" + GetRealIP(r)
+}
+
+// ceilSeconds rounds a duration up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := newMemoryStore()
+	limit := rateLimit{limit: 3, window: time.Minute}
+	ctx := context.Background()
+
+	for i := range 3 {
+		result, _ := store.take(ctx, "ip:192.0.2.1", limit)
+		if !result.allowed || result.remaining != 2-i {
+			t.Fatalf("take %d = %+v, want allowed with %d remaining", i, result, 2-i)
+		}
+	}
+	result, _ := store.take(ctx, "ip:192.0.2.1", limit)
+	if result.allowed || result.remaining != 0 {
+		t.Errorf("take over the limit = %+v, want refused", result)
+	}
+	// One token comes back every 20s, and the bucket is full again after a minute.
+	if result.retryAfter <= 19*time.Second || result.retryAfter > 20*time.Second {
+		t.Errorf("retry after %s, want about 20s", result.retryAfter)
+	}
+	if result.reset <= 59*time.Second || result.reset > time.Minute {
+		t.Errorf("reset after %s, want about 1m", result.reset)
+	}
+
+	// Buckets are kept per key.
+	if result, _ := store.take(ctx, "ip:192.0.2.2", limit); !result.allowed || result.remaining != 2 {
+		t.Errorf("take for another client = %+v, want a full bucket", result)
+	}
+
+	// Tokens refill over time.
+	store.buckets["ip:192.0.2.1"].updated = time.Now().Add(-40 * time.Second)
+	if result, _ := store.take(ctx, "ip:192.0.2.1", limit); !result.allowed || result.remaining != 1 {
+		t.Errorf("take after 40s = %+v, want allowed with 1 remaining", result)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{
+		IP:   config.RateLimit{Limit: 2, Window: time.Minute},
+		Bulk: config.RateLimit{Limit: 1, Window: time.Hour},
+	})
+
+	rec := serveTest(router, http.MethodGet, "/8.8.8.8", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Policy":    "2;w=60",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "30",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Errorf("Retry-After set on an allowed request")
+	}
+
+	// Network lookups share the IP bucket.
+	if rec := serveTest(router, http.MethodGet, "/8.8.8.0/24", "", ""); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("network lookup: status %d, remaining %q", rec.Code, rec.Header().Get("RateLimit-Remaining"))
+	}
+	rec = serveTest(router, http.MethodGet, "/1.1.1.1", "", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("over the limit: status %d, Retry-After %q, want 429 after 30s", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Other classes have buckets of their own, and unlimited classes send no headers.
+	if rec := serveTest(router, http.MethodGet, "/AS15169", "", ""); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("ASN lookup: status %d, RateLimit-Limit %q", rec.Code, rec.Header().Get("RateLimit-Limit"))
+	}
+	if rec := serveTest(router, http.MethodPost, "/batch", "", `["8.8.8.8"]`); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Policy") != "1;w=3600" {
+		t.Errorf("batch: status %d, RateLimit-Policy %q", rec.Code, rec.Header().Get("RateLimit-Policy"))
+	}
+	if rec := serveTest(router, http.MethodPost, "/batch", "", `["8.8.8.8"]`); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second batch: status %d, want 429", rec.Code)
+	}
+
+	// Routes outside the classes are never limited.
+	for range 3 {
+		if rec := serveTest(router, http.MethodGet, "/health", "", ""); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("health: status %d, RateLimit-Limit %q", rec.Code, rec.Header().Get("RateLimit-Limit"))
+		}
+	}
+}
+
+func TestWaitQuery(t *testing.T) {
+	limits := newRateLimiter(config.RateLimitConfig{
+		IP:     config.RateLimit{Limit: 1, Window: time.Hour},
+		Domain: config.RateLimit{Limit: 1, Window: 200 * time.Millisecond},
+	})
+	ctx := context.Background()
+
+	// IP and ASN queries are not charged, even with their interactive buckets used up.
+	limits.take(ctx, "ip:192.0.2.1", rateClassIP)
+	for _, query := range []string{"8.8.8.8", "8.8.8.0/24", "AS15169", "8.8.4.4"} {
+		start := time.Now()
+		if err := limits.waitQuery(ctx, "ip:192.0.2.1", query); err != nil || time.Since(start) > 50*time.Millisecond {
+			t.Errorf("waitQuery(%q) = %v after %s, want no wait", query, err, time.Since(start))
+		}
+	}
+
+	// Domain queries wait for the domain bucket to refill instead of failing.
+	start := time.Now()
+	for range 2 {
+		if err := limits.waitQuery(ctx, "ip:192.0.2.1", "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("second domain query admitted after %s, want it to wait for the refill", elapsed)
+	}
+
+	// A canceled request stops waiting.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := limits.waitQuery(canceled, "ip:192.0.2.1", "example.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("waitQuery with a canceled context = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+
+	"ipinfo/internal/config"
+)
+
+// realIPContextKey is the context key of the client IP resolved by realIPResolver.
+type realIPContextKey struct{}
+
+// realIPResolver determines the client IP, honoring forwarding headers only from trusted proxies.
+type realIPResolver struct {
+	trusted []netip.Prefix
+	headers []string
+}
+
+// newRealIPResolver creates a resolver that trusts the configured proxies ("none" trusts no one) and the
+// ranges in the Cloudflare IPs file, with one CIDR per line, and consults the headers in the configured order.
+func newRealIPResolver(cfg config.ProxyConfig) *realIPResolver {
+	resolver := &realIPResolver{}
+
+	for _, entry := range cfg.TrustedProxies {
+		if entry != "none" {
+			resolver.addTrusted(entry, "trusted_proxies")
+		}
+	}
+
+	if path := cfg.CloudflareIPsFile; path != "" {
+		if err := resolver.loadTrustedFile(path); err != nil {
+			slog.Error("failed to load cloudflare ip ranges, cloudflare headers will not be trusted", "path", path, "error", err)
+		}
+	}
+
+	for _, header := range cfg.RealIPHeaders {
+		if header = strings.TrimSpace(header); header != "" {
+			resolver.headers = append(resolver.headers, http.CanonicalHeaderKey(header))
+		}
+	}
+
+	return resolver
+}
+
+// addTrusted parses a CIDR or a single address and adds it to the trusted proxies.
+func (rr *realIPResolver) addTrusted(entry, source string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return
+	}
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		rr.trusted = append(rr.trusted, prefix.Masked())
+		return
+	}
+	if addr, err := netip.ParseAddr(entry); err == nil {
+		rr.trusted = append(rr.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		return
+	}
+	slog.Warn("ignoring invalid trusted proxy", "source", source, "value", entry)
+}
+
+// loadTrustedFile adds the CIDRs listed in a file, such as Cloudflare's published ranges.
+// Blank lines and lines starting with # are skipped.
+func (rr *realIPResolver) loadTrustedFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rr.addTrusted(line, path)
+	}
+	return scanner.Err()
+}
+
+// isTrusted reports whether addr belongs to a trusted proxy.
+func (rr *realIPResolver) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range rr.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the client IP of a request. Headers are only read when the peer is a trusted proxy;
+// forwarding chains are walked from the right, skipping trusted hops.
+func (rr *realIPResolver) resolve(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !rr.isTrusted(peer) {
+		return host
+	}
+
+	for _, header := range rr.headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		switch header {
+		case "X-Forwarded-For":
+			if addr, ok := rr.walkChain(strings.Split(strings.Join(values, ","), ",")); ok {
+				return addr.String()
+			}
+		case "Forwarded":
+			if addr, ok := rr.walkChain(forwardedFor(strings.Join(values, ","))); ok {
+				return addr.String()
+			}
+		default:
+			if addr, err := netip.ParseAddr(strings.TrimSpace(values[0])); err == nil {
+				return addr.Unmap().String()
+			}
+		}
+	}
+	return peer.Unmap().String()
+}
+
+// walkChain returns the rightmost address of a forwarding chain that is not a trusted proxy.
+// If every hop is trusted, the leftmost address is the client.
+func (rr *realIPResolver) walkChain(hops []string) (netip.Addr, bool) {
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !rr.isTrusted(client) {
+			break
+		}
+	}
+	return client, client.IsValid()
+}
+
+// forwardedFor extracts the for= addresses of an RFC 7239 Forwarded header, in order.
+func forwardedFor(value string) []string {
+	var hops []string
+	for _, element := range strings.Split(value, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			key, node, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || !strings.EqualFold(key, "for") {
+				continue
+			}
+			node = strings.Trim(node, `"`)
+			if host, _, err := net.SplitHostPort(node); err == nil {
+				node = host
+			}
+			hops = append(hops, strings.Trim(node, "[]"))
+		}
+	}
+	return hops
+}
+
+// middleware resolves the client IP once and stores it in the request context for GetRealIP.
+func (rr *realIPResolver) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), realIPContextKey{}, rr.resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRealIP returns the client's real IP address as resolved from trusted proxy headers.
+func GetRealIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(realIPContextKey{}).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
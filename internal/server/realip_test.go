@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ipinfo/internal/config"
+)
+
+func TestRealIPResolver(t *testing.T) {
+	cloudflareIPs := filepath.Join(t.TempDir(), "cloudflare-ips.txt")
+	if err := os.WriteFile(cloudflareIPs, []byte("# Cloudflare\n173.245.48.0/20\n\n2400:cb00::/32\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default().Proxy
+	cfg.TrustedProxies = append(cfg.TrustedProxies, "172.18.0.2")
+	cfg.CloudflareIPsFile = cloudflareIPs
+	cfg.RealIPHeaders = append(cfg.RealIPHeaders, "Forwarded")
+	resolver := newRealIPResolver(cfg)
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted peer", "203.0.113.7:1234", map[string]string{"X-Forwarded-For": "198.51.100.1", "CF-Connecting-IP": "198.51.100.2"}, "203.0.113.7"},
+		{"private peer is not trusted by default", "10.1.2.3:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "10.1.2.3"},
+		{"loopback proxy", "127.0.0.1:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"listed proxy", "172.18.0.2:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"cloudflare range", "173.245.48.10:443", map[string]string{"CF-Connecting-IP": "2001:db8::1"}, "2001:db8::1"},
+		{"header priority", "127.0.0.1:1234", map[string]string{"CF-Connecting-IP": "198.51.100.2", "X-Real-IP": "198.51.100.1"}, "198.51.100.2"},
+		{"invalid header falls through", "127.0.0.1:1234", map[string]string{"CF-Connecting-IP": "garbage", "X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"forwarded chain skips trusted hops", "127.0.0.1:1234", map[string]string{"X-Forwarded-For": "192.0.2.66, 198.51.100.1, 172.18.0.2"}, "198.51.100.1"},
+		{"spoofed left of the chain is ignored", "127.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"all hops trusted", "127.0.0.1:1234", map[string]string{"X-Forwarded-For": "172.18.0.2, 127.0.0.1"}, "172.18.0.2"},
+		{"unparsable hop stops the walk", "127.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, unknown, 127.0.0.1"}, "127.0.0.1"},
+		{"rfc 7239", "127.0.0.1:1234", map[string]string{"Forwarded": `for=192.0.2.60;proto=http, for="[2001:db8::2]:4711"`}, "2001:db8::2"},
+		{"no headers", "127.0.0.1:1234", nil, "127.0.0.1"},
+		{"mapped peer", "[::ffff:127.0.0.1]:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if got := resolver.resolve(req); got != tt.want {
+				t.Errorf("resolve() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRealIPResolverTrustsNone(t *testing.T) {
+	cfg := config.Default().Proxy
+	cfg.TrustedProxies = []string{"none"}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Real-IP", "198.51.100.1")
+	if got := newRealIPResolver(cfg).resolve(req); got != "127.0.0.1" {
+		t.Errorf("resolve() = %s, want the peer", got)
+	}
+}
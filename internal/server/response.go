@@ -1,23 +1,40 @@
 package server
 
 import (
-	"encoding/json"
+	"bytes"
 	"log/slog"
 	"net/http"
 )
 
-// sendJSONResponse sends a JSON response with the given data and status code.
-func sendJSONResponse(w http.ResponseWriter, data any, statusCode int) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+// sendResponse encodes data in the format negotiated with the client and sends it with the given status code.
+func sendResponse(w http.ResponseWriter, r *http.Request, data any, statusCode int) {
+	if pages := requestHTMLPages(r); pages != nil {
+		pages.render(w, r, data, statusCode)
+		return
+	}
+
+	enc, err := negotiateEncoder(r)
+	if err != nil {
+		enc, data, statusCode = jsonEncoder, errorResponse{Error: "Please provide a supported format."}, http.StatusBadRequest
+	}
+
+	var body bytes.Buffer
+	if err := enc.encode(&body, data, wantsPretty(r)); err != nil {
+		slog.Error("failed to encode response", "format", enc.name, "error", err)
+		enc, statusCode = jsonEncoder, http.StatusInternalServerError
+		body.Reset()
+		_ = jsonEncoder.encode(&body, errorResponse{Error: "Error encoding response."}, true)
+	}
+
+	w.Header().Set("Content-Type", enc.contentType)
+	w.Header().Add("Vary", "Accept")
 	w.WriteHeader(statusCode)
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(data); err != nil {
-		slog.Error("failed to encode json response", "error", err)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		slog.Error("failed to write response", "error", err)
 	}
 }
 
-// sendJSONError sends a JSON error response with the given message and status code.
-func sendJSONError(w http.ResponseWriter, errMsg string, statusCode int) {
-	sendJSONResponse(w, map[string]string{"error": errMsg}, statusCode)
+// sendError sends an error response with the given message and status code.
+func sendError(w http.ResponseWriter, r *http.Request, errMsg string, statusCode int) {
+	sendResponse(w, r, errorResponse{Error: errMsg}, statusCode)
 }
@@ -5,22 +5,46 @@ import (
 	"net/http"
 	"strings"
 
+	"ipinfo/internal/config"
 	"ipinfo/internal/db"
 	"ipinfo/utils"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // newRouter creates the main request router and applies middleware.
-func newRouter(geoIP *db.GeoIPManager) http.Handler {
+func newRouter(geoIP *db.GeoIPManager, jobs *jobManager, auth *authenticator, limits *rateLimiter, cfg *config.Config) http.Handler {
 	mux := http.NewServeMux()
+	maxBatchSize := cfg.Server.BatchMaxSize
 
 	// Register handlers
 	mux.Handle("/health", utils.HealthCheck())
 	mux.HandleFunc("/favicon.ico", faviconHandler)
+	mux.HandleFunc("GET /search", handleSearch)
+	mux.HandleFunc("GET /openapi.json", openAPIHandler(maxBatchSize, auth.enabled()))
+	mux.HandleFunc("GET /schemas/{name}", schemaHandler())
+	mux.HandleFunc("POST /batch", handleBatch(geoIP, maxBatchSize, limits))
+	mux.HandleFunc("POST /stream", handleStream(geoIP, limits))
+	mux.HandleFunc("POST /jobs", jobs.handleCreateJob)
+	mux.HandleFunc("GET /jobs/{id}", jobs.handleGetJob)
+	mux.HandleFunc("GET /jobs/{id}/result", jobs.handleJobResult)
+	mux.HandleFunc("DELETE /jobs/{id}", jobs.handleDeleteJob)
 	mux.HandleFunc("/", rootHandler(geoIP))
 
 	// Chain middleware
 	var handler http.Handler = mux
-	handler = loggingMiddleware(handler)
+	handler = auth.quotaMiddleware(handler)
+	handler = limits.middleware(handler)
+	handler = auth.middleware(handler)
+	handler = newHTMLPages(cfg.UI).middleware(handler)
+	handler = newCompressor(cfg.Server.CompressionMinSize).middleware(handler)
+	handler = newCORSPolicy(cfg.CORS).middleware(handler)
+	handler = metricsMiddleware(handler)
+	handler = newAccessLogger(cfg.Log).middleware(handler)
+	handler = newRealIPResolver(cfg.Proxy).middleware(handler)
+	handler = otelhttp.NewHandler(handler, "http", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + routeLabel(r)
+	}))
 
 	return handler
 }
@@ -28,12 +52,6 @@ func newRouter(geoIP *db.GeoIPManager) http.Handler {
 // rootHandler is the main routing logic that inspects the path.
 func rootHandler(geoIP *db.GeoIPManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Apply gzip compression where accepted
-		w = newGzipResponseWriter(w, r)
-		if gw, ok := w.(gzipResponseWriter); ok {
-			defer gw.Close()
-		}
-
 		path := strings.Trim(r.URL.Path, "/")
 		parts := strings.Split(path, "/")
 		firstPart := ""
@@ -42,7 +60,7 @@ func rootHandler(geoIP *db.GeoIPManager) http.HandlerFunc {
 		}
 
 		// Route to ASN handler
-		if strings.HasPrefix(strings.ToLower(firstPart), "as") {
+		if isASNQuery(firstPart) {
 			handleASNLookup(w, r, path, geoIP)
 			return
 		}
@@ -50,11 +68,16 @@ func rootHandler(geoIP *db.GeoIPManager) http.HandlerFunc {
 		// Route to Domain handler
 		isDomain := strings.Contains(firstPart, ".") && net.ParseIP(firstPart) == nil && firstPart != ""
 		if isDomain {
-			if len(parts) > 1 {
-				sendJSONError(w, "Invalid request for domain. Field lookups are not supported.", http.StatusBadRequest)
+			var field string
+			switch len(parts) {
+			case 1:
+			case 2:
+				field = parts[1]
+			default:
+				sendError(w, r, "Invalid request format.", http.StatusBadRequest)
 				return
 			}
-			handleDomainLookup(w, r, firstPart)
+			handleDomainLookup(w, r, firstPart, field)
 			return
 		}
 
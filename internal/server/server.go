@@ -3,56 +3,231 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"time"
 
+	"ipinfo/internal/config"
 	"ipinfo/internal/db"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
 // Server represents the HTTP server.
 type Server struct {
-	server *http.Server
+	server          *http.Server
+	metrics         *http.Server
+	redirect        *http.Server
+	tlsConfig       config.TLSConfig
+	grpcConfig      config.GRPCConfig
+	grpc            *grpc.Server
+	grpcHealth      *health.Server
+	dns             *dnsFrontend
+	whois           *whoisServer
+	geoIP           *db.GeoIPManager
+	shutdownTimeout time.Duration
+	jobs            *jobManager
+	auth            *authenticator
+	limits          *rateLimiter
+	access          *accessLogger
 }
 
 // NewServer creates a new HTTP server.
-func NewServer(geoIP *db.GeoIPManager) *Server {
+func NewServer(geoIP *db.GeoIPManager, cfg *config.Config) *Server {
+	limits := newRateLimiter(cfg.RateLimit)
+	jobs := newJobManager(geoIP, limits, cfg.Jobs)
+	auth := newAuthenticator(cfg.Auth)
+
 	// The router is now created in its own file.
-	handler := newRouter(geoIP)
+	handler := newRouter(geoIP, jobs, auth, limits, cfg)
+
+	// gRPC calls, DNS queries and WHOIS connections go to the access log with the same level and sampling as HTTP requests.
+	access := newAccessLogger(cfg.Log)
+
+	var dnsFrontend *dnsFrontend
+	if cfg.DNSServer.Address != "" {
+		dnsFrontend = newDNSFrontend(geoIP, cfg.DNSServer, access)
+	}
+
+	var whois *whoisServer
+	if cfg.WhoisServer.Address != "" {
+		whois = newWhoisServer(geoIP, cfg.WhoisServer, access)
+	}
+
+	var metricsServer *http.Server
+	if cfg.Server.MetricsAddress != "" {
+		metricsServer = newMetricsServer(cfg.Server)
+	}
 
 	return &Server{
 		server: &http.Server{
-			Addr:         ":3000",
+			Addr:         cfg.Server.Address,
 			Handler:      handler,
-			ReadTimeout:  10 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout:  60 * time.Second,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
 		},
+		metrics:         metricsServer,
+		tlsConfig:       cfg.TLS,
+		grpcConfig:      cfg.GRPC,
+		geoIP:           geoIP,
+		dns:             dnsFrontend,
+		whois:           whois,
+		shutdownTimeout: cfg.Server.ShutdownTimeout,
+		jobs:            jobs,
+		auth:            auth,
+		limits:          limits,
+		access:          access,
 	}
 }
 
 // Start starts the HTTP server and handles graceful shutdown.
 func (s *Server) Start(ctx context.Context) error {
+	if err := s.limits.start(ctx); err != nil {
+		return err
+	}
+	if err := s.auth.start(ctx); err != nil {
+		return err
+	}
+	if err := s.jobs.start(ctx); err != nil {
+		return err
+	}
+	if err := s.setupTLS(ctx); err != nil {
+		return err
+	}
+
 	go func() {
-		slog.Info("server listening", "address", s.server.Addr)
-		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if s.server.TLSConfig != nil {
+			slog.Info("server listening", "address", s.server.Addr, "tls", true)
+			err = s.server.ListenAndServeTLS("", "")
+		} else {
+			slog.Info("server listening", "address", s.server.Addr)
+			err = s.server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("server error", "error", err)
 			os.Exit(1)
 		}
 	}()
 
+	if s.metrics != nil {
+		go func() {
+			slog.Info("metrics server listening", "address", s.metrics.Addr)
+			if err := s.metrics.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("metrics server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	if s.grpcConfig.Address != "" {
+		listener, err := net.Listen("tcp", s.grpcConfig.Address)
+		if err != nil {
+			return fmt.Errorf("listening for grpc: %w", err)
+		}
+		s.grpc, s.grpcHealth = newGRPCServer(s.geoIP, s.auth, s.limits, s.access, s.grpcConfig, s.server.TLSConfig)
+		go func() {
+			slog.Info("grpc server listening", "address", s.grpcConfig.Address, "tls", s.server.TLSConfig != nil, "reflection", s.grpcConfig.Reflection)
+			if err := s.grpc.Serve(listener); err != nil {
+				slog.Error("grpc server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	if s.dns != nil {
+		if err := s.dns.start(); err != nil {
+			return err
+		}
+	}
+
+	if s.whois != nil {
+		if err := s.whois.start(); err != nil {
+			return err
+		}
+	}
+
+	if s.redirect != nil {
+		go func() {
+			slog.Info("redirecting http to https", "address", s.redirect.Addr)
+			if err := s.redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("redirect server error", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	<-ctx.Done()
 
 	slog.Info("shutdown signal received")
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("redirect server shutdown failed", "error", err)
+		}
+	}
+	if s.grpc != nil {
+		s.stopGRPC(shutdownCtx)
+	}
+	if s.dns != nil {
+		s.dns.shutdown(shutdownCtx)
+	}
+	if s.whois != nil {
+		s.whois.shutdown(shutdownCtx)
+	}
 	if err := s.server.Shutdown(shutdownCtx); err != nil {
 		slog.Error("shutdown failed", "error", err)
 		return err
 	}
+	if s.metrics != nil {
+		if err := s.metrics.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("metrics server shutdown failed", "error", err)
+		}
+	}
+	s.jobs.wait()
+	s.auth.wait()
 
 	slog.Info("shutdown complete")
 	return nil
 }
+
+// newMetricsServer serves the Prometheus metrics on their own address, apart from the API and its
+// authentication, so that they can be kept off the public network.
+func newMetricsServer(cfg config.ServerConfig) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return &http.Server{
+		Addr:         cfg.MetricsAddress,
+		Handler:      mux,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+}
+
+// stopGRPC reports the service as not serving, then waits for running calls to finish until ctx is done,
+// after which they are cancelled.
+func (s *Server) stopGRPC(ctx context.Context) {
+	s.grpcHealth.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("grpc server shutdown timed out, cancelling running calls")
+		s.grpc.Stop()
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/db"
+)
+
+// streamConcurrency bounds the number of lookups in flight for a single stream.
+const streamConcurrency = 16
+
+// streamResult represents a single line of a streaming response.
+type streamResult struct {
+	Query string `json:"query"`
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+	// retryAfter is set on the result of the query the API key's quota ran out at.
+	retryAfter time.Duration
+}
+
+// handleStream handles streaming lookups of newline-delimited IPs, writing one NDJSON line per input line.
+// Results keep the input order, and only a fixed number of lookups are buffered at any time.
+func handleStream(geoIP *db.GeoIPManager, limits *rateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.EnableFullDuplex(); err != nil {
+			slog.Debug("full duplex is not supported for stream", "error", err)
+		}
+		_ = rc.SetReadDeadline(time.Time{})
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		// Read before writing anything, so a client waiting for 100 Continue sends the body
+		// instead of the server closing it once the response has started.
+		body := bufio.NewReader(r.Body)
+		_, _ = body.Peek(1)
+
+		lang := requestLanguage(r, geoIP)
+		key := requestAPIKey(r)
+		identity := rateIdentity(r)
+		pending := make(chan chan streamResult, streamConcurrency)
+
+		go func() {
+			defer close(pending)
+
+			scanner := bufio.NewScanner(body)
+			for scanner.Scan() {
+				query := strings.TrimSpace(scanner.Text())
+				if query == "" {
+					continue
+				}
+
+				result := make(chan streamResult, 1)
+				select {
+				case pending <- result:
+				case <-r.Context().Done():
+					return
+				}
+
+				// Queries are charged in input order, so the stream ends at the first one over quota. Queries
+				// outside the key's tier and lines that are not IPs are answered without a lookup and cost nothing.
+				if err := key.permits(query); err != nil {
+					result <- streamResult{Query: query, Error: err.Error()}
+					continue
+				}
+				if net.ParseIP(query) != nil {
+					if retryAfter, ok := key.charge(1); !ok {
+						result <- streamResult{Query: query, Error: errQuotaExceeded.Error(), retryAfter: retryAfter}
+						return
+					}
+				}
+
+				go func(q string) {
+					if err := limits.waitQuery(r.Context(), identity, q); err != nil {
+						result <- streamResult{Query: q, Error: err.Error()}
+						return
+					}
+					result <- lookupStreamItem(r.Context(), geoIP, q, lang)
+				}(query)
+			}
+
+			if err := scanner.Err(); err != nil {
+				result := make(chan streamResult, 1)
+				result <- streamResult{Error: "error reading request body: " + err.Error()}
+				select {
+				case pending <- result:
+				case <-r.Context().Done():
+				}
+			}
+		}()
+
+		// The status is sent with the first result, so a key without quota left gets a 429.
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		started := false
+
+		encoder := json.NewEncoder(w)
+		for result := range pending {
+			res := <-result
+			if !started && res.retryAfter > 0 {
+				sendQuotaExceeded(w, r, res.retryAfter)
+				return
+			}
+			started = true
+			if err := encoder.Encode(res); err != nil {
+				slog.Warn("failed to write stream result", "error", err)
+				return
+			}
+			if len(pending) == 0 {
+				if err := rc.Flush(); err != nil {
+					slog.Warn("failed to flush stream", "error", err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// lookupStreamItem looks up a single IP from a stream without caching the result.
+func lookupStreamItem(ctx context.Context, geoIP *db.GeoIPManager, query, lang string) streamResult {
+	ip := net.ParseIP(query)
+	if ip == nil {
+		return streamResult{Query: query, Error: "invalid ip address"}
+	}
+
+	if common.IsBogon(ip) {
+		return streamResult{Query: query, Data: bogonDataStruct{IP: ip.String(), Bogon: true}}
+	}
+
+	data := common.LookupIPDataUncached(ctx, geoIP, ip, lang)
+	if data == nil {
+		return streamResult{Query: query, Error: "could not retrieve data for the specified ip"}
+	}
+	return streamResult{Query: query, Data: data}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ipinfo/internal/config"
+)
+
+// streamLines decodes the NDJSON lines of a stream response.
+func streamLines(t *testing.T, body string) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestStream(t *testing.T) {
+	router := newTestRouter(t, newTestAuthenticator(t, false), config.RateLimitConfig{})
+
+	rec := serveTest(router, http.MethodPost, "/stream", "", "8.8.8.8\n\n  1.1.1.1 \n10.0.0.1\nexample.com\n2001:4860:4860::8888\n")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	lines := streamLines(t, rec.Body.String())
+
+	// Blank lines are skipped and the results keep the input order.
+	want := []string{"8.8.8.8", "1.1.1.1", "10.0.0.1", "example.com", "2001:4860:4860::8888"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %s", len(lines), len(want), rec.Body.String())
+	}
+	for i, query := range want {
+		if lines[i]["query"] != query {
+			t.Errorf("line %d is for %v, want %s", i, lines[i]["query"], query)
+		}
+	}
+	if data, _ := lines[0]["data"].(map[string]any); data["asn"] == nil || data["country"] != "US" {
+		t.Errorf("8.8.8.8 data %v", lines[0]["data"])
+	}
+	if data, _ := lines[2]["data"].(map[string]any); data["bogon"] != true {
+		t.Errorf("10.0.0.1 data %v, want bogon", lines[2]["data"])
+	}
+	if lines[3]["error"] != "invalid ip address" {
+		t.Errorf("example.com line %v, want invalid ip address", lines[3])
+	}
+}
+
+func TestStreamQuota(t *testing.T) {
+	auth := newTestAuthenticator(t, true)
+	router := newTestRouter(t, auth, config.RateLimitConfig{})
+
+	// The ASN is outside the tier and the domain is not an IP, so neither is charged; the quota of 3
+	// covers the first three IPs and the stream ends at the fourth.
+	rec := serveTest(router, http.MethodPost, "/stream", "batch-secret", "10.0.0.1\nAS13335\n192.168.0.1\nexample.com\n10.0.0.2\n10.0.0.3\n10.0.0.4\n")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	lines := streamLines(t, rec.Body.String())
+	if len(lines) != 6 {
+		t.Fatalf("got %d lines, want 6: %s", len(lines), rec.Body.String())
+	}
+	if !strings.HasPrefix(lines[1]["error"].(string), "this api key cannot look up asn") {
+		t.Errorf("ASN line %v, want tier error", lines[1])
+	}
+	if lines[5]["query"] != "10.0.0.3" || lines[5]["error"] != errQuotaExceeded.Error() {
+		t.Errorf("last line %v, want quota error for 10.0.0.3", lines[5])
+	}
+	if got := dailyUsage(auth, "batch"); got != 3 {
+		t.Errorf("batch key charged %d lookups, want 3", got)
+	}
+
+	// Without quota left, the stream is refused before it starts.
+	rec = serveTest(router, http.MethodPost, "/stream", "batch-secret", "10.0.0.5\n")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("stream without quota: status %d, want 429", rec.Code)
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ipinfo/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certReloadInterval is how often the certificate files are checked for changes.
+const certReloadInterval = time.Minute
+
+// setupTLS configures HTTPS from certificate files or ACME, and the plain HTTP listener that redirects
+// to it. It does nothing unless TLS is enabled.
+func (s *Server) setupTLS(ctx context.Context) error {
+	cfg := s.tlsConfig
+	redirect := redirectToHTTPS(s.server.Addr)
+
+	switch {
+	case cfg.CertFile != "":
+		certs, err := newCertReloader(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("loading tls certificate: %w", err)
+		}
+		certs.start(ctx)
+		s.server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.getCertificate,
+		}
+	case len(cfg.ACMEDomains) > 0:
+		manager, err := newACMEManager(cfg)
+		if err != nil {
+			return err
+		}
+		// The manager's TLS config answers TLS-ALPN-01 challenges; its HTTP handler answers HTTP-01.
+		s.server.TLSConfig = manager.TLSConfig()
+		s.server.TLSConfig.MinVersion = tls.VersionTLS12
+		redirect = manager.HTTPHandler(redirect)
+		slog.Info("obtaining certificates through acme", "domains", cfg.ACMEDomains, "directory", cfg.ACMEDirectoryURL)
+	default:
+		return nil
+	}
+
+	if cfg.RedirectAddress != "" {
+		s.redirect = &http.Server{
+			Addr:         cfg.RedirectAddress,
+			Handler:      redirect,
+			ReadTimeout:  s.server.ReadTimeout,
+			WriteTimeout: s.server.WriteTimeout,
+			IdleTimeout:  s.server.IdleTimeout,
+		}
+	}
+	return nil
+}
+
+// newACMEManager creates a certificate manager for the configured domains, keeping accounts and
+// certificates in the cache directory.
+func newACMEManager(cfg config.TLSConfig) (*autocert.Manager, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ACMECAFile != "" {
+		pem, err := os.ReadFile(cfg.ACMECAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading acme ca file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("acme ca file %s contains no certificates", cfg.ACMECAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+
+	// HTTP-01 challenges carry the port in the Host header when they are not sent to port 80,
+	// as with a local test server.
+	allowed := autocert.HostWhitelist(cfg.ACMEDomains...)
+	hostPolicy := func(ctx context.Context, host string) error {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return allowed(ctx, host)
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.ACMECacheDir),
+		HostPolicy: hostPolicy,
+		Email:      cfg.ACMEEmail,
+		Client: &acme.Client{
+			DirectoryURL: cfg.ACMEDirectoryURL,
+			HTTPClient:   &http.Client{Transport: transport},
+		},
+	}, nil
+}
+
+// redirectToHTTPS redirects requests to the same host and path on the port of the HTTPS address.
+func redirectToHTTPS(httpsAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddress)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// certReloader serves a certificate from files and reloads it when either file changes.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// newCertReloader loads the certificate, failing if the files cannot be read.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// start checks the files for changes periodically until ctx is done. A certificate that fails to load
+// is logged and the current one is kept.
+func (r *certReloader) start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(certReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.reload(); err != nil {
+					slog.Error("failed to reload tls certificate, keeping the current one", "cert", r.certFile, "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// reload loads the certificate if either file was modified since it was last loaded.
+func (r *certReloader) reload() error {
+	var modTime time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	r.mu.RLock()
+	unchanged := modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	if cert.Leaf == nil {
+		return errors.New("certificate file contains no certificate")
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+
+	slog.Info("loaded tls certificate", "cert", r.certFile, "subject", cert.Leaf.Subject.String(), "expires", cert.Leaf.NotAfter)
+	return nil
+}
+
+// getCertificate returns the current certificate for every handshake.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
@@ -0,0 +1,487 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+
+	"golang.org/x/crypto/acme"
+)
+
+// fakeACME is a minimal ACME CA that validates HTTP-01 challenges through a handler and issues
+// certificates from its own root.
+type fakeACME struct {
+	t      *testing.T
+	server *httptest.Server
+	root   *x509.Certificate
+	key    *ecdsa.PrivateKey
+
+	mu         sync.Mutex
+	accountKey *ecdsa.PublicKey
+	domain     string
+	token      string
+	validated  bool
+	issued     []byte
+	// challenges answers the HTTP-01 challenge requests of the CA.
+	challenges http.Handler
+}
+
+// newFakeACME starts a fake CA on a TLS test server.
+func newFakeACME(t *testing.T) *fakeACME {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake acme root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &fakeACME{t: t, root: root, key: key, token: "challenge-token"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /directory", ca.directory)
+	mux.HandleFunc("HEAD /nonce", func(w http.ResponseWriter, r *http.Request) { ca.nonce(w) })
+	mux.HandleFunc("POST /account", ca.newAccount)
+	mux.HandleFunc("POST /order", ca.newOrder)
+	mux.HandleFunc("POST /order/1", ca.order)
+	mux.HandleFunc("POST /authz/1", ca.authz)
+	mux.HandleFunc("POST /authz/1/deactivate", ca.authz)
+	mux.HandleFunc("POST /challenge/1", ca.challenge)
+	mux.HandleFunc("POST /finalize/1", ca.finalize)
+	mux.HandleFunc("POST /cert/1", ca.cert)
+	ca.server = httptest.NewTLSServer(mux)
+	t.Cleanup(ca.server.Close)
+	return ca
+}
+
+// caFile writes the certificate of the test server to a PEM file for the acme_ca_file setting.
+func (ca *fakeACME) caFile() string {
+	path := filepath.Join(ca.t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.server.Certificate().Raw})
+	if err := os.WriteFile(path, block, 0o600); err != nil {
+		ca.t.Fatal(err)
+	}
+	return path
+}
+
+func (ca *fakeACME) nonce(w http.ResponseWriter) {
+	w.Header().Set("Replay-Nonce", base64.RawURLEncoding.EncodeToString(big.NewInt(time.Now().UnixNano()).Bytes()))
+}
+
+func (ca *fakeACME) reply(w http.ResponseWriter, status int, body any) {
+	ca.nonce(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// request decodes the payload of a JWS request and returns its protected header.
+func (ca *fakeACME) request(r *http.Request, payload any) (header struct {
+	JWK json.RawMessage `json:"jwk"`
+	URL string          `json:"url"`
+}) {
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		ca.t.Errorf("decoding jws: %v", err)
+		return header
+	}
+	protected, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if err := json.Unmarshal(protected, &header); err != nil {
+		ca.t.Errorf("decoding jws header: %v", err)
+	}
+	if raw, _ := base64.RawURLEncoding.DecodeString(jws.Payload); payload != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, payload); err != nil {
+			ca.t.Errorf("decoding jws payload: %v", err)
+		}
+	}
+	return header
+}
+
+func (ca *fakeACME) url(path string) string {
+	return ca.server.URL + path
+}
+
+func (ca *fakeACME) directory(w http.ResponseWriter, _ *http.Request) {
+	ca.reply(w, http.StatusOK, map[string]any{
+		"newNonce":   ca.url("/nonce"),
+		"newAccount": ca.url("/account"),
+		"newOrder":   ca.url("/order"),
+		"revokeCert": ca.url("/revoke"),
+		"keyChange":  ca.url("/key-change"),
+	})
+}
+
+func (ca *fakeACME) newAccount(w http.ResponseWriter, r *http.Request) {
+	header := ca.request(r, nil)
+	var jwk struct {
+		Crv, X, Y string
+	}
+	if err := json.Unmarshal(header.JWK, &jwk); err != nil || jwk.Crv != "P-256" {
+		ca.t.Errorf("unexpected account key %s", header.JWK)
+		http.Error(w, "bad key", http.StatusBadRequest)
+		return
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, _ := base64.RawURLEncoding.DecodeString(jwk.Y)
+
+	ca.mu.Lock()
+	ca.accountKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	ca.mu.Unlock()
+
+	w.Header().Set("Location", ca.url("/account/1"))
+	ca.reply(w, http.StatusCreated, map[string]any{"status": "valid"})
+}
+
+// orderBody is the order in its current state.
+func (ca *fakeACME) orderBody() map[string]any {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	status := "pending"
+	switch {
+	case ca.issued != nil:
+		status = "valid"
+	case ca.validated:
+		status = "ready"
+	}
+	body := map[string]any{
+		"status":         status,
+		"identifiers":    []map[string]string{{"type": "dns", "value": ca.domain}},
+		"authorizations": []string{ca.url("/authz/1")},
+		"finalize":       ca.url("/finalize/1"),
+	}
+	if ca.issued != nil {
+		body["certificate"] = ca.url("/cert/1")
+	}
+	return body
+}
+
+func (ca *fakeACME) newOrder(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Identifiers []struct{ Value string }
+	}
+	ca.request(r, &payload)
+	if len(payload.Identifiers) != 1 {
+		http.Error(w, "one identifier expected", http.StatusBadRequest)
+		return
+	}
+	ca.mu.Lock()
+	ca.domain = payload.Identifiers[0].Value
+	ca.mu.Unlock()
+
+	w.Header().Set("Location", ca.url("/order/1"))
+	ca.reply(w, http.StatusCreated, ca.orderBody())
+}
+
+func (ca *fakeACME) order(w http.ResponseWriter, r *http.Request) {
+	ca.request(r, nil)
+	ca.reply(w, http.StatusOK, ca.orderBody())
+}
+
+func (ca *fakeACME) authz(w http.ResponseWriter, r *http.Request) {
+	ca.request(r, nil)
+	ca.mu.Lock()
+	status := "pending"
+	if ca.validated {
+		status = "valid"
+	}
+	body := map[string]any{
+		"status":     status,
+		"identifier": map[string]string{"type": "dns", "value": ca.domain},
+		"challenges": []map[string]string{{
+			"type":   "http-01",
+			"url":    ca.url("/challenge/1"),
+			"token":  ca.token,
+			"status": status,
+		}},
+	}
+	ca.mu.Unlock()
+	ca.reply(w, http.StatusOK, body)
+}
+
+// challenge validates the HTTP-01 challenge by fetching the key authorization from the challenge
+// handler, as the CA would from port 80 of the domain.
+func (ca *fakeACME) challenge(w http.ResponseWriter, r *http.Request) {
+	ca.request(r, nil)
+	ca.mu.Lock()
+	domain, token, accountKey, handler := ca.domain, ca.token, ca.accountKey, ca.challenges
+	ca.mu.Unlock()
+
+	thumbprint, err := acme.JWKThumbprint(accountKey)
+	if err != nil {
+		ca.t.Errorf("account key thumbprint: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://"+domain+":5002/.well-known/acme-challenge/"+token, nil)
+	handler.ServeHTTP(rec, req)
+
+	status := "invalid"
+	if rec.Code == http.StatusOK && rec.Body.String() == token+"."+thumbprint {
+		status = "valid"
+		ca.mu.Lock()
+		ca.validated = true
+		ca.mu.Unlock()
+	} else {
+		ca.t.Errorf("challenge response %d %q, want key authorization", rec.Code, rec.Body.String())
+	}
+	ca.reply(w, http.StatusOK, map[string]string{"type": "http-01", "url": ca.url("/challenge/1"), "token": token, "status": status})
+}
+
+// finalize issues the certificate for the CSR and answers with the order, which is then polled.
+func (ca *fakeACME) finalize(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	ca.request(r, &payload)
+	raw, _ := base64.RawURLEncoding.DecodeString(payload.CSR)
+	csr, err := x509.ParseCertificateRequest(raw)
+	if err != nil {
+		http.Error(w, "bad csr", http.StatusBadRequest)
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.root, csr.PublicKey, ca.key)
+	if err != nil {
+		ca.t.Errorf("issuing certificate: %v", err)
+		http.Error(w, "issuing failed", http.StatusInternalServerError)
+		return
+	}
+
+	ca.mu.Lock()
+	ca.issued = der
+	ca.mu.Unlock()
+	body := ca.orderBody()
+	body["status"] = "processing"
+	delete(body, "certificate")
+	w.Header().Set("Location", ca.url("/order/1"))
+	ca.reply(w, http.StatusOK, body)
+}
+
+func (ca *fakeACME) cert(w http.ResponseWriter, r *http.Request) {
+	ca.request(r, nil)
+	ca.mu.Lock()
+	issued := ca.issued
+	ca.mu.Unlock()
+	ca.nonce(w)
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: issued})
+	_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.root.Raw})
+}
+
+// TestACMECertificate obtains a certificate from a fake CA through the configured directory URL,
+// answering its HTTP-01 challenge on the redirect listener, and serves it in a TLS handshake.
+func TestACMECertificate(t *testing.T) {
+	ca := newFakeACME(t)
+	cacheDir := t.TempDir()
+	s := &Server{
+		server: &http.Server{Addr: "127.0.0.1:8443"},
+		tlsConfig: config.TLSConfig{
+			ACMEDomains:      []string{"example.test"},
+			ACMEDirectoryURL: ca.url("/directory"),
+			ACMECacheDir:     cacheDir,
+			ACMECAFile:       ca.caFile(),
+			RedirectAddress:  "127.0.0.1:8080",
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.setupTLS(ctx); err != nil {
+		t.Fatalf("setupTLS: %v", err)
+	}
+	if s.redirect == nil || s.server.TLSConfig == nil {
+		t.Fatal("setupTLS configured no redirect listener or TLS")
+	}
+	ca.mu.Lock()
+	ca.challenges = s.redirect.Handler
+	ca.mu.Unlock()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", s.server.TLSConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).HandshakeContext(ctx)
+			conn.Close()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.root)
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: "example.test", RootCAs: roots}}
+	conn, err := dialer.DialContext(ctx, "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	leaf := conn.(*tls.Conn).ConnectionState().PeerCertificates[0]
+	conn.Close()
+	if err := leaf.VerifyHostname("example.test"); err != nil {
+		t.Errorf("served certificate: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "example.test")); err != nil {
+		t.Errorf("certificate not cached: %v", err)
+	}
+
+	// Names outside the configured domains are refused before the CA is asked.
+	_, err = s.server.TLSConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.test"})
+	if err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Errorf("certificate for other.test: %v, want host policy error", err)
+	}
+
+	// Other requests on the redirect listener go to HTTPS.
+	rec := httptest.NewRecorder()
+	s.redirect.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.test:8080/8.8.8.8?lang=de", nil))
+	if want := "https://example.test:8443/8.8.8.8?lang=de"; rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != want {
+		t.Errorf("redirect: %d %q, want 308 %q", rec.Code, rec.Header().Get("Location"), want)
+	}
+}
+
+// TestRedirectToHTTPS checks the redirect target for default and custom HTTPS ports and IPv6 hosts.
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		address, host, want string
+	}{
+		{":443", "example.com", "https://example.com/path?q=1"},
+		{":443", "example.com:80", "https://example.com/path?q=1"},
+		{":8443", "example.com:8080", "https://example.com:8443/path?q=1"},
+		{":443", "[2001:db8::1]:80", "https://[2001:db8::1]/path?q=1"},
+		{":8443", "[2001:db8::1]", "https://[2001:db8::1]:8443/path?q=1"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.address, tt.host), func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/path?q=1", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			redirectToHTTPS(tt.address).ServeHTTP(rec, req)
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCertReloader checks that a certificate is reloaded once its files change.
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeSelfSigned := func(name string, modTime time.Time) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for path, block := range map[string]*pem.Block{
+			certFile: {Type: "CERTIFICATE", Bytes: der},
+			keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+		} {
+			if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	served := func(r *certReloader) string {
+		cert, err := r.getCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Leaf.Subject.CommonName
+	}
+
+	start := time.Now().Add(-time.Minute)
+	writeSelfSigned("first.test", start)
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader: %v", err)
+	}
+	if got := served(r); got != "first.test" {
+		t.Fatalf("served %s, want first.test", got)
+	}
+
+	writeSelfSigned("second.test", start.Add(time.Second))
+	if err := r.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := served(r); got != "second.test" {
+		t.Errorf("served %s after the files changed, want second.test", got)
+	}
+
+	// A broken certificate is reported and the current one is kept.
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(certFile, start.Add(2*time.Second), start.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(); err == nil {
+		t.Error("reload of a broken certificate succeeded")
+	}
+	if got := served(r); got != "second.test" {
+		t.Errorf("served %s after a failed reload, want second.test", got)
+	}
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/config"
+	"ipinfo/internal/tracing"
+
+	"github.com/miekg/dns"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// startTestResolver serves an A record for example.com and empty answers for everything else, and returns
+// its address.
+func startTestResolver(t *testing.T) string {
+	t.Helper()
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: packetConn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		if q := req.Question[0]; q.Qtype == dns.TypeA && q.Name == "example.com." {
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+				A:   net.IPv4(192, 0, 2, 1),
+			})
+		}
+		_ = w.WriteMsg(resp)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return packetConn.LocalAddr().String()
+}
+
+// startTestWhoisRegistry answers every WHOIS query with a short domain record and returns its address.
+func startTestWhoisRegistry(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			_, _ = bufio.NewReader(conn).ReadString('\n')
+			_, _ = conn.Write([]byte("Domain Name: EXAMPLE.COM\r\nRegistrar: Test Registrar\r\n"))
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// TestTracing checks the spans of HTTP requests and that the database, DNS and WHOIS lookups they make
+// are recorded below them.
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	provider := tracing.Install(exporter, config.TracingConfig{SampleRate: 1, ServiceName: "ipinfo-test"})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+
+	cfg := config.Default()
+	cfg.DNS.Resolver = startTestResolver(t)
+	cfg.Whois.Server = startTestWhoisRegistry(t)
+	common.Configure(cfg)
+	t.Cleanup(func() { common.Configure(config.Default()) })
+
+	geoIP := newTestGeoIP(t)
+	limits := newRateLimiter(cfg.RateLimit)
+	router := newRouter(geoIP, newJobManager(geoIP, limits, cfg.Jobs), newTestAuthenticator(t, false), limits, cfg)
+
+	// serve makes a request and returns the spans it recorded, checking that they form one trace below
+	// its HTTP span.
+	serve := func(t *testing.T, path, wantSpan string) (tracetest.SpanStub, map[string][]tracetest.SpanStub) {
+		t.Helper()
+		exporter.Reset()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", path, rec.Code, rec.Body.String())
+		}
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		spans := make(map[string][]tracetest.SpanStub)
+		for _, span := range exporter.GetSpans() {
+			spans[span.Name] = append(spans[span.Name], span)
+		}
+		if len(spans[wantSpan]) != 1 {
+			t.Fatalf("GET %s: spans %v, want one %q span", path, spanNames(spans), wantSpan)
+		}
+		root := spans[wantSpan][0]
+		if root.Parent.IsValid() {
+			t.Errorf("HTTP span has parent %s", root.Parent.SpanID())
+		}
+		for _, group := range spans {
+			for _, span := range group {
+				if span.SpanContext.TraceID() != root.SpanContext.TraceID() {
+					t.Errorf("span %q is in trace %s, want %s", span.Name, span.SpanContext.TraceID(), root.SpanContext.TraceID())
+				}
+			}
+		}
+		return root, spans
+	}
+
+	t.Run("database", func(t *testing.T) {
+		root, spans := serve(t, "/8.8.8.0/24", "GET network")
+		lookups := spans["mmdb.lookup"]
+		if len(lookups) != 2 {
+			t.Fatalf("%d mmdb.lookup spans, want one per database", len(lookups))
+		}
+		databases := make(map[string]bool)
+		for _, span := range lookups {
+			assertChild(t, span, root)
+			databases[spanAttr(span, "mmdb.database").AsString()] = true
+			if !spanAttr(span, "mmdb.found").AsBool() {
+				t.Errorf("mmdb.lookup in %s not found", spanAttr(span, "mmdb.database").AsString())
+			}
+		}
+		if len(databases) != 2 {
+			t.Errorf("mmdb.lookup databases %v, want city and asn", databases)
+		}
+	})
+
+	t.Run("domain", func(t *testing.T) {
+		root, spans := serve(t, "/example.com", "GET domain")
+
+		queries := spans["dns.query"]
+		if len(queries) != 8 {
+			t.Fatalf("%d dns.query spans, want one per record type", len(queries))
+		}
+		for _, span := range queries {
+			assertChild(t, span, root)
+			if spanAttr(span, "server.address").AsString() != cfg.DNS.Resolver {
+				t.Errorf("dns.query sent to %q, want %q", spanAttr(span, "server.address").AsString(), cfg.DNS.Resolver)
+			}
+			answers := int64(0)
+			if spanAttr(span, "dns.question.type").AsString() == "A" {
+				answers = 1
+			}
+			if got := spanAttr(span, "dns.answers").AsInt64(); got != answers {
+				t.Errorf("dns.query %s has %d answers, want %d", spanAttr(span, "dns.question.type").AsString(), got, answers)
+			}
+		}
+
+		if len(spans["whois"]) != 1 {
+			t.Fatalf("%d whois spans, want 1", len(spans["whois"]))
+		}
+		whois := spans["whois"][0]
+		assertChild(t, whois, root)
+		if spanAttr(whois, "whois.domain").AsString() != "example.com" {
+			t.Errorf("whois span for %q, want example.com", spanAttr(whois, "whois.domain").AsString())
+		}
+		attempts := spans["whois.query"]
+		if len(attempts) != 1 {
+			t.Fatalf("%d whois.query spans, want only the primary attempt", len(attempts))
+		}
+		assertChild(t, attempts[0], whois)
+		if spanAttr(attempts[0], "whois.attempt").AsString() != "primary" {
+			t.Errorf("whois.query attempt %q, want primary", spanAttr(attempts[0], "whois.attempt").AsString())
+		}
+	})
+}
+
+// assertChild checks that span is a direct child of parent.
+func assertChild(t *testing.T, span, parent tracetest.SpanStub) {
+	t.Helper()
+	if span.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Errorf("span %q has parent %s, want %q (%s)", span.Name, span.Parent.SpanID(), parent.Name, parent.SpanContext.SpanID())
+	}
+}
+
+// spanAttr returns the value of an attribute of span, or an empty value if it is not set.
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// spanNames returns the number of spans of each name.
+func spanNames(spans map[string][]tracetest.SpanStub) map[string]int {
+	names := make(map[string]int, len(spans))
+	for name, group := range spans {
+		names[name] = len(group)
+	}
+	return names
+}
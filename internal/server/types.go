@@ -1,5 +1,29 @@
 package server
 
+import (
+	"reflect"
+
+	"ipinfo/internal/common"
+)
+
+// Response types used to validate requested field paths and to derive the API schemas.
+var (
+	ipDataType      = reflect.TypeOf(common.DataStruct{})
+	networkDataType = reflect.TypeOf(common.NetworkDataResponse{})
+	asnDataType     = reflect.TypeOf(common.ASNDataResponse{})
+	domainDataType  = reflect.TypeOf(common.DomainDataResponse{})
+	whoisInfoType   = reflect.TypeOf(common.WhoisInfo{})
+	bogonDataType   = reflect.TypeOf(bogonDataStruct{})
+	errorType       = reflect.TypeOf(errorResponse{})
+	streamType      = reflect.TypeOf(streamResult{})
+	jobType         = reflect.TypeOf(job{})
+)
+
+// errorResponse represents the response structure for errors, including failed batch items.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
 // bogonDataStruct represents the response structure for bogon IP queries.
 type bogonDataStruct struct {
 	IP    string `json:"ip"`
@@ -1,43 +1,98 @@
 package server
 
 import (
-	"net"
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"ipinfo/internal/common"
+	"ipinfo/internal/db"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/language"
 )
 
-// fieldMap maps request fields to their corresponding data struct fields.
-var fieldMap = map[string]func(*common.DataStruct) *string{
-	"ip":       func(d *common.DataStruct) *string { return d.IP },
-	"hostname": func(d *common.DataStruct) *string { return d.Hostname },
-	"org":      func(d *common.DataStruct) *string { return d.Org },
-	"city":     func(d *common.DataStruct) *string { return d.City },
-	"region":   func(d *common.DataStruct) *string { return d.Region },
-	"country":  func(d *common.DataStruct) *string { return d.Country },
-	"timezone": func(d *common.DataStruct) *string { return d.Timezone },
-	"loc":      func(d *common.DataStruct) *string { return d.Loc },
+// parseASN extracts a positive ASN from a path such as "AS13335" or "asn13335".
+func parseASN(path string) (uint, error) {
+	upperPath := strings.ToUpper(path)
+	cleanPath := path
+
+	if strings.HasPrefix(upperPath, "ASN") {
+		cleanPath = path[3:]
+	} else if strings.HasPrefix(upperPath, "AS") {
+		cleanPath = path[2:]
+	}
+
+	asnStr := strings.Trim(cleanPath, "/ ")
+
+	asn, err := strconv.ParseUint(asnStr, 10, 32)
+	if err != nil || asn == 0 {
+		return 0, errors.New("invalid asn: must be a positive number")
+	}
+	return uint(asn), nil
 }
 
-// getField retrieves the value of a specific field from the data struct.
-func getField(data *common.DataStruct, field string) *string {
-	if f, ok := fieldMap[field]; ok {
-		return f(data)
+// isASNQuery reports whether query names an ASN, such as "AS13335" or "asn13335", rather than an IP
+// address, a field or a domain that happens to start with "as".
+func isASNQuery(query string) bool {
+	rest, ok := strings.CutPrefix(strings.ToLower(query), "as")
+	if !ok {
+		return false
 	}
-	return nil
+	rest = strings.TrimPrefix(rest, "n")
+	return rest != "" && strings.Trim(rest, "0123456789") == ""
 }
 
-// GetRealIP extracts the client's real IP address from request headers.
-func GetRealIP(r *http.Request) string {
-	for _, header := range []string{"CF-Connecting-IP", "X-Real-IP", "X-Forwarded-For"} {
-		if ip := r.Header.Get(header); ip != "" {
-			return strings.TrimSpace(strings.Split(ip, ",")[0])
+// normalizeDomain converts a domain to its punycode form and validates its length.
+func normalizeDomain(domain string) (string, error) {
+	punycodeDomain, err := idna.ToASCII(domain)
+	if err != nil {
+		return "", errors.New("invalid domain name")
+	}
+
+	if len(punycodeDomain) > 253 {
+		return "", errors.New("invalid domain name")
+	}
+	return punycodeDomain, nil
+}
+
+// requestLanguage picks the best place-name language for the request from ?lang= or the
+// Accept-Language header, limited to the languages in the city database.
+func requestLanguage(r *http.Request, geoIP *db.GeoIPManager) string {
+	return matchLanguage(geoIP, r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+}
+
+// matchLanguage picks the city database language that best matches the given language tags or lists.
+func matchLanguage(geoIP *db.GeoIPManager, langParam, acceptLanguage string) string {
+	if langParam == "" && acceptLanguage == "" {
+		return common.DefaultLanguage
+	}
+
+	names := []string{common.DefaultLanguage}
+	tags := []language.Tag{language.Make(common.DefaultLanguage)}
+	for _, lang := range geoIP.GetCityDB().Metadata.Languages {
+		if lang == common.DefaultLanguage {
+			continue
 		}
+		names = append(names, lang)
+		tags = append(tags, language.Make(lang))
 	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+
+	_, index := language.MatchStrings(language.NewMatcher(tags), langParam, acceptLanguage)
+	return names[index]
+}
+
+// asnCountry returns the country of the first prefix an ASN announces.
+func asnCountry(ctx context.Context, geoIP *db.GeoIPManager, asn uint) string {
+	prefixes := geoIP.GetASNPrefixes(asn)
+	if len(prefixes) == 0 {
+		return ""
+	}
+	origin, err := common.LookupOrigin(ctx, geoIP, prefixes[0].IP)
+	if err != nil || origin == nil {
+		return ""
 	}
-	return host
+	return origin.Country
 }
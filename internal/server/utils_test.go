@@ -0,0 +1,28 @@
+package server
+
+import "testing"
+
+func TestMatchLanguage(t *testing.T) {
+	geoIP := newTestGeoIP(t)
+
+	tests := []struct {
+		lang           string
+		acceptLanguage string
+		want           string
+	}{
+		{"", "", "en"},
+		{"de", "", "de"},
+		{"de-AT", "", "de"},
+		{"pt", "", "pt-BR"},
+		{"es", "", "en"},
+		{"", "fr-CH, fr;q=0.9, en;q=0.8", "fr"},
+		{"", "es, de;q=0.5", "de"},
+		{"", "*", "en"},
+		{"fr", "de", "fr"},
+	}
+	for _, tt := range tests {
+		if got := matchLanguage(geoIP, tt.lang, tt.acceptLanguage); got != tt.want {
+			t.Errorf("matchLanguage(%q, %q) = %q, want %q", tt.lang, tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ipinfo/internal/common"
+	"ipinfo/internal/config"
+	"ipinfo/internal/db"
+)
+
+const (
+	// whoisLineLimit caps the length of a query line in bytes.
+	whoisLineLimit = 1024
+	// whoisHeader names the columns of every answer line.
+	whoisHeader = "AS | IP | BGP Prefix | CC | AS Name"
+)
+
+// whoisServer answers WHOIS queries for IP addresses and ASNs in the format of the Team Cymru WHOIS
+// service, one "AS | IP | BGP Prefix | CC | AS Name" line per query. A connection carries either a single
+// query, which may be preceded by options such as -v, or a bulk request of one query per line between
+// "begin" and "end".
+type whoisServer struct {
+	geoIP    *db.GeoIPManager
+	cfg      config.WhoisServerConfig
+	hostname string
+	access   *accessLogger
+	listener net.Listener
+	// slots holds a token for every connection being served, so that at most cfg.MaxConnections are.
+	slots chan struct{}
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// newWhoisServer creates a WHOIS server with the given settings that logs connections to access.
+func newWhoisServer(geoIP *db.GeoIPManager, cfg config.WhoisServerConfig, access *accessLogger) *whoisServer {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "ipinfo"
+	}
+	return &whoisServer{
+		geoIP:    geoIP,
+		cfg:      cfg,
+		hostname: hostname,
+		access:   access,
+		slots:    make(chan struct{}, cfg.MaxConnections),
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// start listens for connections and serves them until shutdown is called.
+func (s *whoisServer) start() error {
+	listener, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		return fmt.Errorf("listening for whois: %w", err)
+	}
+	s.listener = listener
+
+	slog.Info("whois server listening", "address", s.cfg.Address, "max_connections", s.cfg.MaxConnections)
+	go func() {
+		if err := s.accept(listener); err != nil {
+			slog.Error("whois server error", "error", err)
+			os.Exit(1)
+		}
+	}()
+	return nil
+}
+
+// accept serves the connections of listener until it is closed, waiting for a free slot before each
+// one. Temporary errors, such as running out of file descriptors, are retried with a growing delay
+// like net/http does; any other error is returned.
+func (s *whoisServer) accept(listener net.Listener) error {
+	var delay time.Duration
+	for {
+		s.slots <- struct{}{}
+		conn, err := listener.Accept()
+		if err != nil {
+			<-s.slots
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Temporary() {
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				slog.Warn("whois server accept failed, retrying", "error", err, "delay", delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// shutdown stops accepting connections and waits for open ones to finish until ctx is done, after which
+// they are closed.
+func (s *whoisServer) shutdown(ctx context.Context) {
+	s.listener.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("whois server shutdown timed out, closing open connections")
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// serve answers the queries of a connection and closes it.
+func (s *whoisServer) serve(conn net.Conn) {
+	start := time.Now()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		<-s.slots
+		s.wg.Done()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, whoisLineLimit), whoisLineLimit)
+	w := bufio.NewWriter(&deadlineWriter{conn: conn, timeout: s.cfg.IdleTimeout})
+	readLine := func() (string, bool) {
+		conn.SetReadDeadline(time.Now().Add(s.cfg.IdleTimeout))
+		if !scanner.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(scanner.Text()), true
+	}
+
+	line, ok := readLine()
+	if !ok {
+		return
+	}
+
+	mode, queries := "query", 1
+	if strings.EqualFold(line, "begin") {
+		mode = "bulk"
+		queries = s.serveBulk(w, readLine)
+	} else {
+		s.serveQuery(w, line)
+	}
+
+	if err := w.Flush(); err != nil {
+		slog.Debug("failed to write whois response", "error", err)
+	}
+
+	level, ok := s.access.entryLevel(context.Background(), false)
+	if !ok {
+		return
+	}
+	client := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	slog.LogAttrs(context.Background(), level, "whois request",
+		slog.String("mode", mode),
+		slog.Int("queries", queries),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("client", client),
+	)
+}
+
+// serveQuery answers a single query line. Options starting with a dash are accepted and ignored,
+// since every answer already has all columns.
+func (s *whoisServer) serveQuery(w *bufio.Writer, line string) {
+	var query string
+	for _, field := range strings.Fields(line) {
+		if !strings.HasPrefix(field, "-") {
+			query = field
+		}
+	}
+
+	fmt.Fprintln(w, whoisHeader)
+	fmt.Fprintln(w, s.answer(context.Background(), query))
+}
+
+// serveBulk answers the queries of a bulk request up to the "end" line and returns their number.
+// The lines "header" and "noheader" turn the column header on and off, and other Team Cymru options
+// such as "verbose" are ignored. Queries are looked up concurrently and answered in order.
+func (s *whoisServer) serveBulk(w *bufio.Writer, readLine func() (string, bool)) int {
+	fmt.Fprintf(w, "Bulk mode; %s [%s]\n", s.hostname, time.Now().UTC().Format("2006-01-02 15:04:05 MST"))
+
+	pending := make(chan chan string, streamConcurrency)
+	count := 0
+	go func() {
+		defer close(pending)
+		header, headerSent := true, false
+		send := func(line string) {
+			result := make(chan string, 1)
+			result <- line
+			pending <- result
+		}
+
+		for {
+			line, ok := readLine()
+			if !ok || strings.EqualFold(line, "end") {
+				return
+			}
+
+			switch strings.ToLower(line) {
+			case "":
+				continue
+			case "header":
+				header = true
+				continue
+			case "noheader":
+				header = false
+				continue
+			case "verbose", "noverbose", "asnumber", "noasnumber", "prefix", "noprefix", "countrycode", "nocountrycode",
+				"registry", "noregistry", "allocdate", "noallocdate", "asname", "noasname", "notruncate", "truncate":
+				continue
+			}
+
+			if header && !headerSent {
+				send(whoisHeader)
+			}
+			headerSent = true
+
+			count++
+			if count > s.cfg.BulkMaxSize {
+				send(fmt.Sprintf("Error: bulk size exceeds the maximum of %d queries.", s.cfg.BulkMaxSize))
+				return
+			}
+
+			result := make(chan string, 1)
+			pending <- result
+			go func(query string) {
+				result <- s.answer(context.Background(), query)
+			}(line)
+		}
+	}()
+
+	for result := range pending {
+		fmt.Fprintln(w, <-result)
+		if len(pending) == 0 {
+			if err := w.Flush(); err != nil {
+				slog.Debug("failed to write whois response", "error", err)
+			}
+		}
+	}
+	return min(count, s.cfg.BulkMaxSize)
+}
+
+// answer returns the answer line of an IP address or ASN query, or an error line. Addresses are looked up
+// in the databases only, without the reverse DNS lookup and cache entry of a full IP lookup, since bulk
+// requests may hold many thousands of them.
+func (s *whoisServer) answer(ctx context.Context, query string) string {
+	if query == "" {
+		return "Error: no query given."
+	}
+
+	if ip := net.ParseIP(query); ip != nil {
+		asn, prefix, country, name := "NA", "", "", "NA"
+		if !common.IsBogon(ip) {
+			origin, err := common.LookupOrigin(ctx, s.geoIP, ip)
+			if err != nil {
+				slog.Error("failed to look up whois query", "query", query, "error", err)
+			}
+			if origin != nil {
+				asn = strconv.FormatUint(uint64(origin.ASN), 10)
+				prefix = origin.Prefix
+				country = origin.Country
+				name = origin.ASName
+			}
+		}
+		return whoisLine(asn, ip.String(), prefix, country, name)
+	}
+
+	if isASNQuery(query) {
+		asn, err := parseASN(query)
+		if err != nil {
+			return fmt.Sprintf("Error: %s is not an IP address or ASN.", query)
+		}
+		data, err := common.LookupASNData(ctx, s.geoIP, asn)
+		if err != nil {
+			return whoisLine(strconv.FormatUint(uint64(asn), 10), "", "", "", "NA")
+		}
+		return whoisLine(strconv.FormatUint(uint64(asn), 10), "", "", asnCountry(ctx, s.geoIP, asn), data.Details.Name)
+	}
+
+	return fmt.Sprintf("Error: %s is not an IP address or ASN.", query)
+}
+
+// whoisLine joins the columns of an answer line.
+func whoisLine(asn, ip, prefix, country, name string) string {
+	return strings.Join([]string{asn, ip, prefix, country, name}, " | ")
+}
+
+// deadlineWriter writes to a connection, giving each write its own deadline so a client that stops
+// reading cannot hold the connection open.
+type deadlineWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	if err := d.conn.SetWriteDeadline(time.Now().Add(d.timeout)); err != nil {
+		return 0, err
+	}
+	return d.conn.Write(p)
+}
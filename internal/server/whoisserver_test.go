@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"ipinfo/internal/config"
+)
+
+// queryTestWhois starts a WHOIS server on the test databases, sends request and returns the answer lines.
+func queryTestWhois(t *testing.T, request string) []string {
+	t.Helper()
+	cfg := config.Default().WhoisServer
+	cfg.Address = "127.0.0.1:0"
+	s := newWhoisServer(newTestGeoIP(t), cfg, newAccessLogger(config.Default().Log))
+	if err := s.start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.shutdown(ctx)
+	})
+
+	conn, err := net.DialTimeout("tcp", s.listener.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestWhoisQuery(t *testing.T) {
+	lines := queryTestWhois(t, " -v 8.8.8.8\r\n")
+	want := []string{whoisHeader, "15169 | 8.8.8.8 | 8.8.8.0/24 | US | Google LLC"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("answer %q, want %q", lines, want)
+	}
+}
+
+func TestWhoisBulk(t *testing.T) {
+	lines := queryTestWhois(t, "begin\nverbose\n8.8.8.8\n2001:4860:4860::8888\n10.0.0.1\n9.9.9.9\nAS13335\nexample.com\nnoheader\n1.1.1.1\nend\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "Bulk mode; ") {
+		t.Fatalf("answer %q, want the bulk mode banner first", lines)
+	}
+	want := []string{
+		whoisHeader,
+		"15169 | 8.8.8.8 | 8.8.8.0/24 | US | Google LLC",
+		"15169 | 2001:4860:4860::8888 | 2001:4860::/32 | US | Google LLC",
+		"NA | 10.0.0.1 |  |  | NA",
+		"NA | 9.9.9.9 |  |  | NA",
+		"13335 |  |  | AU | Cloudflare, Inc.",
+		"Error: example.com is not an IP address or ASN.",
+		"13335 | 1.1.1.1 | 1.1.1.0/24 | AU | Cloudflare, Inc.",
+	}
+	if got := strings.Join(lines[1:], "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("answer\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+// flakyListener fails the first accepts with a temporary error, then with err if set, and otherwise
+// accepts from the wrapped listener.
+type flakyListener struct {
+	net.Listener
+	temporary int
+	err       error
+}
+
+// temporaryError is an accept error such as EMFILE that goes away by itself.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.temporary > 0 {
+		l.temporary--
+		return nil, temporaryError{}
+	}
+	if l.err != nil {
+		return nil, l.err
+	}
+	return l.Listener.Accept()
+}
+
+func TestWhoisAccept(t *testing.T) {
+	cfg := config.Default().WhoisServer
+	cfg.MaxConnections = 1
+	s := newWhoisServer(newTestGeoIP(t), cfg, newAccessLogger(config.Default().Log))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.listener = listener
+	accepted := make(chan error, 1)
+	go func() { accepted <- s.accept(&flakyListener{Listener: listener, temporary: 3}) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.shutdown(ctx)
+		if err := <-accepted; err != nil {
+			t.Errorf("accept returned %v after the listener was closed", err)
+		}
+	})
+
+	dial := func() net.Conn {
+		t.Helper()
+		conn, err := net.DialTimeout("tcp", listener.Addr().String(), 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	// The first connection takes the only slot, so the second is not served until it is closed.
+	first := dial()
+	if _, err := io.WriteString(first, "begin\n"); err != nil {
+		t.Fatal(err)
+	}
+	second := dial()
+	if _, err := io.WriteString(second, "8.8.8.8\n"); err != nil {
+		t.Fatal(err)
+	}
+	_ = second.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err := second.Read(make([]byte, 1)); n > 0 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("second connection served while the first was open: %d bytes, %v", n, err)
+	}
+
+	first.Close()
+	_ = second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	answer, err := io.ReadAll(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(answer), "15169 | 8.8.8.8 |") {
+		t.Errorf("answer %q", answer)
+	}
+}
+
+func TestWhoisAcceptFailure(t *testing.T) {
+	s := newWhoisServer(newTestGeoIP(t), config.Default().WhoisServer, newAccessLogger(config.Default().Log))
+	failure := errors.New("listener broken")
+	if err := s.accept(&flakyListener{temporary: 1, err: failure}); !errors.Is(err, failure) {
+		t.Errorf("accept returned %v, want %v", err, failure)
+	}
+	if len(s.slots) != 0 {
+		t.Errorf("%d slots held after failed accepts", len(s.slots))
+	}
+}
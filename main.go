@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
+	"ipinfo/internal/common"
+	"ipinfo/internal/config"
 	"ipinfo/internal/db"
 	"ipinfo/internal/server"
+	"ipinfo/internal/tracing"
 
 	"github.com/joho/godotenv"
 )
@@ -20,23 +24,71 @@ func main() {
 		slog.Info("env file not found, using system environment variables")
 	}
 
+	cfg, args, printConfig, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(2)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			slog.Error("failed to print configuration", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	common.Configure(cfg)
+
+	if len(args) > 0 {
+		// Offline lookups only report warnings and errors, keeping stderr quiet for scripts.
+		setupLogging(cfg.Log, max(cfg.Log.Level, slog.LevelWarn))
+		os.Exit(runCommand(cfg, args))
+	}
+	setupLogging(cfg.Log, cfg.Log.Level)
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	geoIP, err := db.NewGeoIPManager()
+	geoIP, err := db.NewGeoIPManager(cfg.Database)
 	if err != nil {
 		slog.Error("failed to initialize databases", "error", err)
 		os.Exit(1)
 	}
 	defer geoIP.Close()
 
-	geoIP.StartUpdater(ctx, 24*time.Hour)
+	geoIP.StartUpdater(ctx, cfg.Database.UpdateInterval)
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		// Spans still buffered are flushed before exiting.
+		flushCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}()
 
 	slog.Info("starting server")
-	appServer := server.NewServer(geoIP)
+	appServer := server.NewServer(geoIP, cfg)
 	if err := appServer.Start(ctx); err != nil {
 		slog.Error("server failed to start", "error", err)
 		os.Exit(1)
 	}
 	slog.Info("server shut down gracefully")
 }
+
+// setupLogging makes the default logger write to stderr in the configured format, from level on.
+func setupLogging(cfg config.LogConfig, level slog.Level) {
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, options)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
@@ -0,0 +1,4 @@
+// Package ipinfov1 contains the protobuf messages and gRPC service of the ipinfo API.
+package ipinfov1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative ipinfo/v1/ipinfo.proto
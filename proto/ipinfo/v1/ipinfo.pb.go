@@ -0,0 +1,1554 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: ipinfo/v1/ipinfo.proto
+
+package ipinfov1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LookupIPRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Ip    string                 `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	// Language of place names, such as "de". English is used when empty or unavailable.
+	Language      string `protobuf:"bytes,2,opt,name=language,proto3" json:"language,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupIPRequest) Reset() {
+	*x = LookupIPRequest{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupIPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupIPRequest) ProtoMessage() {}
+
+func (x *LookupIPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupIPRequest.ProtoReflect.Descriptor instead.
+func (*LookupIPRequest) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LookupIPRequest) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *LookupIPRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+type LookupASNRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Asn           uint32                 `protobuf:"varint,1,opt,name=asn,proto3" json:"asn,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupASNRequest) Reset() {
+	*x = LookupASNRequest{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupASNRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupASNRequest) ProtoMessage() {}
+
+func (x *LookupASNRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupASNRequest.ProtoReflect.Descriptor instead.
+func (*LookupASNRequest) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LookupASNRequest) GetAsn() uint32 {
+	if x != nil {
+		return x.Asn
+	}
+	return 0
+}
+
+type LookupDomainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupDomainRequest) Reset() {
+	*x = LookupDomainRequest{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupDomainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupDomainRequest) ProtoMessage() {}
+
+func (x *LookupDomainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupDomainRequest.ProtoReflect.Descriptor instead.
+func (*LookupDomainRequest) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LookupDomainRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+type BatchLookupRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// An IP address, CIDR network, ASN such as "AS13335", or domain.
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Language of place names, such as "de". English is used when empty or unavailable.
+	Language      string `protobuf:"bytes,2,opt,name=language,proto3" json:"language,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchLookupRequest) Reset() {
+	*x = BatchLookupRequest{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchLookupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchLookupRequest) ProtoMessage() {}
+
+func (x *BatchLookupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchLookupRequest.ProtoReflect.Descriptor instead.
+func (*BatchLookupRequest) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchLookupRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *BatchLookupRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+type BatchLookupResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Query string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Types that are valid to be assigned to Result:
+	//
+	//	*BatchLookupResponse_Ip
+	//	*BatchLookupResponse_Network
+	//	*BatchLookupResponse_Asn
+	//	*BatchLookupResponse_Domain
+	//	*BatchLookupResponse_Error
+	Result        isBatchLookupResponse_Result `protobuf_oneof:"result"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchLookupResponse) Reset() {
+	*x = BatchLookupResponse{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchLookupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchLookupResponse) ProtoMessage() {}
+
+func (x *BatchLookupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchLookupResponse.ProtoReflect.Descriptor instead.
+func (*BatchLookupResponse) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchLookupResponse) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *BatchLookupResponse) GetResult() isBatchLookupResponse_Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *BatchLookupResponse) GetIp() *IPInfo {
+	if x != nil {
+		if x, ok := x.Result.(*BatchLookupResponse_Ip); ok {
+			return x.Ip
+		}
+	}
+	return nil
+}
+
+func (x *BatchLookupResponse) GetNetwork() *NetworkInfo {
+	if x != nil {
+		if x, ok := x.Result.(*BatchLookupResponse_Network); ok {
+			return x.Network
+		}
+	}
+	return nil
+}
+
+func (x *BatchLookupResponse) GetAsn() *ASNInfo {
+	if x != nil {
+		if x, ok := x.Result.(*BatchLookupResponse_Asn); ok {
+			return x.Asn
+		}
+	}
+	return nil
+}
+
+func (x *BatchLookupResponse) GetDomain() *DomainInfo {
+	if x != nil {
+		if x, ok := x.Result.(*BatchLookupResponse_Domain); ok {
+			return x.Domain
+		}
+	}
+	return nil
+}
+
+func (x *BatchLookupResponse) GetError() string {
+	if x != nil {
+		if x, ok := x.Result.(*BatchLookupResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return ""
+}
+
+type isBatchLookupResponse_Result interface {
+	isBatchLookupResponse_Result()
+}
+
+type BatchLookupResponse_Ip struct {
+	Ip *IPInfo `protobuf:"bytes,2,opt,name=ip,proto3,oneof"`
+}
+
+type BatchLookupResponse_Network struct {
+	Network *NetworkInfo `protobuf:"bytes,3,opt,name=network,proto3,oneof"`
+}
+
+type BatchLookupResponse_Asn struct {
+	Asn *ASNInfo `protobuf:"bytes,4,opt,name=asn,proto3,oneof"`
+}
+
+type BatchLookupResponse_Domain struct {
+	Domain *DomainInfo `protobuf:"bytes,5,opt,name=domain,proto3,oneof"`
+}
+
+type BatchLookupResponse_Error struct {
+	// Why the query could not be looked up.
+	Error string `protobuf:"bytes,6,opt,name=error,proto3,oneof"`
+}
+
+func (*BatchLookupResponse_Ip) isBatchLookupResponse_Result() {}
+
+func (*BatchLookupResponse_Network) isBatchLookupResponse_Result() {}
+
+func (*BatchLookupResponse_Asn) isBatchLookupResponse_Result() {}
+
+func (*BatchLookupResponse_Domain) isBatchLookupResponse_Result() {}
+
+func (*BatchLookupResponse_Error) isBatchLookupResponse_Result() {}
+
+type IPInfo struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Ip    string                 `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	// Set for private, reserved and other non-routable addresses, which have no other data.
+	Bogon             bool           `protobuf:"varint,2,opt,name=bogon,proto3" json:"bogon,omitempty"`
+	Hostname          string         `protobuf:"bytes,3,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Asn               uint32         `protobuf:"varint,4,opt,name=asn,proto3" json:"asn,omitempty"`
+	AsName            string         `protobuf:"bytes,5,opt,name=as_name,json=asName,proto3" json:"as_name,omitempty"`
+	Org               string         `protobuf:"bytes,6,opt,name=org,proto3" json:"org,omitempty"`
+	Network           string         `protobuf:"bytes,7,opt,name=network,proto3" json:"network,omitempty"`
+	City              string         `protobuf:"bytes,8,opt,name=city,proto3" json:"city,omitempty"`
+	Region            string         `protobuf:"bytes,9,opt,name=region,proto3" json:"region,omitempty"`
+	Subdivisions      []*Subdivision `protobuf:"bytes,10,rep,name=subdivisions,proto3" json:"subdivisions,omitempty"`
+	Postal            string         `protobuf:"bytes,11,opt,name=postal,proto3" json:"postal,omitempty"`
+	Country           string         `protobuf:"bytes,12,opt,name=country,proto3" json:"country,omitempty"`
+	CountryName       string         `protobuf:"bytes,13,opt,name=country_name,json=countryName,proto3" json:"country_name,omitempty"`
+	IsInEuropeanUnion *bool          `protobuf:"varint,14,opt,name=is_in_european_union,json=isInEuropeanUnion,proto3,oneof" json:"is_in_european_union,omitempty"`
+	Continent         string         `protobuf:"bytes,15,opt,name=continent,proto3" json:"continent,omitempty"`
+	ContinentCode     string         `protobuf:"bytes,16,opt,name=continent_code,json=continentCode,proto3" json:"continent_code,omitempty"`
+	Timezone          string         `protobuf:"bytes,17,opt,name=timezone,proto3" json:"timezone,omitempty"`
+	// Latitude and longitude, such as "37.7510,-97.8220".
+	Loc            string `protobuf:"bytes,18,opt,name=loc,proto3" json:"loc,omitempty"`
+	AccuracyRadius uint32 `protobuf:"varint,19,opt,name=accuracy_radius,json=accuracyRadius,proto3" json:"accuracy_radius,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *IPInfo) Reset() {
+	*x = IPInfo{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IPInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IPInfo) ProtoMessage() {}
+
+func (x *IPInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IPInfo.ProtoReflect.Descriptor instead.
+func (*IPInfo) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *IPInfo) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *IPInfo) GetBogon() bool {
+	if x != nil {
+		return x.Bogon
+	}
+	return false
+}
+
+func (x *IPInfo) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *IPInfo) GetAsn() uint32 {
+	if x != nil {
+		return x.Asn
+	}
+	return 0
+}
+
+func (x *IPInfo) GetAsName() string {
+	if x != nil {
+		return x.AsName
+	}
+	return ""
+}
+
+func (x *IPInfo) GetOrg() string {
+	if x != nil {
+		return x.Org
+	}
+	return ""
+}
+
+func (x *IPInfo) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *IPInfo) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *IPInfo) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *IPInfo) GetSubdivisions() []*Subdivision {
+	if x != nil {
+		return x.Subdivisions
+	}
+	return nil
+}
+
+func (x *IPInfo) GetPostal() string {
+	if x != nil {
+		return x.Postal
+	}
+	return ""
+}
+
+func (x *IPInfo) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *IPInfo) GetCountryName() string {
+	if x != nil {
+		return x.CountryName
+	}
+	return ""
+}
+
+func (x *IPInfo) GetIsInEuropeanUnion() bool {
+	if x != nil && x.IsInEuropeanUnion != nil {
+		return *x.IsInEuropeanUnion
+	}
+	return false
+}
+
+func (x *IPInfo) GetContinent() string {
+	if x != nil {
+		return x.Continent
+	}
+	return ""
+}
+
+func (x *IPInfo) GetContinentCode() string {
+	if x != nil {
+		return x.ContinentCode
+	}
+	return ""
+}
+
+func (x *IPInfo) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
+func (x *IPInfo) GetLoc() string {
+	if x != nil {
+		return x.Loc
+	}
+	return ""
+}
+
+func (x *IPInfo) GetAccuracyRadius() uint32 {
+	if x != nil {
+		return x.AccuracyRadius
+	}
+	return 0
+}
+
+type Subdivision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subdivision) Reset() {
+	*x = Subdivision{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subdivision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subdivision) ProtoMessage() {}
+
+func (x *Subdivision) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subdivision.ProtoReflect.Descriptor instead.
+func (*Subdivision) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Subdivision) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Subdivision) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type NetworkInfo struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Network string                 `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	// Set for private, reserved and other non-routable networks, which have no other data.
+	Bogon        bool   `protobuf:"varint,2,opt,name=bogon,proto3" json:"bogon,omitempty"`
+	PrefixLength int32  `protobuf:"varint,3,opt,name=prefix_length,json=prefixLength,proto3" json:"prefix_length,omitempty"`
+	FirstAddress string `protobuf:"bytes,4,opt,name=first_address,json=firstAddress,proto3" json:"first_address,omitempty"`
+	LastAddress  string `protobuf:"bytes,5,opt,name=last_address,json=lastAddress,proto3" json:"last_address,omitempty"`
+	// Number of addresses in the network, in decimal, since IPv6 networks exceed 64 bits.
+	AddressCount  string `protobuf:"bytes,6,opt,name=address_count,json=addressCount,proto3" json:"address_count,omitempty"`
+	CityNetwork   string `protobuf:"bytes,7,opt,name=city_network,json=cityNetwork,proto3" json:"city_network,omitempty"`
+	AsnNetwork    string `protobuf:"bytes,8,opt,name=asn_network,json=asnNetwork,proto3" json:"asn_network,omitempty"`
+	Org           string `protobuf:"bytes,9,opt,name=org,proto3" json:"org,omitempty"`
+	Country       string `protobuf:"bytes,10,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetworkInfo) Reset() {
+	*x = NetworkInfo{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetworkInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetworkInfo) ProtoMessage() {}
+
+func (x *NetworkInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetworkInfo.ProtoReflect.Descriptor instead.
+func (*NetworkInfo) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *NetworkInfo) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *NetworkInfo) GetBogon() bool {
+	if x != nil {
+		return x.Bogon
+	}
+	return false
+}
+
+func (x *NetworkInfo) GetPrefixLength() int32 {
+	if x != nil {
+		return x.PrefixLength
+	}
+	return 0
+}
+
+func (x *NetworkInfo) GetFirstAddress() string {
+	if x != nil {
+		return x.FirstAddress
+	}
+	return ""
+}
+
+func (x *NetworkInfo) GetLastAddress() string {
+	if x != nil {
+		return x.LastAddress
+	}
+	return ""
+}
+
+func (x *NetworkInfo) GetAddressCount() string {
+	if x != nil {
+		return x.AddressCount
+	}
+	return ""
+}
+
+func (x *NetworkInfo) GetCityNetwork() string {
+	if x != nil {
+		return x.CityNetwork
+	}
+	return ""
+}
+
+func (x *NetworkInfo) GetAsnNetwork() string {
+	if x != nil {
+		return x.AsnNetwork
+	}
+	return ""
+}
+
+func (x *NetworkInfo) GetOrg() string {
+	if x != nil {
+		return x.Org
+	}
+	return ""
+}
+
+func (x *NetworkInfo) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+type ASNInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Asn           uint32                 `protobuf:"varint,1,opt,name=asn,proto3" json:"asn,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Ipv4Prefixes  []string               `protobuf:"bytes,3,rep,name=ipv4_prefixes,json=ipv4Prefixes,proto3" json:"ipv4_prefixes,omitempty"`
+	Ipv6Prefixes  []string               `protobuf:"bytes,4,rep,name=ipv6_prefixes,json=ipv6Prefixes,proto3" json:"ipv6_prefixes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ASNInfo) Reset() {
+	*x = ASNInfo{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ASNInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ASNInfo) ProtoMessage() {}
+
+func (x *ASNInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ASNInfo.ProtoReflect.Descriptor instead.
+func (*ASNInfo) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ASNInfo) GetAsn() uint32 {
+	if x != nil {
+		return x.Asn
+	}
+	return 0
+}
+
+func (x *ASNInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ASNInfo) GetIpv4Prefixes() []string {
+	if x != nil {
+		return x.Ipv4Prefixes
+	}
+	return nil
+}
+
+func (x *ASNInfo) GetIpv6Prefixes() []string {
+	if x != nil {
+		return x.Ipv6Prefixes
+	}
+	return nil
+}
+
+type DomainInfo struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Domain string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	// Absent when the WHOIS lookup failed.
+	Whois         *Whois      `protobuf:"bytes,2,opt,name=whois,proto3" json:"whois,omitempty"`
+	Dns           *DNSRecords `protobuf:"bytes,3,opt,name=dns,proto3" json:"dns,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DomainInfo) Reset() {
+	*x = DomainInfo{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DomainInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DomainInfo) ProtoMessage() {}
+
+func (x *DomainInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DomainInfo.ProtoReflect.Descriptor instead.
+func (*DomainInfo) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DomainInfo) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *DomainInfo) GetWhois() *Whois {
+	if x != nil {
+		return x.Whois
+	}
+	return nil
+}
+
+func (x *DomainInfo) GetDns() *DNSRecords {
+	if x != nil {
+		return x.Dns
+	}
+	return nil
+}
+
+type Whois struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Domain     *WhoisDomain           `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Registrar  *WhoisRegistrar        `protobuf:"bytes,2,opt,name=registrar,proto3" json:"registrar,omitempty"`
+	Registrant *WhoisContact          `protobuf:"bytes,3,opt,name=registrant,proto3" json:"registrant,omitempty"`
+	Admin      *WhoisContact          `protobuf:"bytes,4,opt,name=admin,proto3" json:"admin,omitempty"`
+	Tech       *WhoisContact          `protobuf:"bytes,5,opt,name=tech,proto3" json:"tech,omitempty"`
+	// The unparsed response, set instead of the other fields when it could not be parsed.
+	Raw           string `protobuf:"bytes,6,opt,name=raw,proto3" json:"raw,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Whois) Reset() {
+	*x = Whois{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Whois) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Whois) ProtoMessage() {}
+
+func (x *Whois) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Whois.ProtoReflect.Descriptor instead.
+func (*Whois) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Whois) GetDomain() *WhoisDomain {
+	if x != nil {
+		return x.Domain
+	}
+	return nil
+}
+
+func (x *Whois) GetRegistrar() *WhoisRegistrar {
+	if x != nil {
+		return x.Registrar
+	}
+	return nil
+}
+
+func (x *Whois) GetRegistrant() *WhoisContact {
+	if x != nil {
+		return x.Registrant
+	}
+	return nil
+}
+
+func (x *Whois) GetAdmin() *WhoisContact {
+	if x != nil {
+		return x.Admin
+	}
+	return nil
+}
+
+func (x *Whois) GetTech() *WhoisContact {
+	if x != nil {
+		return x.Tech
+	}
+	return nil
+}
+
+func (x *Whois) GetRaw() string {
+	if x != nil {
+		return x.Raw
+	}
+	return ""
+}
+
+type WhoisDomain struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Domain         string                 `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	WhoisServer    string                 `protobuf:"bytes,3,opt,name=whois_server,json=whoisServer,proto3" json:"whois_server,omitempty"`
+	Status         []string               `protobuf:"bytes,4,rep,name=status,proto3" json:"status,omitempty"`
+	NameServers    []string               `protobuf:"bytes,5,rep,name=name_servers,json=nameServers,proto3" json:"name_servers,omitempty"`
+	Dnssec         bool                   `protobuf:"varint,6,opt,name=dnssec,proto3" json:"dnssec,omitempty"`
+	CreatedDate    string                 `protobuf:"bytes,7,opt,name=created_date,json=createdDate,proto3" json:"created_date,omitempty"`
+	UpdatedDate    string                 `protobuf:"bytes,8,opt,name=updated_date,json=updatedDate,proto3" json:"updated_date,omitempty"`
+	ExpirationDate string                 `protobuf:"bytes,9,opt,name=expiration_date,json=expirationDate,proto3" json:"expiration_date,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *WhoisDomain) Reset() {
+	*x = WhoisDomain{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WhoisDomain) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WhoisDomain) ProtoMessage() {}
+
+func (x *WhoisDomain) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WhoisDomain.ProtoReflect.Descriptor instead.
+func (*WhoisDomain) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *WhoisDomain) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *WhoisDomain) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *WhoisDomain) GetWhoisServer() string {
+	if x != nil {
+		return x.WhoisServer
+	}
+	return ""
+}
+
+func (x *WhoisDomain) GetStatus() []string {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *WhoisDomain) GetNameServers() []string {
+	if x != nil {
+		return x.NameServers
+	}
+	return nil
+}
+
+func (x *WhoisDomain) GetDnssec() bool {
+	if x != nil {
+		return x.Dnssec
+	}
+	return false
+}
+
+func (x *WhoisDomain) GetCreatedDate() string {
+	if x != nil {
+		return x.CreatedDate
+	}
+	return ""
+}
+
+func (x *WhoisDomain) GetUpdatedDate() string {
+	if x != nil {
+		return x.UpdatedDate
+	}
+	return ""
+}
+
+func (x *WhoisDomain) GetExpirationDate() string {
+	if x != nil {
+		return x.ExpirationDate
+	}
+	return ""
+}
+
+type WhoisRegistrar struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email         string                 `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	Phone         string                 `protobuf:"bytes,4,opt,name=phone,proto3" json:"phone,omitempty"`
+	ReferralUrl   string                 `protobuf:"bytes,5,opt,name=referral_url,json=referralUrl,proto3" json:"referral_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WhoisRegistrar) Reset() {
+	*x = WhoisRegistrar{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WhoisRegistrar) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WhoisRegistrar) ProtoMessage() {}
+
+func (x *WhoisRegistrar) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WhoisRegistrar.ProtoReflect.Descriptor instead.
+func (*WhoisRegistrar) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *WhoisRegistrar) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *WhoisRegistrar) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *WhoisRegistrar) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *WhoisRegistrar) GetPhone() string {
+	if x != nil {
+		return x.Phone
+	}
+	return ""
+}
+
+func (x *WhoisRegistrar) GetReferralUrl() string {
+	if x != nil {
+		return x.ReferralUrl
+	}
+	return ""
+}
+
+type WhoisContact struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Organization  string                 `protobuf:"bytes,3,opt,name=organization,proto3" json:"organization,omitempty"`
+	Street        string                 `protobuf:"bytes,4,opt,name=street,proto3" json:"street,omitempty"`
+	City          string                 `protobuf:"bytes,5,opt,name=city,proto3" json:"city,omitempty"`
+	Province      string                 `protobuf:"bytes,6,opt,name=province,proto3" json:"province,omitempty"`
+	PostalCode    string                 `protobuf:"bytes,7,opt,name=postal_code,json=postalCode,proto3" json:"postal_code,omitempty"`
+	Country       string                 `protobuf:"bytes,8,opt,name=country,proto3" json:"country,omitempty"`
+	Phone         string                 `protobuf:"bytes,9,opt,name=phone,proto3" json:"phone,omitempty"`
+	Fax           string                 `protobuf:"bytes,10,opt,name=fax,proto3" json:"fax,omitempty"`
+	Email         string                 `protobuf:"bytes,11,opt,name=email,proto3" json:"email,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WhoisContact) Reset() {
+	*x = WhoisContact{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WhoisContact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WhoisContact) ProtoMessage() {}
+
+func (x *WhoisContact) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WhoisContact.ProtoReflect.Descriptor instead.
+func (*WhoisContact) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *WhoisContact) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetOrganization() string {
+	if x != nil {
+		return x.Organization
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetStreet() string {
+	if x != nil {
+		return x.Street
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetProvince() string {
+	if x != nil {
+		return x.Province
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetPostalCode() string {
+	if x != nil {
+		return x.PostalCode
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetPhone() string {
+	if x != nil {
+		return x.Phone
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetFax() string {
+	if x != nil {
+		return x.Fax
+	}
+	return ""
+}
+
+func (x *WhoisContact) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+type DNSRecords struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	A             []string               `protobuf:"bytes,1,rep,name=a,proto3" json:"a,omitempty"`
+	Aaaa          []string               `protobuf:"bytes,2,rep,name=aaaa,proto3" json:"aaaa,omitempty"`
+	Cname         string                 `protobuf:"bytes,3,opt,name=cname,proto3" json:"cname,omitempty"`
+	Mx            []string               `protobuf:"bytes,4,rep,name=mx,proto3" json:"mx,omitempty"`
+	Txt           []string               `protobuf:"bytes,5,rep,name=txt,proto3" json:"txt,omitempty"`
+	Ns            []string               `protobuf:"bytes,6,rep,name=ns,proto3" json:"ns,omitempty"`
+	Soa           []string               `protobuf:"bytes,7,rep,name=soa,proto3" json:"soa,omitempty"`
+	Caa           []string               `protobuf:"bytes,8,rep,name=caa,proto3" json:"caa,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DNSRecords) Reset() {
+	*x = DNSRecords{}
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DNSRecords) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DNSRecords) ProtoMessage() {}
+
+func (x *DNSRecords) ProtoReflect() protoreflect.Message {
+	mi := &file_ipinfo_v1_ipinfo_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DNSRecords.ProtoReflect.Descriptor instead.
+func (*DNSRecords) Descriptor() ([]byte, []int) {
+	return file_ipinfo_v1_ipinfo_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DNSRecords) GetA() []string {
+	if x != nil {
+		return x.A
+	}
+	return nil
+}
+
+func (x *DNSRecords) GetAaaa() []string {
+	if x != nil {
+		return x.Aaaa
+	}
+	return nil
+}
+
+func (x *DNSRecords) GetCname() string {
+	if x != nil {
+		return x.Cname
+	}
+	return ""
+}
+
+func (x *DNSRecords) GetMx() []string {
+	if x != nil {
+		return x.Mx
+	}
+	return nil
+}
+
+func (x *DNSRecords) GetTxt() []string {
+	if x != nil {
+		return x.Txt
+	}
+	return nil
+}
+
+func (x *DNSRecords) GetNs() []string {
+	if x != nil {
+		return x.Ns
+	}
+	return nil
+}
+
+func (x *DNSRecords) GetSoa() []string {
+	if x != nil {
+		return x.Soa
+	}
+	return nil
+}
+
+func (x *DNSRecords) GetCaa() []string {
+	if x != nil {
+		return x.Caa
+	}
+	return nil
+}
+
+var File_ipinfo_v1_ipinfo_proto protoreflect.FileDescriptor
+
+const file_ipinfo_v1_ipinfo_proto_rawDesc = "" +
+	"\n" +
+	"\x16ipinfo/v1/ipinfo.proto\x12\tipinfo.v1\"=\n" +
+	"\x0fLookupIPRequest\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x1a\n" +
+	"\blanguage\x18\x02 \x01(\tR\blanguage\"$\n" +
+	"\x10LookupASNRequest\x12\x10\n" +
+	"\x03asn\x18\x01 \x01(\rR\x03asn\"-\n" +
+	"\x13LookupDomainRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\"F\n" +
+	"\x12BatchLookupRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1a\n" +
+	"\blanguage\x18\x02 \x01(\tR\blanguage\"\xff\x01\n" +
+	"\x13BatchLookupResponse\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12#\n" +
+	"\x02ip\x18\x02 \x01(\v2\x11.ipinfo.v1.IPInfoH\x00R\x02ip\x122\n" +
+	"\anetwork\x18\x03 \x01(\v2\x16.ipinfo.v1.NetworkInfoH\x00R\anetwork\x12&\n" +
+	"\x03asn\x18\x04 \x01(\v2\x12.ipinfo.v1.ASNInfoH\x00R\x03asn\x12/\n" +
+	"\x06domain\x18\x05 \x01(\v2\x15.ipinfo.v1.DomainInfoH\x00R\x06domain\x12\x16\n" +
+	"\x05error\x18\x06 \x01(\tH\x00R\x05errorB\b\n" +
+	"\x06result\"\xc9\x04\n" +
+	"\x06IPInfo\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x14\n" +
+	"\x05bogon\x18\x02 \x01(\bR\x05bogon\x12\x1a\n" +
+	"\bhostname\x18\x03 \x01(\tR\bhostname\x12\x10\n" +
+	"\x03asn\x18\x04 \x01(\rR\x03asn\x12\x17\n" +
+	"\aas_name\x18\x05 \x01(\tR\x06asName\x12\x10\n" +
+	"\x03org\x18\x06 \x01(\tR\x03org\x12\x18\n" +
+	"\anetwork\x18\a \x01(\tR\anetwork\x12\x12\n" +
+	"\x04city\x18\b \x01(\tR\x04city\x12\x16\n" +
+	"\x06region\x18\t \x01(\tR\x06region\x12:\n" +
+	"\fsubdivisions\x18\n" +
+	" \x03(\v2\x16.ipinfo.v1.SubdivisionR\fsubdivisions\x12\x16\n" +
+	"\x06postal\x18\v \x01(\tR\x06postal\x12\x18\n" +
+	"\acountry\x18\f \x01(\tR\acountry\x12!\n" +
+	"\fcountry_name\x18\r \x01(\tR\vcountryName\x124\n" +
+	"\x14is_in_european_union\x18\x0e \x01(\bH\x00R\x11isInEuropeanUnion\x88\x01\x01\x12\x1c\n" +
+	"\tcontinent\x18\x0f \x01(\tR\tcontinent\x12%\n" +
+	"\x0econtinent_code\x18\x10 \x01(\tR\rcontinentCode\x12\x1a\n" +
+	"\btimezone\x18\x11 \x01(\tR\btimezone\x12\x10\n" +
+	"\x03loc\x18\x12 \x01(\tR\x03loc\x12'\n" +
+	"\x0faccuracy_radius\x18\x13 \x01(\rR\x0eaccuracyRadiusB\x17\n" +
+	"\x15_is_in_european_union\"5\n" +
+	"\vSubdivision\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\xbf\x02\n" +
+	"\vNetworkInfo\x12\x18\n" +
+	"\anetwork\x18\x01 \x01(\tR\anetwork\x12\x14\n" +
+	"\x05bogon\x18\x02 \x01(\bR\x05bogon\x12#\n" +
+	"\rprefix_length\x18\x03 \x01(\x05R\fprefixLength\x12#\n" +
+	"\rfirst_address\x18\x04 \x01(\tR\ffirstAddress\x12!\n" +
+	"\flast_address\x18\x05 \x01(\tR\vlastAddress\x12#\n" +
+	"\raddress_count\x18\x06 \x01(\tR\faddressCount\x12!\n" +
+	"\fcity_network\x18\a \x01(\tR\vcityNetwork\x12\x1f\n" +
+	"\vasn_network\x18\b \x01(\tR\n" +
+	"asnNetwork\x12\x10\n" +
+	"\x03org\x18\t \x01(\tR\x03org\x12\x18\n" +
+	"\acountry\x18\n" +
+	" \x01(\tR\acountry\"y\n" +
+	"\aASNInfo\x12\x10\n" +
+	"\x03asn\x18\x01 \x01(\rR\x03asn\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12#\n" +
+	"\ripv4_prefixes\x18\x03 \x03(\tR\fipv4Prefixes\x12#\n" +
+	"\ripv6_prefixes\x18\x04 \x03(\tR\fipv6Prefixes\"u\n" +
+	"\n" +
+	"DomainInfo\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12&\n" +
+	"\x05whois\x18\x02 \x01(\v2\x10.ipinfo.v1.WhoisR\x05whois\x12'\n" +
+	"\x03dns\x18\x03 \x01(\v2\x15.ipinfo.v1.DNSRecordsR\x03dns\"\x97\x02\n" +
+	"\x05Whois\x12.\n" +
+	"\x06domain\x18\x01 \x01(\v2\x16.ipinfo.v1.WhoisDomainR\x06domain\x127\n" +
+	"\tregistrar\x18\x02 \x01(\v2\x19.ipinfo.v1.WhoisRegistrarR\tregistrar\x127\n" +
+	"\n" +
+	"registrant\x18\x03 \x01(\v2\x17.ipinfo.v1.WhoisContactR\n" +
+	"registrant\x12-\n" +
+	"\x05admin\x18\x04 \x01(\v2\x17.ipinfo.v1.WhoisContactR\x05admin\x12+\n" +
+	"\x04tech\x18\x05 \x01(\v2\x17.ipinfo.v1.WhoisContactR\x04tech\x12\x10\n" +
+	"\x03raw\x18\x06 \x01(\tR\x03raw\"\x9a\x02\n" +
+	"\vWhoisDomain\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\x12!\n" +
+	"\fwhois_server\x18\x03 \x01(\tR\vwhoisServer\x12\x16\n" +
+	"\x06status\x18\x04 \x03(\tR\x06status\x12!\n" +
+	"\fname_servers\x18\x05 \x03(\tR\vnameServers\x12\x16\n" +
+	"\x06dnssec\x18\x06 \x01(\bR\x06dnssec\x12!\n" +
+	"\fcreated_date\x18\a \x01(\tR\vcreatedDate\x12!\n" +
+	"\fupdated_date\x18\b \x01(\tR\vupdatedDate\x12'\n" +
+	"\x0fexpiration_date\x18\t \x01(\tR\x0eexpirationDate\"\x83\x01\n" +
+	"\x0eWhoisRegistrar\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05email\x18\x03 \x01(\tR\x05email\x12\x14\n" +
+	"\x05phone\x18\x04 \x01(\tR\x05phone\x12!\n" +
+	"\freferral_url\x18\x05 \x01(\tR\vreferralUrl\"\x97\x02\n" +
+	"\fWhoisContact\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\"\n" +
+	"\forganization\x18\x03 \x01(\tR\forganization\x12\x16\n" +
+	"\x06street\x18\x04 \x01(\tR\x06street\x12\x12\n" +
+	"\x04city\x18\x05 \x01(\tR\x04city\x12\x1a\n" +
+	"\bprovince\x18\x06 \x01(\tR\bprovince\x12\x1f\n" +
+	"\vpostal_code\x18\a \x01(\tR\n" +
+	"postalCode\x12\x18\n" +
+	"\acountry\x18\b \x01(\tR\acountry\x12\x14\n" +
+	"\x05phone\x18\t \x01(\tR\x05phone\x12\x10\n" +
+	"\x03fax\x18\n" +
+	" \x01(\tR\x03fax\x12\x14\n" +
+	"\x05email\x18\v \x01(\tR\x05email\"\x9a\x01\n" +
+	"\n" +
+	"DNSRecords\x12\f\n" +
+	"\x01a\x18\x01 \x03(\tR\x01a\x12\x12\n" +
+	"\x04aaaa\x18\x02 \x03(\tR\x04aaaa\x12\x14\n" +
+	"\x05cname\x18\x03 \x01(\tR\x05cname\x12\x0e\n" +
+	"\x02mx\x18\x04 \x03(\tR\x02mx\x12\x10\n" +
+	"\x03txt\x18\x05 \x03(\tR\x03txt\x12\x0e\n" +
+	"\x02ns\x18\x06 \x03(\tR\x02ns\x12\x10\n" +
+	"\x03soa\x18\a \x03(\tR\x03soa\x12\x10\n" +
+	"\x03caa\x18\b \x03(\tR\x03caa2\xa1\x02\n" +
+	"\rIPInfoService\x129\n" +
+	"\bLookupIP\x12\x1a.ipinfo.v1.LookupIPRequest\x1a\x11.ipinfo.v1.IPInfo\x12<\n" +
+	"\tLookupASN\x12\x1b.ipinfo.v1.LookupASNRequest\x1a\x12.ipinfo.v1.ASNInfo\x12E\n" +
+	"\fLookupDomain\x12\x1e.ipinfo.v1.LookupDomainRequest\x1a\x15.ipinfo.v1.DomainInfo\x12P\n" +
+	"\vBatchLookup\x12\x1d.ipinfo.v1.BatchLookupRequest\x1a\x1e.ipinfo.v1.BatchLookupResponse(\x010\x01B!Z\x1fipinfo/proto/ipinfo/v1;ipinfov1b\x06proto3"
+
+var (
+	file_ipinfo_v1_ipinfo_proto_rawDescOnce sync.Once
+	file_ipinfo_v1_ipinfo_proto_rawDescData []byte
+)
+
+func file_ipinfo_v1_ipinfo_proto_rawDescGZIP() []byte {
+	file_ipinfo_v1_ipinfo_proto_rawDescOnce.Do(func() {
+		file_ipinfo_v1_ipinfo_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_ipinfo_v1_ipinfo_proto_rawDesc), len(file_ipinfo_v1_ipinfo_proto_rawDesc)))
+	})
+	return file_ipinfo_v1_ipinfo_proto_rawDescData
+}
+
+var file_ipinfo_v1_ipinfo_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_ipinfo_v1_ipinfo_proto_goTypes = []any{
+	(*LookupIPRequest)(nil),     // 0: ipinfo.v1.LookupIPRequest
+	(*LookupASNRequest)(nil),    // 1: ipinfo.v1.LookupASNRequest
+	(*LookupDomainRequest)(nil), // 2: ipinfo.v1.LookupDomainRequest
+	(*BatchLookupRequest)(nil),  // 3: ipinfo.v1.BatchLookupRequest
+	(*BatchLookupResponse)(nil), // 4: ipinfo.v1.BatchLookupResponse
+	(*IPInfo)(nil),              // 5: ipinfo.v1.IPInfo
+	(*Subdivision)(nil),         // 6: ipinfo.v1.Subdivision
+	(*NetworkInfo)(nil),         // 7: ipinfo.v1.NetworkInfo
+	(*ASNInfo)(nil),             // 8: ipinfo.v1.ASNInfo
+	(*DomainInfo)(nil),          // 9: ipinfo.v1.DomainInfo
+	(*Whois)(nil),               // 10: ipinfo.v1.Whois
+	(*WhoisDomain)(nil),         // 11: ipinfo.v1.WhoisDomain
+	(*WhoisRegistrar)(nil),      // 12: ipinfo.v1.WhoisRegistrar
+	(*WhoisContact)(nil),        // 13: ipinfo.v1.WhoisContact
+	(*DNSRecords)(nil),          // 14: ipinfo.v1.DNSRecords
+}
+var file_ipinfo_v1_ipinfo_proto_depIdxs = []int32{
+	5,  // 0: ipinfo.v1.BatchLookupResponse.ip:type_name -> ipinfo.v1.IPInfo
+	7,  // 1: ipinfo.v1.BatchLookupResponse.network:type_name -> ipinfo.v1.NetworkInfo
+	8,  // 2: ipinfo.v1.BatchLookupResponse.asn:type_name -> ipinfo.v1.ASNInfo
+	9,  // 3: ipinfo.v1.BatchLookupResponse.domain:type_name -> ipinfo.v1.DomainInfo
+	6,  // 4: ipinfo.v1.IPInfo.subdivisions:type_name -> ipinfo.v1.Subdivision
+	10, // 5: ipinfo.v1.DomainInfo.whois:type_name -> ipinfo.v1.Whois
+	14, // 6: ipinfo.v1.DomainInfo.dns:type_name -> ipinfo.v1.DNSRecords
+	11, // 7: ipinfo.v1.Whois.domain:type_name -> ipinfo.v1.WhoisDomain
+	12, // 8: ipinfo.v1.Whois.registrar:type_name -> ipinfo.v1.WhoisRegistrar
+	13, // 9: ipinfo.v1.Whois.registrant:type_name -> ipinfo.v1.WhoisContact
+	13, // 10: ipinfo.v1.Whois.admin:type_name -> ipinfo.v1.WhoisContact
+	13, // 11: ipinfo.v1.Whois.tech:type_name -> ipinfo.v1.WhoisContact
+	0,  // 12: ipinfo.v1.IPInfoService.LookupIP:input_type -> ipinfo.v1.LookupIPRequest
+	1,  // 13: ipinfo.v1.IPInfoService.LookupASN:input_type -> ipinfo.v1.LookupASNRequest
+	2,  // 14: ipinfo.v1.IPInfoService.LookupDomain:input_type -> ipinfo.v1.LookupDomainRequest
+	3,  // 15: ipinfo.v1.IPInfoService.BatchLookup:input_type -> ipinfo.v1.BatchLookupRequest
+	5,  // 16: ipinfo.v1.IPInfoService.LookupIP:output_type -> ipinfo.v1.IPInfo
+	8,  // 17: ipinfo.v1.IPInfoService.LookupASN:output_type -> ipinfo.v1.ASNInfo
+	9,  // 18: ipinfo.v1.IPInfoService.LookupDomain:output_type -> ipinfo.v1.DomainInfo
+	4,  // 19: ipinfo.v1.IPInfoService.BatchLookup:output_type -> ipinfo.v1.BatchLookupResponse
+	16, // [16:20] is the sub-list for method output_type
+	12, // [12:16] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_ipinfo_v1_ipinfo_proto_init() }
+func file_ipinfo_v1_ipinfo_proto_init() {
+	if File_ipinfo_v1_ipinfo_proto != nil {
+		return
+	}
+	file_ipinfo_v1_ipinfo_proto_msgTypes[4].OneofWrappers = []any{
+		(*BatchLookupResponse_Ip)(nil),
+		(*BatchLookupResponse_Network)(nil),
+		(*BatchLookupResponse_Asn)(nil),
+		(*BatchLookupResponse_Domain)(nil),
+		(*BatchLookupResponse_Error)(nil),
+	}
+	file_ipinfo_v1_ipinfo_proto_msgTypes[5].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_ipinfo_v1_ipinfo_proto_rawDesc), len(file_ipinfo_v1_ipinfo_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_ipinfo_v1_ipinfo_proto_goTypes,
+		DependencyIndexes: file_ipinfo_v1_ipinfo_proto_depIdxs,
+		MessageInfos:      file_ipinfo_v1_ipinfo_proto_msgTypes,
+	}.Build()
+	File_ipinfo_v1_ipinfo_proto = out.File
+	file_ipinfo_v1_ipinfo_proto_goTypes = nil
+	file_ipinfo_v1_ipinfo_proto_depIdxs = nil
+}
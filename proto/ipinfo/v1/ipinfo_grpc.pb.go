@@ -0,0 +1,242 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: ipinfo/v1/ipinfo.proto
+
+package ipinfov1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	IPInfoService_LookupIP_FullMethodName     = "/ipinfo.v1.IPInfoService/LookupIP"
+	IPInfoService_LookupASN_FullMethodName    = "/ipinfo.v1.IPInfoService/LookupASN"
+	IPInfoService_LookupDomain_FullMethodName = "/ipinfo.v1.IPInfoService/LookupDomain"
+	IPInfoService_BatchLookup_FullMethodName  = "/ipinfo.v1.IPInfoService/BatchLookup"
+)
+
+// IPInfoServiceClient is the client API for IPInfoService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// IPInfoService looks up IP addresses, networks, ASNs and domains with the same data as the HTTP API.
+type IPInfoServiceClient interface {
+	// LookupIP returns the location and network of an IP address.
+	LookupIP(ctx context.Context, in *LookupIPRequest, opts ...grpc.CallOption) (*IPInfo, error)
+	// LookupASN returns the name and announced prefixes of an autonomous system.
+	LookupASN(ctx context.Context, in *LookupASNRequest, opts ...grpc.CallOption) (*ASNInfo, error)
+	// LookupDomain returns the WHOIS and DNS records of a domain.
+	LookupDomain(ctx context.Context, in *LookupDomainRequest, opts ...grpc.CallOption) (*DomainInfo, error)
+	// BatchLookup answers every query sent on the stream with one response, in the order the queries were sent.
+	BatchLookup(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[BatchLookupRequest, BatchLookupResponse], error)
+}
+
+type iPInfoServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIPInfoServiceClient(cc grpc.ClientConnInterface) IPInfoServiceClient {
+	return &iPInfoServiceClient{cc}
+}
+
+func (c *iPInfoServiceClient) LookupIP(ctx context.Context, in *LookupIPRequest, opts ...grpc.CallOption) (*IPInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IPInfo)
+	err := c.cc.Invoke(ctx, IPInfoService_LookupIP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iPInfoServiceClient) LookupASN(ctx context.Context, in *LookupASNRequest, opts ...grpc.CallOption) (*ASNInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ASNInfo)
+	err := c.cc.Invoke(ctx, IPInfoService_LookupASN_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iPInfoServiceClient) LookupDomain(ctx context.Context, in *LookupDomainRequest, opts ...grpc.CallOption) (*DomainInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DomainInfo)
+	err := c.cc.Invoke(ctx, IPInfoService_LookupDomain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *iPInfoServiceClient) BatchLookup(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[BatchLookupRequest, BatchLookupResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IPInfoService_ServiceDesc.Streams[0], IPInfoService_BatchLookup_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchLookupRequest, BatchLookupResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IPInfoService_BatchLookupClient = grpc.BidiStreamingClient[BatchLookupRequest, BatchLookupResponse]
+
+// IPInfoServiceServer is the server API for IPInfoService service.
+// All implementations must embed UnimplementedIPInfoServiceServer
+// for forward compatibility.
+//
+// IPInfoService looks up IP addresses, networks, ASNs and domains with the same data as the HTTP API.
+type IPInfoServiceServer interface {
+	// LookupIP returns the location and network of an IP address.
+	LookupIP(context.Context, *LookupIPRequest) (*IPInfo, error)
+	// LookupASN returns the name and announced prefixes of an autonomous system.
+	LookupASN(context.Context, *LookupASNRequest) (*ASNInfo, error)
+	// LookupDomain returns the WHOIS and DNS records of a domain.
+	LookupDomain(context.Context, *LookupDomainRequest) (*DomainInfo, error)
+	// BatchLookup answers every query sent on the stream with one response, in the order the queries were sent.
+	BatchLookup(grpc.BidiStreamingServer[BatchLookupRequest, BatchLookupResponse]) error
+	mustEmbedUnimplementedIPInfoServiceServer()
+}
+
+// UnimplementedIPInfoServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIPInfoServiceServer struct{}
+
+func (UnimplementedIPInfoServiceServer) LookupIP(context.Context, *LookupIPRequest) (*IPInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupIP not implemented")
+}
+func (UnimplementedIPInfoServiceServer) LookupASN(context.Context, *LookupASNRequest) (*ASNInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupASN not implemented")
+}
+func (UnimplementedIPInfoServiceServer) LookupDomain(context.Context, *LookupDomainRequest) (*DomainInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupDomain not implemented")
+}
+func (UnimplementedIPInfoServiceServer) BatchLookup(grpc.BidiStreamingServer[BatchLookupRequest, BatchLookupResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BatchLookup not implemented")
+}
+func (UnimplementedIPInfoServiceServer) mustEmbedUnimplementedIPInfoServiceServer() {}
+func (UnimplementedIPInfoServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeIPInfoServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IPInfoServiceServer will
+// result in compilation errors.
+type UnsafeIPInfoServiceServer interface {
+	mustEmbedUnimplementedIPInfoServiceServer()
+}
+
+func RegisterIPInfoServiceServer(s grpc.ServiceRegistrar, srv IPInfoServiceServer) {
+	// If the following call pancis, it indicates UnimplementedIPInfoServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&IPInfoService_ServiceDesc, srv)
+}
+
+func _IPInfoService_LookupIP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupIPRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IPInfoServiceServer).LookupIP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IPInfoService_LookupIP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IPInfoServiceServer).LookupIP(ctx, req.(*LookupIPRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IPInfoService_LookupASN_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupASNRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IPInfoServiceServer).LookupASN(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IPInfoService_LookupASN_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IPInfoServiceServer).LookupASN(ctx, req.(*LookupASNRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IPInfoService_LookupDomain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupDomainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IPInfoServiceServer).LookupDomain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IPInfoService_LookupDomain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IPInfoServiceServer).LookupDomain(ctx, req.(*LookupDomainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IPInfoService_BatchLookup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IPInfoServiceServer).BatchLookup(&grpc.GenericServerStream[BatchLookupRequest, BatchLookupResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IPInfoService_BatchLookupServer = grpc.BidiStreamingServer[BatchLookupRequest, BatchLookupResponse]
+
+// IPInfoService_ServiceDesc is the grpc.ServiceDesc for IPInfoService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IPInfoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ipinfo.v1.IPInfoService",
+	HandlerType: (*IPInfoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "LookupIP",
+			Handler:    _IPInfoService_LookupIP_Handler,
+		},
+		{
+			MethodName: "LookupASN",
+			Handler:    _IPInfoService_LookupASN_Handler,
+		},
+		{
+			MethodName: "LookupDomain",
+			Handler:    _IPInfoService_LookupDomain_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchLookup",
+			Handler:       _IPInfoService_BatchLookup_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "ipinfo/v1/ipinfo.proto",
+}